@@ -5,11 +5,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"backend/internal/config"
 	"backend/internal/db"
 	"backend/internal/handlers"
+	"backend/internal/mailer"
 	"backend/internal/middleware"
+	"backend/internal/services"
 
 	"github.com/gorilla/mux"
 )
@@ -18,6 +21,9 @@ func main() {
 
 	// loading environment variables from .env file
 	config.LoadConfig()
+
+	// picking the mailer (smtp / log) :
+	mailer.Init()
 	
 	// connection to postgrSQL : 
 	if err := db.Connect(); err != nil {
@@ -25,6 +31,30 @@ func main() {
 	}
 	fmt.Println("✅ Connected to Postgres")
 
+	// background job fixing drifted quota counters :
+	services.StartQuotaReconciler(time.Duration(config.AppConfig.QuotaReconcileMinutes) * time.Minute)
+
+	// hash-chaining audit events written before the chain existed, then signing the head periodically :
+	if err := services.ChainAuditBacklog(); err != nil {
+		log.Printf("❌ audit backlog chaining failed: %v", err)
+	}
+	services.StartAuditCheckpointer(time.Duration(config.AppConfig.AuditCheckpointMinutes) * time.Minute)
+
+	// today's statistics snapshot, refreshed periodically for the history charts :
+	services.StartStatsSnapshotter(time.Duration(config.AppConfig.StatsSnapshotMinutes) * time.Minute)
+
+	// text extraction of uploaded documents for full-text search :
+	services.StartContentExtractor(time.Duration(config.AppConfig.ContentExtractMinutes) * time.Minute)
+
+	// notifications for subscribed saved searches :
+	services.StartSavedSearchNotifier(time.Duration(config.AppConfig.SavedSearchNotifyMinutes) * time.Minute)
+
+	// thumbnails & previews of uploaded images, PDFs and plaintext :
+	services.StartPreviewGenerator(time.Duration(config.AppConfig.PreviewGenerateMinutes) * time.Minute)
+
+	// bulk jobs don't survive a restart :
+	services.FailInterruptedBulkJobs()
+
 	// make uploads dir if missing : 
 	os.MkdirAll("./uploads", os.ModePerm)
 
@@ -36,6 +66,11 @@ func main() {
 	r.HandleFunc("/api/login", handlers.LoginHandler).Methods("POST")
 	r.HandleFunc("/api/logout", handlers.LogoutHandler).Methods("POST")
 	r.HandleFunc("/api/publicFiles", handlers.PublicFilesHandler).Methods("GET")
+
+	// password reset & email verification :
+	r.HandleFunc("/api/password/forgot", handlers.ForgotPasswordHandler).Methods("POST")
+	r.HandleFunc("/api/password/reset", handlers.ResetPasswordHandler).Methods("POST")
+	r.HandleFunc("/api/email/verify", handlers.VerifyEmailHandler).Methods("POST")
 	// file details route: soft auth → allows guests but still passes context if logged in
 	r.Handle("/api/fileDetails/{id}", middleware.SoftAuthMiddleware(http.HandlerFunc(handlers.FileDetailHandler),)).Methods("GET")
 
@@ -46,6 +81,16 @@ func main() {
 	r.Handle("/api/me", middleware.AuthMiddleware(
 		http.HandlerFunc(handlers.RefershHandler),
 	)).Methods("GET")
+
+	// changing pswd (needs the current one) :
+	r.Handle("/api/password/change", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ChangePasswordHandler)),
+		)).Methods("POST")
+
+	// re-sending the verification mail :
+	r.Handle("/api/email/verify/resend", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ResendVerificationHandler)),
+		)).Methods("POST")
 	
 	
 	// file upload route : 
@@ -55,17 +100,57 @@ func main() {
 
 	// view-files admin route :
 	r.Handle("/api/adminFiles", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny)(
 		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminFilesHandler)),
-		)).Methods("GET")
+		))).Methods("GET")
 
-	// change user roles (admin only)
+	// change user roles :
 	r.Handle("/api/makeAdmin", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
 		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.MakeAdminHandler)),
-		)).Methods("POST")
+		))).Methods("POST")
 
 	r.Handle("/api/makeUser", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
 		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.MakeUserHandler)),
-		)).Methods("POST")
+		))).Methods("POST")
+
+	r.Handle("/api/admin/users/role", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminSetUserRoleHandler)),
+		))).Methods("POST")
+
+	// roles & permissions management :
+	r.Handle("/api/admin/roles", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermRolesManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminRolesHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/roles", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermRolesManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminSaveRoleHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/admin/roles/{name}", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermRolesManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminDeleteRoleHandler)),
+		))).Methods("DELETE")
+
+	r.Handle("/api/admin/permissions", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermRolesManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminPermissionsHandler)),
+		))).Methods("GET")
+
+	// failed-login lockouts :
+	r.Handle("/api/admin/lockouts", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermSecurityManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminLockoutsHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/lockouts/clear", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermSecurityManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminClearLockoutHandler)),
+		))).Methods("POST")
 
 
 
@@ -74,6 +159,95 @@ func main() {
 		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.FilesHandler)),
 		)).Methods("GET")
 	
+	// full-text search :
+	r.Handle("/api/files/search", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.SearchFilesHandler)),
+		)).Methods("GET")
+
+	// saved searches / smart folders (listed through /api/files?saved_search={id}) :
+	r.Handle("/api/saved-searches", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ListSavedSearchesHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/saved-searches", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.CreateSavedSearchHandler)),
+		)).Methods("POST")
+
+	r.Handle("/api/saved-searches/{id:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.UpdateSavedSearchHandler)),
+		)).Methods("PUT")
+
+	r.Handle("/api/saved-searches/{id:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.DeleteSavedSearchHandler)),
+		)).Methods("DELETE")
+
+	r.Handle("/api/saved-searches/{id:[0-9]+}/subscription", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.SubscribeSavedSearchHandler)),
+		)).Methods("POST", "DELETE")
+
+	// file description, tags & custom metadata :
+	r.Handle("/api/files/{id:[0-9]+}/description", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.FileDescriptionHandler)),
+		)).Methods("PUT")
+
+	r.Handle("/api/files/{id:[0-9]+}/tags", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AddFileTagsHandler)),
+		)).Methods("POST")
+
+	r.Handle("/api/files/{id:[0-9]+}/tags/{tag}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.RemoveFileTagHandler)),
+		)).Methods("DELETE")
+
+	r.Handle("/api/files/{id:[0-9]+}/metadata/{key}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.SetFileMetadataHandler)),
+		)).Methods("PUT")
+
+	r.Handle("/api/files/{id:[0-9]+}/metadata/{key}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.DeleteFileMetadataHandler)),
+		)).Methods("DELETE")
+
+	r.Handle("/api/tags", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.TagSuggestionsHandler)),
+		)).Methods("GET")
+
+	// stars, recent files & activity feed :
+	r.Handle("/api/files/{id:[0-9]+}/star", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.StarFileHandler)),
+		)).Methods("PUT", "DELETE")
+	r.Handle("/api/files/starred", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.StarredFilesHandler)),
+		)).Methods("GET")
+	r.Handle("/api/files/recent", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.RecentFilesHandler)),
+		)).Methods("GET")
+	r.Handle("/api/activity", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ActivityFeedHandler)),
+		)).Methods("GET")
+
+	// file comments :
+	r.Handle("/api/files/{id:[0-9]+}/comments", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ListFileCommentsHandler)),
+		)).Methods("GET")
+	r.Handle("/api/files/{id:[0-9]+}/comments", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AddFileCommentHandler)),
+		)).Methods("POST")
+	r.Handle("/api/files/{id:[0-9]+}/comments/{commentId:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.EditFileCommentHandler)),
+		)).Methods("PUT")
+	r.Handle("/api/files/{id:[0-9]+}/comments/{commentId:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.DeleteFileCommentHandler)),
+		)).Methods("DELETE")
+	r.Handle("/api/files/{id:[0-9]+}/comments/{commentId:[0-9]+}/history", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.CommentHistoryHandler)),
+		)).Methods("GET")
+
+	// thumbnails (soft auth : guests get those of public files) :
+	r.Handle("/api/files/{id:[0-9]+}/thumbnail", middleware.SoftAuthMiddleware(http.HandlerFunc(handlers.FileThumbnailHandler),)).Methods("GET")
+
+	// inline views (soft auth) & their signed links on the sandbox origin (VIEW_SANDBOX_ORIGIN) :
+	r.Handle("/api/files/{id:[0-9]+}/view", middleware.SoftAuthMiddleware(http.HandlerFunc(handlers.FileViewHandler),)).Methods("GET", "HEAD")
+	r.HandleFunc("/api/view/{token}", handlers.SandboxViewHandler).Methods("GET", "HEAD")
+
 	// file download route with file_id : 
 	r.Handle("/api/fileDownload/{id}", middleware.AuthMiddleware(
 		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.FileDownloadHandler)),
@@ -94,6 +268,176 @@ func main() {
 
 
 
+	// teams & shared spaces :
+	r.Handle("/api/teams", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ListTeamsHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/teams", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.CreateTeamHandler)),
+		)).Methods("POST")
+
+	r.Handle("/api/teams/{id}/members", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.TeamMembersHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/teams/{id}/members", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AddTeamMemberHandler)),
+		)).Methods("POST")
+
+	r.Handle("/api/teams/{id}/members/{userID}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.UpdateTeamMemberHandler)),
+		)).Methods("PUT")
+
+	r.Handle("/api/teams/{id}/members/{userID}", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.RemoveTeamMemberHandler)),
+		)).Methods("DELETE")
+
+	r.Handle("/api/folders", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.ListFoldersHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/folders", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.CreateFolderHandler)),
+		)).Methods("POST")
+
+	// storage quotas & usage :
+	r.Handle("/api/me/usage", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.MyUsageHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/admin/users/{id}/quota", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermQuotaManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminSetUserQuotaHandler)),
+		))).Methods("PUT")
+
+	r.Handle("/api/admin/roles/{name}/quota", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermQuotaManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminSetRoleQuotaHandler)),
+		))).Methods("PUT")
+
+	r.Handle("/api/admin/teams/{id}/quota", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermQuotaManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminSetTeamQuotaHandler)),
+		))).Methods("PUT")
+
+	// admin user management :
+	r.Handle("/api/admin/users", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminUsersHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/users/{id:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminUserDetailHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/users/{id:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminDeleteUserHandler)),
+		))).Methods("DELETE")
+
+	r.Handle("/api/admin/users/{id:[0-9]+}/activate", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminActivateUserHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/admin/users/{id:[0-9]+}/deactivate", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminDeactivateUserHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/admin/users/{id:[0-9]+}/logout", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminForceLogoutHandler)),
+		))).Methods("POST")
+
+	// ownership transfers (leavers' files & folders) and their history :
+	r.Handle("/api/admin/users/{id:[0-9]+}/transfer", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersManage, services.PermFilesManageAny)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminTransferFilesHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/admin/transfers", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermUsersRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminTransfersHandler)),
+		))).Methods("GET")
+
+	// impersonation ("act as user", read-only unless allow_destructive) :
+	r.Handle("/api/admin/users/{id:[0-9]+}/impersonate", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermImpersonate)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminImpersonateHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/impersonate/stop", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.StopImpersonationHandler)),
+		)).Methods("POST")
+
+	// system statistics :
+	r.Handle("/api/admin/stats", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny, services.PermUsersRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminStatsHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/stats/history", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny, services.PermUsersRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminStatsHistoryHandler)),
+		))).Methods("GET")
+
+	// per-file access history & download analytics :
+	r.Handle("/api/files/{id}/access", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.FileAccessHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/files/{id}/stats", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.FileStatsHandler)),
+		)).Methods("GET")
+
+	// bulk file actions (background jobs with progress, dry_run supported) :
+	r.Handle("/api/admin/files/bulk", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny, services.PermFilesManageAny)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminBulkFilesHandler)),
+		))).Methods("POST")
+
+	r.Handle("/api/admin/files/bulk", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminBulkJobsHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/files/bulk/{id:[0-9]+}", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminBulkJobHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/files/top", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermFilesReadAny)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminTopFilesHandler)),
+		))).Methods("GET")
+
+	// audit log (append-only, read & export) :
+	r.Handle("/api/admin/audit", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermAuditRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminAuditHandler)),
+		))).Methods("GET")
+
+	r.Handle("/api/admin/audit/export", middleware.AuthMiddleware(
+		middleware.RequirePermission(services.PermAuditRead)(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.AdminAuditExportHandler)),
+		))).Methods("GET")
+
+	// in-app notifications :
+	r.Handle("/api/notifications", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.NotificationsHandler)),
+		)).Methods("GET")
+
+	r.Handle("/api/notifications/readAll", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.MarkAllNotificationsReadHandler)),
+		)).Methods("POST")
+
+	r.Handle("/api/notifications/{id}/read", middleware.AuthMiddleware(
+		middleware.RateLimitMiddleware(http.HandlerFunc(handlers.MarkNotificationReadHandler)),
+		)).Methods("POST")
+
 	// loading the port no. :
 	port := config.AppConfig.Port
     if port == "" {port = "8080"}
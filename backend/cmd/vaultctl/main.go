@@ -0,0 +1,271 @@
+// vaultctl - operator commands for FileVault, run against the same .env / DB_URL as the server.
+//
+//	vaultctl audit verify       walk the audit hash chain & checkpoints, exit 1 on the first broken link
+//	vaultctl audit checkpoint   sign the current chain head now
+//	vaultctl audit keygen       print a fresh ed25519 key pair for AUDIT_SIGNING_KEY / AUDIT_PUBLIC_KEY
+//	vaultctl files transfer     hand all or selected files & folders of one user to another
+//	vaultctl files transfers    list the ownership transfer history
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/models"
+	"backend/internal/services"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: vaultctl <command> [args]
+
+commands:
+  audit verify [-json]   verify the audit hash chain and signed checkpoints
+  audit checkpoint       sign the current audit chain head
+  audit keygen           generate an ed25519 key pair for audit checkpoints
+  files transfer -from <user> -to <user> (-all | -files 1,2 -folders 3) [-reason text] [-json]
+                         move files & folders to another user, recalculating both quotas
+  files transfers [-user <user>] [-limit n] [-json]
+                         list past ownership transfers`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+
+	switch os.Args[1] + " " + os.Args[2] {
+	case "audit verify":
+		auditVerify(os.Args[3:])
+	case "audit checkpoint":
+		auditCheckpoint()
+	case "audit keygen":
+		auditKeygen()
+	case "files transfer":
+		filesTransfer(os.Args[3:])
+	case "files transfers":
+		filesTransfers(os.Args[3:])
+	default:
+		usage()
+	}
+}
+
+// connect loads the config & opens the DB, exiting on failure :
+func connect() {
+	config.LoadConfig()
+	if err := db.Connect(); err != nil {
+		fatal("DB connection failed: %v", err)
+	}
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "❌ "+format+"\n", args...)
+	os.Exit(1)
+}
+
+func auditVerify(args []string) {
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	connect()
+	report, err := services.VerifyAuditChain()
+	if err != nil {
+		fatal("verification failed: %v", err)
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(report)
+	} else {
+		fmt.Printf("events verified:   %d\n", report.Events)
+		fmt.Printf("checkpoints:       %d\n", report.Checkpoints)
+		if report.HeadID != 0 {
+			fmt.Printf("last good event:   #%d %s\n", report.HeadID, report.HeadHash)
+		}
+		if report.SignaturesSkipped {
+			fmt.Println("⚠️ no audit key configured, checkpoint signatures were not checked")
+		}
+		if report.BrokenAt != 0 {
+			fmt.Printf("❌ chain broken at event #%d: %s\n", report.BrokenAt, report.BrokenReason)
+		}
+		for _, msg := range report.CheckpointErrors {
+			fmt.Printf("❌ %s\n", msg)
+		}
+		if report.OK() {
+			fmt.Println("✅ audit log intact")
+		}
+	}
+
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+func auditCheckpoint() {
+	connect()
+	c, err := services.CreateAuditCheckpoint()
+	if err != nil {
+		fatal("checkpoint failed: %v", err)
+	}
+	if c == nil {
+		fmt.Println("ℹ️ nothing new since the last checkpoint")
+		return
+	}
+	fmt.Printf("✅ checkpoint %d signed event #%d %s (%s)\n", c.ID, c.EventID, c.EventHash, c.Algorithm)
+}
+
+func auditKeygen() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fatal("key generation failed: %v", err)
+	}
+	fmt.Println("AUDIT_SIGNING_ALG=ed25519")
+	fmt.Println("AUDIT_SIGNING_KEY=" + base64.StdEncoding.EncodeToString(priv.Seed()))
+	fmt.Println("AUDIT_PUBLIC_KEY=" + base64.StdEncoding.EncodeToString(pub))
+}
+
+// lookupUser resolves a user by id or username :
+func lookupUser(ref string) *models.User {
+	var u *models.User
+	var err error
+	if id, convErr := strconv.Atoi(ref); convErr == nil {
+		u, err = models.GetUserByID(id)
+	} else {
+		u, err = models.GetUserByUsername(ref)
+	}
+	if err != nil {
+		fatal("user lookup failed: %v", err)
+	}
+	if u == nil {
+		fatal("no such user: %s", ref)
+	}
+	return u
+}
+
+// parseIDs reads a comma separated id list :
+func parseIDs(list string) []int {
+	var ids []int
+	for _, part := range strings.Split(list, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			fatal("invalid id %q", part)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func filesTransfer(args []string) {
+	fs := flag.NewFlagSet("files transfer", flag.ExitOnError)
+	from := fs.String("from", "", "source user (id or username)")
+	to := fs.String("to", "", "target user (id or username)")
+	all := fs.Bool("all", false, "transfer every file & folder")
+	files := fs.String("files", "", "comma separated file ids")
+	folders := fs.String("folders", "", "comma separated folder ids (with everything below them)")
+	reason := fs.String("reason", "", "kept in the transfer history")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	fs.Parse(args)
+	if *from == "" || *to == "" {
+		usage()
+	}
+
+	connect()
+	req := services.TransferRequest{
+		FromUserID: lookupUser(*from).ID,
+		ToUserID:   lookupUser(*to).ID,
+		All:        *all,
+		FileIDs:    parseIDs(*files),
+		FolderIDs:  parseIDs(*folders),
+		Reason:     *reason,
+	}
+	by := services.TransferActor{Username: "vaultctl", Source: services.TransferViaCLI}
+	if u, err := user.Current(); err == nil {
+		by.Username = "vaultctl:" + u.Username
+	}
+
+	t, err := services.TransferOwnership(req, by)
+	if t == nil {
+		fatal("transfer failed: %v", err)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️ usage recalculation failed (the reconciler will fix it): %v\n", err)
+	}
+	services.RecordAudit(services.AuditEvent{
+		ActorUsername: by.Username,
+		Action:        services.AuditFileTransfer,
+		TargetType:    "user",
+		TargetID:      strconv.Itoa(t.FromUserID),
+		Outcome:       services.AuditSuccess,
+		Details: mustJSON(map[string]interface{}{
+			"transfer_id": t.ID, "to_user_id": t.ToUserID, "files": t.Files, "folders": t.Folders, "bytes": t.Bytes, "reason": t.Reason,
+		}),
+	})
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(t)
+		return
+	}
+	fmt.Printf("✅ transfer %d: %s → %s, %d file(s), %d folder(s), %d personal bytes\n",
+		t.ID, t.FromUsername, t.ToUsername, t.Files, t.Folders, t.Bytes)
+	if t.HandedToTeamOwners > 0 {
+		fmt.Printf("ℹ️ %d team item(s) went to another team owner (%s isn't a member)\n", t.HandedToTeamOwners, t.ToUsername)
+	}
+	if t.Skipped > 0 {
+		fmt.Printf("⚠️ %d team item(s) stayed with %s (no other team owner)\n", t.Skipped, t.FromUsername)
+	}
+}
+
+func filesTransfers(args []string) {
+	fs := flag.NewFlagSet("files transfers", flag.ExitOnError)
+	who := fs.String("user", "", "only transfers from or to this user (id or username)")
+	limit := fs.Int("limit", 20, "max rows")
+	asJSON := fs.Bool("json", false, "print the history as JSON")
+	fs.Parse(args)
+
+	connect()
+	var userID *int
+	if *who != "" {
+		id := lookupUser(*who).ID
+		userID = &id
+	}
+	transfers, total, err := services.ListOwnershipTransfers(userID, *limit, 0)
+	if err != nil {
+		fatal("listing failed: %v", err)
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(transfers)
+		return
+	}
+	for _, t := range transfers {
+		by := t.PerformedByUsername
+		if by == "" {
+			by = "-"
+		}
+		fmt.Printf("#%d  %s  %s → %s  files=%d folders=%d bytes=%d  via=%s by=%s  %s\n",
+			t.ID, t.CreatedAt.Format("2006-01-02 15:04"), t.FromUsername, t.ToUsername,
+			t.Files, t.Folders, t.Bytes, t.Source, by, t.Reason)
+	}
+	fmt.Printf("%d of %d transfer(s)\n", len(transfers), total)
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		fatal("encoding failed: %v", err)
+	}
+	return b
+}
@@ -4,12 +4,10 @@ go 1.25.1
 
 require (
 	github.com/golang-jwt/jwt/v5 v5.3.0
+	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.42.0
-)
-
-require (
-	github.com/gorilla/mux v1.8.1 // indirect
-	golang.org/x/time v0.13.0 // indirect
+	golang.org/x/image v0.33.0
+	golang.org/x/time v0.13.0
 )
@@ -3,7 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -14,7 +16,65 @@ type Config struct {
 	DBUrl        string
 	JWTKey       string
 	UserQuotaMB  int
+	TeamQuotaMB  int
 	ApiRateLimit int
+
+	// quota counters & warnings :
+	QuotaReconcileMinutes int
+	QuotaWarnThresholds   []int
+	QuotaWarnEmail        bool
+
+	// mailer settings :
+	Mailer       string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	MailFrom     string
+	MailLogFile  string
+
+	// account flows :
+	AppBaseURL               string
+	RequireEmailVerification bool
+	ResetTokenTTLMinutes     int
+	VerifyTokenTTLHours      int
+
+	// password policy :
+	PasswordMinLength    int
+	PasswordMinScore     int
+	BreachedPasswordsDir string
+
+	// login brute-force protection :
+	LoginMaxFailures       int
+	LoginIPMaxFailures     int
+	LoginLockoutBaseSecs   int
+	LoginLockoutMaxMinutes int
+	LoginFailureWindowMins int
+	TrustProxyHeaders      bool
+
+	// audit chain checkpoints :
+	AuditSigningAlg        string
+	AuditSigningKey        string
+	AuditPublicKey         string
+	AuditCheckpointMinutes int
+
+	// daily statistics snapshots :
+	StatsSnapshotMinutes int
+
+	// full-text search content extraction :
+	ContentExtractMinutes int
+
+	// saved search subscriptions :
+	SavedSearchNotifyMinutes int
+
+	// thumbnails & previews :
+	PreviewGenerateMinutes int
+	PreviewDir             string
+	ThumbnailSize          int
+
+	// inline views : optional separate origin for untrusted content, lifetime of its signed links :
+	ViewSandboxOrigin   string
+	ViewTokenTTLMinutes int
 }
 
 // AppConfig will be populated on app booting :
@@ -39,7 +99,54 @@ func LoadConfig() {
 		DBUrl:        dbURL,
 		JWTKey:       jwtKey,
 		UserQuotaMB:  userQuotaMB,
+		TeamQuotaMB:  getEnvAsInt("TEAM_QUOTA_MB", 100),
 		ApiRateLimit: apiRateLimit,
+
+		QuotaReconcileMinutes: getEnvAsInt("QUOTA_RECONCILE_MINUTES", 60),
+		QuotaWarnThresholds:   getEnvAsIntList("QUOTA_WARN_THRESHOLDS", []int{80, 95}),
+		QuotaWarnEmail:        getEnvAsBool("QUOTA_WARN_EMAIL", false),
+
+		Mailer:       getEnv("MAILER", "log"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@filevault.local"),
+		MailLogFile:  getEnv("MAIL_LOG_FILE", ""),
+
+		AppBaseURL:               getEnv("APP_BASE_URL", "http://localhost:5173"),
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+		ResetTokenTTLMinutes:     getEnvAsInt("RESET_TOKEN_TTL_MINUTES", 30),
+		VerifyTokenTTLHours:      getEnvAsInt("VERIFY_TOKEN_TTL_HOURS", 24),
+
+		PasswordMinLength:    getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMinScore:     getEnvAsInt("PASSWORD_MIN_SCORE", 2),
+		BreachedPasswordsDir: getEnv("BREACHED_PASSWORDS_DIR", ""),
+
+		LoginMaxFailures:       getEnvAsInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:     getEnvAsInt("LOGIN_IP_MAX_FAILURES", 20),
+		LoginLockoutBaseSecs:   getEnvAsInt("LOGIN_LOCKOUT_BASE_SECONDS", 30),
+		LoginLockoutMaxMinutes: getEnvAsInt("LOGIN_LOCKOUT_MAX_MINUTES", 60),
+		LoginFailureWindowMins: getEnvAsInt("LOGIN_FAILURE_WINDOW_MINUTES", 15),
+		TrustProxyHeaders:      getEnvAsBool("TRUST_PROXY_HEADERS", false),
+
+		AuditSigningAlg:        getEnv("AUDIT_SIGNING_ALG", "hmac"),
+		AuditSigningKey:        getEnv("AUDIT_SIGNING_KEY", ""),
+		AuditPublicKey:         getEnv("AUDIT_PUBLIC_KEY", ""),
+		AuditCheckpointMinutes: getEnvAsInt("AUDIT_CHECKPOINT_MINUTES", 60),
+
+		StatsSnapshotMinutes: getEnvAsInt("STATS_SNAPSHOT_MINUTES", 60),
+
+		ContentExtractMinutes: getEnvAsInt("CONTENT_EXTRACT_MINUTES", 5),
+
+		SavedSearchNotifyMinutes: getEnvAsInt("SAVED_SEARCH_NOTIFY_MINUTES", 15),
+
+		PreviewGenerateMinutes: getEnvAsInt("PREVIEW_GENERATE_MINUTES", 5),
+		PreviewDir:             getEnv("PREVIEW_DIR", "./uploads/previews"),
+		ThumbnailSize:          getEnvAsInt("THUMBNAIL_SIZE", 256),
+
+		ViewSandboxOrigin:   strings.TrimRight(getEnv("VIEW_SANDBOX_ORIGIN", ""), "/"),
+		ViewTokenTTLMinutes: getEnvAsInt("VIEW_TOKEN_TTL_MINUTES", 15),
 	}
 }
 
@@ -59,3 +166,30 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsBool fetches env var as bool, with fallback if parse fails :
+func getEnvAsBool(key string, fallback bool) bool {
+	valStr := getEnv(key, "")
+	if val, err := strconv.ParseBool(valStr); err == nil {
+		return val
+	}
+	return fallback
+}
+
+// getEnvAsIntList fetches a comma-separated env var as sorted ints, with fallback if any part is invalid :
+func getEnvAsIntList(key string, fallback []int) []int {
+	valStr := getEnv(key, "")
+	if strings.TrimSpace(valStr) == "" {
+		return fallback
+	}
+	var vals []int
+	for _, part := range strings.Split(valStr, ",") {
+		val, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return fallback
+		}
+		vals = append(vals, val)
+	}
+	sort.Ints(vals)
+	return vals
+}
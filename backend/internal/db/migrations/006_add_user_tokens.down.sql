@@ -0,0 +1,6 @@
+-- removing user_tokens table :
+DROP TABLE IF EXISTS user_tokens;
+
+-- removing email_verified col from users :
+ALTER TABLE users
+DROP COLUMN IF EXISTS email_verified;
@@ -0,0 +1,8 @@
+-- removing role FK from users :
+ALTER TABLE users
+DROP CONSTRAINT IF EXISTS fk_users_role;
+
+-- dropping roles & permissions tables :
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
@@ -0,0 +1,58 @@
+-- ============================
+-- Roles & permissions
+-- ============================
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description TEXT,
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE ON UPDATE CASCADE,
+    permission VARCHAR(64) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- built-in permissions :
+INSERT INTO permissions (name, description) VALUES
+    ('files.read.any',   'List, view and download any user''s files'),
+    ('files.manage.any', 'Modify or delete any user''s files'),
+    ('users.read',       'List users and view their details'),
+    ('users.manage',     'Change user roles and account status'),
+    ('roles.manage',     'Create, edit and delete roles'),
+    ('quota.manage',     'Change storage quotas'),
+    ('audit.read',       'Read the audit log'),
+    ('security.manage',  'View and clear login lockouts')
+ON CONFLICT (name) DO NOTHING;
+
+-- built-in roles :
+INSERT INTO roles (name, description, is_system) VALUES
+    ('admin',   'Full access', TRUE),
+    ('user',    'Regular account', TRUE),
+    ('auditor', 'Read-only access to files, users and the audit log', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('auditor', 'files.read.any'),
+    ('auditor', 'users.read'),
+    ('auditor', 'audit.read')
+ON CONFLICT DO NOTHING;
+
+-- keeping any role string already in use valid :
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users
+ON CONFLICT (name) DO NOTHING;
+
+-- users.role now points at a real role :
+ALTER TABLE users
+ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
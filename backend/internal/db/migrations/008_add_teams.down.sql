@@ -0,0 +1,11 @@
+-- removing team/folder cols from files :
+ALTER TABLE files
+DROP COLUMN IF EXISTS folder_id;
+
+ALTER TABLE files
+DROP COLUMN IF EXISTS team_id;
+
+-- dropping team tables :
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,6 @@
+-- removing quota overrides :
+ALTER TABLE roles
+DROP COLUMN IF EXISTS default_quota_bytes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS quota_bytes;
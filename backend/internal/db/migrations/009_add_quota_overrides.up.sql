@@ -0,0 +1,7 @@
+-- per-user quota override (NULL → role default → USER_QUOTA_MB) :
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS quota_bytes BIGINT;
+
+-- per-role default quota (NULL → USER_QUOTA_MB) :
+ALTER TABLE roles
+ADD COLUMN IF NOT EXISTS default_quota_bytes BIGINT;
@@ -0,0 +1,6 @@
+-- removing usage counters :
+ALTER TABLE teams
+DROP COLUMN IF EXISTS used_bytes;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS used_bytes;
@@ -0,0 +1,13 @@
+-- running usage counters, kept in sync transactionally by upload / delete :
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS used_bytes BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE teams
+ADD COLUMN IF NOT EXISTS used_bytes BIGINT NOT NULL DEFAULT 0;
+
+-- backfilling from existing files :
+UPDATE users u
+SET used_bytes = COALESCE((SELECT SUM(f.size) FROM files f WHERE f.user_id = u.id AND f.team_id IS NULL), 0);
+
+UPDATE teams t
+SET used_bytes = COALESCE((SELECT SUM(f.size) FROM files f WHERE f.team_id = t.id), 0);
@@ -0,0 +1,9 @@
+-- removing quota warning state :
+ALTER TABLE teams
+DROP COLUMN IF EXISTS quota_warned_pct;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS quota_warned_pct;
+
+-- dropping notifications table :
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,21 @@
+-- ============================
+-- In-app notifications
+-- ============================
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL DEFAULT '',
+    read_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications (user_id, created_at DESC);
+
+-- highest quota warning threshold already notified (0 = none) :
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS quota_warned_pct INT NOT NULL DEFAULT 0;
+
+ALTER TABLE teams
+ADD COLUMN IF NOT EXISTS quota_warned_pct INT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- dropping the audit log :
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
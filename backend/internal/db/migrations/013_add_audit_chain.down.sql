@@ -0,0 +1,15 @@
+-- dropping checkpoints :
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP FUNCTION IF EXISTS audit_checkpoints_append_only();
+
+-- back to the strict append-only trigger :
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- removing the chain columns :
+ALTER TABLE audit_events
+DROP COLUMN IF EXISTS hash,
+DROP COLUMN IF EXISTS prev_hash;
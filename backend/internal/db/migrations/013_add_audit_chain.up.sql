@@ -0,0 +1,55 @@
+-- ============================
+-- Hash-chained audit log
+-- ============================
+-- hash = SHA-256(prev_hash + canonical event), prev_hash of the first event = 64 zeros.
+ALTER TABLE audit_events
+ADD COLUMN IF NOT EXISTS prev_hash CHAR(64),
+ADD COLUMN IF NOT EXISTS hash CHAR(64);
+
+-- still append-only, except the one-time chaining of rows written before this migration
+-- (only hash / prev_hash may be filled in, and only while hash is NULL) :
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD.hash IS NULL AND NEW.hash IS NOT NULL
+        AND NEW.id = OLD.id
+        AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+        AND NEW.actor_username = OLD.actor_username
+        AND NEW.action = OLD.action
+        AND NEW.target_type = OLD.target_type
+        AND NEW.target_id = OLD.target_id
+        AND NEW.ip = OLD.ip
+        AND NEW.user_agent = OLD.user_agent
+        AND NEW.outcome = OLD.outcome
+        AND NEW.details = OLD.details
+        AND NEW.created_at = OLD.created_at THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+-- signed snapshots of the chain head :
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id SERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL,
+    event_hash CHAR(64) NOT NULL,
+    algorithm VARCHAR(16) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE OR REPLACE FUNCTION audit_checkpoints_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_checkpoints is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_checkpoints_no_update ON audit_checkpoints;
+CREATE TRIGGER trg_audit_checkpoints_no_update
+    BEFORE UPDATE OR DELETE ON audit_checkpoints
+    FOR EACH ROW EXECUTE FUNCTION audit_checkpoints_append_only();
+
+DROP TRIGGER IF EXISTS trg_audit_checkpoints_no_truncate ON audit_checkpoints;
+CREATE TRIGGER trg_audit_checkpoints_no_truncate
+    BEFORE TRUNCATE ON audit_checkpoints
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_checkpoints_append_only();
@@ -0,0 +1,4 @@
+-- removing download history :
+DROP INDEX IF EXISTS idx_files_size;
+DROP INDEX IF EXISTS idx_files_download_count;
+DROP TABLE IF EXISTS file_downloads;
@@ -0,0 +1,20 @@
+-- ============================
+-- Per-download access history
+-- ============================
+CREATE TABLE IF NOT EXISTS file_downloads (
+    id BIGSERIAL PRIMARY KEY,
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    via VARCHAR(16) NOT NULL,          -- owner / team / public / admin
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    bytes_served BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_downloads_file ON file_downloads (file_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_file_downloads_created ON file_downloads (created_at);
+
+-- admin top-N lists read these directly :
+CREATE INDEX IF NOT EXISTS idx_files_download_count ON files (download_count DESC);
+CREATE INDEX IF NOT EXISTS idx_files_size ON files (size DESC);
@@ -0,0 +1,5 @@
+-- removing session versioning :
+DROP INDEX IF EXISTS idx_users_created;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS session_version;
@@ -0,0 +1,6 @@
+-- bumped to invalidate every JWT issued to the user (force logout / deactivation) :
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS session_version INT NOT NULL DEFAULT 0;
+
+-- admin user listing searches / sorts on these :
+CREATE INDEX IF NOT EXISTS idx_users_created ON users (created_at DESC);
@@ -0,0 +1,3 @@
+-- removing daily statistics :
+DROP INDEX IF EXISTS idx_files_uploaded_at;
+DROP TABLE IF EXISTS stats_snapshots;
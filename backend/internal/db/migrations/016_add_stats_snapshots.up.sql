@@ -0,0 +1,18 @@
+-- ============================
+-- Daily system statistics
+-- ============================
+-- one row per day, upserted by the snapshot job (the last run of the day wins) :
+CREATE TABLE IF NOT EXISTS stats_snapshots (
+    day DATE PRIMARY KEY,
+    total_users INT NOT NULL,
+    active_users INT NOT NULL,
+    total_files INT NOT NULL,
+    logical_bytes BIGINT NOT NULL,
+    physical_bytes BIGINT NOT NULL,
+    uploads INT NOT NULL,
+    upload_bytes BIGINT NOT NULL,
+    taken_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- per-day upload volume :
+CREATE INDEX IF NOT EXISTS idx_files_uploaded_at ON files (uploaded_at);
@@ -0,0 +1,9 @@
+-- removing impersonation :
+DROP INDEX IF EXISTS idx_audit_events_impersonator;
+
+ALTER TABLE audit_events
+DROP COLUMN IF EXISTS impersonator_username,
+DROP COLUMN IF EXISTS impersonator_id;
+
+DELETE FROM role_permissions WHERE permission = 'users.impersonate';
+DELETE FROM permissions WHERE name = 'users.impersonate';
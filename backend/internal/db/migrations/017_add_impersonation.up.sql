@@ -0,0 +1,18 @@
+-- ============================
+-- Admin impersonation
+-- ============================
+INSERT INTO permissions (name, description) VALUES
+    ('users.impersonate', 'Act as another user (audited)')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users.impersonate')
+ON CONFLICT DO NOTHING;
+
+-- the real person behind an impersonated action (NULL for normal requests) :
+ALTER TABLE audit_events
+ADD COLUMN IF NOT EXISTS impersonator_id INT,
+ADD COLUMN IF NOT EXISTS impersonator_username VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_impersonator ON audit_events (impersonator_id, created_at DESC)
+    WHERE impersonator_id IS NOT NULL;
@@ -0,0 +1,8 @@
+-- removing bulk jobs & quarantine :
+DROP TABLE IF EXISTS bulk_jobs;
+
+DROP INDEX IF EXISTS idx_files_quarantined;
+
+ALTER TABLE files
+DROP COLUMN IF EXISTS quarantined_by,
+DROP COLUMN IF EXISTS quarantined_at;
@@ -0,0 +1,29 @@
+-- ============================
+-- Bulk admin file actions
+-- ============================
+
+-- quarantined files stay on disk but only admins can read them :
+ALTER TABLE files
+ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMPTZ,
+ADD COLUMN IF NOT EXISTS quarantined_by INT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_files_quarantined ON files (quarantined_at) WHERE quarantined_at IS NOT NULL;
+
+-- one row per bulk action, progress updated while it runs :
+CREATE TABLE IF NOT EXISTS bulk_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(30) NOT NULL,
+    params TEXT NOT NULL DEFAULT '{}',
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- queued / running / done / failed
+    total INT NOT NULL DEFAULT 0,
+    processed INT NOT NULL DEFAULT 0,
+    succeeded INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    errors TEXT NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_bulk_jobs_created ON bulk_jobs (created_at DESC);
@@ -0,0 +1,10 @@
+-- removing transfer history & restoring the cascades :
+DROP TABLE IF EXISTS ownership_transfers;
+
+ALTER TABLE folders
+DROP CONSTRAINT IF EXISTS folders_user_id_fkey,
+ADD CONSTRAINT folders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE files
+DROP CONSTRAINT IF EXISTS files_user_id_fkey,
+ADD CONSTRAINT files_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
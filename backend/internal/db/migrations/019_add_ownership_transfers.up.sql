@@ -0,0 +1,34 @@
+-- ============================
+-- Ownership transfers
+-- ============================
+
+-- deleting a user must never take their files or folders along implicitly :
+ALTER TABLE files
+DROP CONSTRAINT IF EXISTS files_user_id_fkey,
+ADD CONSTRAINT files_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+ALTER TABLE folders
+DROP CONSTRAINT IF EXISTS folders_user_id_fkey,
+ADD CONSTRAINT folders_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+-- history of files & folders handed from one user to another (usernames kept, users may be deleted later) :
+CREATE TABLE IF NOT EXISTS ownership_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    from_user_id INT NOT NULL,
+    from_username VARCHAR(50) NOT NULL,
+    to_user_id INT NOT NULL,
+    to_username VARCHAR(50) NOT NULL,
+    performed_by INT,
+    performed_by_username VARCHAR(50),
+    source VARCHAR(20) NOT NULL,                 -- api / cli / user_delete
+    selection TEXT NOT NULL DEFAULT '{}',        -- all, or the requested file & folder ids
+    files INT NOT NULL DEFAULT 0,
+    folders INT NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0,             -- personal bytes moved between quotas
+    handed_to_team_owners INT NOT NULL DEFAULT 0,
+    reason TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ownership_transfers_from ON ownership_transfers (from_user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ownership_transfers_to ON ownership_transfers (to_user_id, created_at DESC);
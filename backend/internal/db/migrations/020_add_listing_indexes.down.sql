@@ -0,0 +1,10 @@
+-- restoring the single-column indexes :
+DROP INDEX IF EXISTS idx_files_public_uploaded_at;
+DROP INDEX IF EXISTS idx_files_name_id;
+DROP INDEX IF EXISTS idx_files_download_count_id;
+DROP INDEX IF EXISTS idx_files_size_id;
+DROP INDEX IF EXISTS idx_files_uploaded_at_id;
+
+CREATE INDEX IF NOT EXISTS idx_files_download_count ON files (download_count);
+CREATE INDEX IF NOT EXISTS idx_files_size ON files (size);
+CREATE INDEX IF NOT EXISTS idx_files_uploaded_at ON files (uploaded_at);
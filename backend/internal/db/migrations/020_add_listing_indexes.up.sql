@@ -0,0 +1,16 @@
+-- ============================
+-- Keyset pagination of file listings
+-- ============================
+-- every sort key is paired with id (the tie-breaker), superseding the single-column indexes :
+DROP INDEX IF EXISTS idx_files_uploaded_at;
+DROP INDEX IF EXISTS idx_files_size;
+DROP INDEX IF EXISTS idx_files_download_count;
+
+CREATE INDEX IF NOT EXISTS idx_files_uploaded_at_id ON files (uploaded_at, id);
+CREATE INDEX IF NOT EXISTS idx_files_size_id ON files (size, id);
+CREATE INDEX IF NOT EXISTS idx_files_download_count_id ON files (download_count, id);
+CREATE INDEX IF NOT EXISTS idx_files_name_id ON files (LOWER(filename), id);
+
+-- the public listing only ever reads visible files :
+CREATE INDEX IF NOT EXISTS idx_files_public_uploaded_at ON files (uploaded_at, id)
+    WHERE is_public = TRUE AND quarantined_at IS NULL;
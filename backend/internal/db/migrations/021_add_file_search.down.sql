@@ -0,0 +1,7 @@
+-- removing full-text search :
+DROP INDEX IF EXISTS idx_files_search_vector;
+
+ALTER TABLE files
+DROP COLUMN IF EXISTS search_vector;
+
+DROP TABLE IF EXISTS file_contents;
@@ -0,0 +1,31 @@
+-- ============================
+-- Full-text search over files
+-- ============================
+-- text extracted from uploaded documents, once per content hash (deduplicated files share it) :
+CREATE TABLE IF NOT EXISTS file_contents (
+    hash TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending | done | skipped | failed
+    content TEXT NOT NULL DEFAULT '',
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_contents_pending ON file_contents (updated_at)
+    WHERE status = 'pending';
+
+-- filename (A), description (B) and extracted content (C), rebuilt by the app :
+ALTER TABLE files
+ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+CREATE INDEX IF NOT EXISTS idx_files_search_vector ON files USING GIN (search_vector);
+
+-- existing files : names & descriptions searchable right away, contents queued for the extractor :
+UPDATE files
+SET search_vector =
+    setweight(to_tsvector('english', regexp_replace(filename, '[._-]+', ' ', 'g')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B');
+
+INSERT INTO file_contents (hash)
+SELECT DISTINCT hash FROM files
+ON CONFLICT (hash) DO NOTHING;
@@ -0,0 +1,4 @@
+-- removing tags & custom metadata :
+DROP TABLE IF EXISTS file_metadata;
+DROP TABLE IF EXISTS file_tags;
+DROP TABLE IF EXISTS tags;
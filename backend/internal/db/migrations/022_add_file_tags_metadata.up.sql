@@ -0,0 +1,29 @@
+-- ============================
+-- Tags & custom metadata on files
+-- ============================
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE -- normalized : trimmed, lower case
+);
+
+CREATE TABLE IF NOT EXISTS file_tags (
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    created_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_tags_tag ON file_tags (tag_id);
+
+-- key / value pairs, one value per key :
+CREATE TABLE IF NOT EXISTS file_metadata (
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_metadata_key_value ON file_metadata (key, LOWER(value));
@@ -0,0 +1,25 @@
+-- ============================
+-- Saved searches (smart folders)
+-- ============================
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    team_id INT REFERENCES teams(id) ON DELETE SET NULL, -- shared with this team's members
+    name TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}', -- listing params : filters, space, team_id, folder_id
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_saved_searches_team ON saved_searches (team_id)
+    WHERE team_id IS NOT NULL;
+
+-- notified of new matching files, uploaded after last_file_id :
+CREATE TABLE IF NOT EXISTS saved_search_subscriptions (
+    search_id INT NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_file_id INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (search_id, user_id)
+);
@@ -0,0 +1,4 @@
+-- removing stars, recent files & the activity feed :
+DROP TABLE IF EXISTS activities;
+DROP TABLE IF EXISTS file_views;
+DROP TABLE IF EXISTS file_stars;
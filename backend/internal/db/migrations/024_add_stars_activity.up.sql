@@ -0,0 +1,36 @@
+-- ============================
+-- Starred files, recently accessed files, activity feed
+-- ============================
+CREATE TABLE IF NOT EXISTS file_stars (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, file_id)
+);
+
+-- last download / detail view per user & file (only the latest ones are kept) :
+CREATE TABLE IF NOT EXISTS file_views (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    viewed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    views INT NOT NULL DEFAULT 1,
+    PRIMARY KEY (user_id, file_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_views_user ON file_views (user_id, viewed_at DESC);
+
+-- one row per event & recipient (fan-out on write); filename kept once the file is gone :
+CREATE TABLE IF NOT EXISTS activities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,
+    actor_id INT REFERENCES users(id) ON DELETE SET NULL,
+    actor_username TEXT,
+    file_id INT REFERENCES files(id) ON DELETE SET NULL,
+    filename TEXT,
+    team_id INT REFERENCES teams(id) ON DELETE SET NULL,
+    details JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_activities_user ON activities (user_id, id DESC);
@@ -0,0 +1,3 @@
+-- removing file comments :
+DROP TABLE IF EXISTS file_comment_revisions;
+DROP TABLE IF EXISTS file_comments;
@@ -0,0 +1,29 @@
+-- ============================
+-- File comments (threaded, soft-deleted) & their edit history
+-- ============================
+CREATE TABLE IF NOT EXISTS file_comments (
+    id SERIAL PRIMARY KEY,
+    file_id INT NOT NULL REFERENCES files(id) ON DELETE CASCADE,
+    -- replies always point at the thread's top-level comment :
+    parent_id INT REFERENCES file_comments(id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    edited_at TIMESTAMP,
+    deleted_at TIMESTAMP,
+    deleted_by INT REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_comments_file ON file_comments (file_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_file_comments_parent ON file_comments (parent_id) WHERE parent_id IS NOT NULL;
+
+-- previous bodies, one row per edit (and the last body on deletion) :
+CREATE TABLE IF NOT EXISTS file_comment_revisions (
+    id SERIAL PRIMARY KEY,
+    comment_id INT NOT NULL REFERENCES file_comments(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    replaced_by INT REFERENCES users(id) ON DELETE SET NULL,
+    replaced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_comment_revisions_comment ON file_comment_revisions (comment_id, id);
@@ -0,0 +1,2 @@
+-- removing previews (the generated images under PREVIEW_DIR can be deleted by hand) :
+DROP TABLE IF EXISTS file_previews;
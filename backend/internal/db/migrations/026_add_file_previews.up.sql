@@ -0,0 +1,24 @@
+-- ============================
+-- Thumbnails & previews
+-- ============================
+-- one preview per content hash (deduplicated files share it), the image itself lives under PREVIEW_DIR :
+CREATE TABLE IF NOT EXISTS file_previews (
+    hash TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending | done | skipped | failed
+    kind TEXT,                              -- image | pdf | text, once done
+    mime_type TEXT,
+    path TEXT,
+    width INT,
+    height INT,
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_previews_pending ON file_previews (updated_at)
+    WHERE status = 'pending';
+
+-- existing files are queued for the generator :
+INSERT INTO file_previews (hash)
+SELECT DISTINCT hash FROM files
+ON CONFLICT (hash) DO NOTHING;
@@ -0,0 +1,7 @@
+-- back to the file id watermark (the newest file uploaded before seen_until) :
+ALTER TABLE saved_search_subscriptions ADD COLUMN IF NOT EXISTS last_file_id INT NOT NULL DEFAULT 0;
+
+UPDATE saved_search_subscriptions ss
+SET last_file_id = COALESCE((SELECT MAX(f.id) FROM files f WHERE f.uploaded_at <= ss.seen_until), 0);
+
+ALTER TABLE saved_search_subscriptions DROP COLUMN IF EXISTS seen_until;
@@ -0,0 +1,15 @@
+-- ============================
+-- Saved search subscriptions : upload-time watermark
+-- ============================
+-- file ids are handed out before the upload commits, so a file committing late could land below an
+-- id watermark & never be notified. Subscriptions now remember the upload time checked up to instead,
+-- the notifier staying a safety lag behind NOW() so in-flight uploads are picked up on a later pass :
+ALTER TABLE saved_search_subscriptions ADD COLUMN IF NOT EXISTS seen_until TIMESTAMP;
+
+UPDATE saved_search_subscriptions ss
+SET seen_until = COALESCE((SELECT f.uploaded_at FROM files f WHERE f.id = ss.last_file_id), ss.created_at);
+
+ALTER TABLE saved_search_subscriptions
+    ALTER COLUMN seen_until SET NOT NULL,
+    ALTER COLUMN seen_until SET DEFAULT CURRENT_TIMESTAMP,
+    DROP COLUMN IF EXISTS last_file_id;
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"backend/internal/db"
+	"backend/internal/middleware"
+	"backend/internal/models"
+	"backend/internal/services"
+	"backend/internal/utils"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ForgotPasswordHandler - mails a reset link if the email belongs to an account :
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// looking up the account :
+	user, err := models.GetUserByEmail(req.Email)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		// token, mail & audit happen in the background, so a registered email doesn't answer slower :
+		e := auditActor(r, services.AuditEvent{
+			Action: services.AuditPasswordResetRequest, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditSuccess,
+		})
+		go func(userID int, email string) {
+			if err := services.SendPasswordResetEmail(userID, email); err != nil {
+				log.Printf("⚠️ reset mail to user %d failed: %v", userID, err)
+			}
+			services.RecordAudit(e)
+		}(user.ID, user.Email)
+	}
+
+	// same response (and timing) either way, so emails can't be enumerated :
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+		"msg":    "if the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetPasswordHandler - sets a new password using a reset token :
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" || req.Password == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// checking the token before burning it, so a rejected pswd can be retried :
+	userID, err := services.LookupUserToken(req.Token, services.TokenPurposePasswordReset)
+	if err == services.ErrInvalidToken {
+		audit(r, services.AuditEvent{Action: services.AuditPasswordReset, Outcome: services.AuditFailure},
+			map[string]interface{}{"reason": "invalid_token"})
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// applying the pswd policy :
+	if fieldErrs := services.ValidatePassword(req.Password, user.Username, user.Email); len(fieldErrs) > 0 {
+		writeValidationErrors(w, fieldErrs)
+		return
+	}
+
+	// redeeming the token :
+	if _, err := services.ConsumeUserToken(req.Token, services.TokenPurposePasswordReset); err == services.ErrInvalidToken {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// hashing & saving the new pswd :
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+	// a reset link proves ownership of the mailbox too :
+	_, err = db.DB.Exec(
+		`UPDATE users SET password=$1, email_verified=TRUE WHERE id=$2`,
+		string(hashed), userID,
+	)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	// whoever knew the old pswd gets logged out everywhere :
+	if _, err := services.RevokeSessions(userID); err != nil {
+		log.Printf("❌ revoking sessions of user %d after reset failed: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	audit(r, services.AuditEvent{
+		ActorID: &userID, ActorUsername: user.Username,
+		Action: services.AuditPasswordReset, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditSuccess,
+	}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+		"msg":    "password updated",
+	})
+}
+
+// ResendVerificationHandler - mails a fresh verification link to the logged-in user :
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil || user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	// nothing to do if already verified :
+	if !user.EmailVerified {
+		if err := services.SendVerificationEmail(user.ID, user.Email); err != nil {
+			log.Printf("⚠️ verification mail to user %d failed: %v", user.ID, err)
+			http.Error(w, "Could not send verification email", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"email_verified": user.EmailVerified,
+	})
+}
+
+// VerifyEmailHandler - confirms an email address using a verification token :
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// redeeming the token :
+	userID, err := services.ConsumeUserToken(req.Token, services.TokenPurposeEmailVerify)
+	if err == services.ErrInvalidToken {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = db.DB.Exec(`UPDATE users SET email_verified=TRUE WHERE id=$1`, userID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	audit(r, services.AuditEvent{
+		ActorID: &userID, Action: services.AuditEmailVerify, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditSuccess,
+	}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+		"msg":    "email verified",
+	})
+}
+
+// ChangePasswordHandler - changes the logged-in user's pswd after re-checking the current one :
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// verifying the current pswd :
+	var username, email, hashedPwd string
+	err := db.DB.QueryRow(
+		`SELECT username, email, password FROM users WHERE id=$1`, userID,
+	).Scan(&username, &email, &hashedPwd)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashedPwd), []byte(req.CurrentPassword)) != nil {
+		audit(r, services.AuditEvent{
+			Action: services.AuditPasswordChange, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditFailure,
+		}, map[string]interface{}{"reason": "bad_current_password"})
+		writeValidationErrors(w, []utils.FieldError{{Field: "current_password", Message: "current password is incorrect"}})
+		return
+	}
+
+	// applying the pswd policy :
+	fieldErrs := services.ValidatePassword(req.NewPassword, username, email)
+	if req.NewPassword == req.CurrentPassword {
+		fieldErrs = append(fieldErrs, utils.FieldError{Field: "new_password", Message: "new password must differ from the current one"})
+	}
+	for i := range fieldErrs {
+		if fieldErrs[i].Field == "password" {
+			fieldErrs[i].Field = "new_password"
+		}
+	}
+	if len(fieldErrs) > 0 {
+		writeValidationErrors(w, fieldErrs)
+		return
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.DB.Exec(`UPDATE users SET password=$1 WHERE id=$2`, string(hashed), userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// logging out every other session, this one gets a token of the new session version :
+	if _, err := services.RevokeSessions(userID); err != nil {
+		log.Printf("❌ revoking sessions of user %d after pswd change failed: %v", userID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var role string
+	var session int
+	if err := db.DB.QueryRow(`SELECT role, session_version FROM users WHERE id=$1`, userID).Scan(&role, &session); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	token, err := services.GenerateJWT(userID, username, role, session)
+	if err != nil {
+		http.Error(w, "Could not issue token", http.StatusInternalServerError)
+		return
+	}
+	setTokenCookie(w, token)
+	audit(r, services.AuditEvent{
+		Action: services.AuditPasswordChange, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditSuccess,
+	}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+		"msg":    "password changed",
+	})
+}
+
+// writeValidationErrors sends 422 with the list of rejected fields :
+func writeValidationErrors(w http.ResponseWriter, errs []utils.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "validation failed",
+		"fields": errs,
+	})
+}
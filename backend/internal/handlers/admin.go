@@ -3,8 +3,8 @@ package handlers
 import (
 	"backend/internal/db"
 	"backend/internal/middleware"
+	"backend/internal/services"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
@@ -17,80 +17,44 @@ func AdminFilesHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // checking for admin : 
-    role, ok := r.Context().Value(middleware.ContextUserRoleKey).(string)
-    if !ok || role != "admin" {
-        http.Error(w, "Forbidden: Admins only", http.StatusForbidden)
+    // parsing filter query params (shared with the user & public listings) :
+    q := r.URL.Query()
+    filter, err := services.ParseFileQuery(q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
         return
     }
 
-    // parsing filter query params :
-    q := r.URL.Query()
-    search := q.Get("search")
-    mimeType := q.Get("mimeType")
-    minSize := q.Get("minSize")
-    maxSize := q.Get("maxSize")
-    startDate := q.Get("startDate")
-    endDate := q.Get("endDate")
-    uploader := q.Get("uploader")
-
-    // dynamic SQL query with filters :
-    query := `
-        SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, f.is_public,
-		u.username 
-        FROM files f 
-        JOIN users u ON f.user_id = u.id
-		WHERE 1=1
-    `
-    args := []interface{}{}
-    argPos := 1
-
-    if search != "" {
-        query += fmt.Sprintf(" AND f.filename ILIKE $%d", argPos)
-        args = append(args, "%"+search+"%")
-        argPos++
-    }
-    if mimeType != "" {
-        query += fmt.Sprintf(" AND f.mime_type = $%d", argPos)
-        args = append(args, mimeType)
-        argPos++
-    }
-    if minSize != "" {
-        if kb, err := strconv.ParseInt(minSize, 10, 64); err == nil {
-            bytes := kb * 1024
-            query += fmt.Sprintf(" AND f.size >= $%d", argPos)
-            args = append(args, bytes)
-            argPos++
-        }
+    // paging & sorting :
+    page, err := services.ParseFilePage(q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
     }
-    if maxSize != "" {
-        if kb, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
-            bytes := kb * 1024
-            query += fmt.Sprintf(" AND f.size <= $%d", argPos)
-            args = append(args, bytes)
-            argPos++
+
+    // dynamic SQL conditions with filters :
+    where, args := filter.Where(nil)
+    where = "TRUE" + where
+
+    // totals over every match, only for the first page :
+    var totals *services.FileTotals
+    if page.First() {
+        if totals, err = services.CountFiles(where, args); err != nil {
+            http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
+            return
         }
     }
-    if startDate != "" {
-        query += fmt.Sprintf(" AND f.uploaded_at >= $%d", argPos)
-        args = append(args, startDate)
-        argPos++
-    }
-    if endDate != "" {
-        query += fmt.Sprintf(" AND f.uploaded_at <= $%d", argPos)
-        args = append(args, endDate)
-        argPos++
-    }
-    if uploader != "" {
-        query += fmt.Sprintf(" AND u.username ILIKE $%d", argPos)
-        args = append(args, "%"+uploader+"%")
-        argPos++
-    }
 
-    query += " ORDER BY f.uploaded_at DESC"
+    keyset, pageArgs := page.Where(args)
+    query := `
+        SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, f.is_public,
+		u.username, f.quarantined_at IS NOT NULL, f.download_count
+        FROM files f 
+        JOIN users u ON f.user_id = u.id
+		WHERE ` + where + keyset + page.OrderLimit()
 
     // executing query :
-    rows, err := db.DB.Query(query, args...)
+    rows, err := db.DB.Query(query, pageArgs...)
     if err != nil {
         http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
         return
@@ -98,8 +62,8 @@ func AdminFilesHandler(w http.ResponseWriter, r *http.Request) {
     defer rows.Close()
 
     // processing results : 
-    var files []map[string]interface{}
-    var originalSize, dedupSize int64
+    files := make([]map[string]interface{}, 0)
+    var keys []services.FileKey
 
     for rows.Next() {
         var id int
@@ -109,8 +73,10 @@ func AdminFilesHandler(w http.ResponseWriter, r *http.Request) {
         var isMaster bool
         var username string
 		var is_public bool
+		var quarantined bool
+		var downloads int
 
-        if err := rows.Scan(&id, &filename, &size, &uploadedAt, &isMaster,&is_public, &username); err != nil {
+        if err := rows.Scan(&id, &filename, &size, &uploadedAt, &isMaster,&is_public, &username, &quarantined, &downloads); err != nil {
             http.Error(w, "DB scan error: "+err.Error(), http.StatusInternalServerError)
             return
         }
@@ -123,25 +89,15 @@ func AdminFilesHandler(w http.ResponseWriter, r *http.Request) {
             "deduplicated": isMaster,
             "uploader":     username,
 			"is_public": is_public,
+			"quarantined": quarantined,
+			"downloads": downloads,
         })
-
-        // adding sizes : 
-        originalSize += size
-        if isMaster {
-            dedupSize += size
-        }
+        keys = append(keys, services.FileKey{ID: id, Filename: filename, Size: size, UploadedAt: uploadedAt, Downloads: downloads})
     }
 
     // sending  response :
-    resp := map[string]interface{}{
-        "files":        files,
-        "dedupSize":    dedupSize,
-        "originalSize": originalSize,
-        "saveSize":     originalSize - dedupSize,
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(resp)
+    n, next := page.Next(len(files), func(i int) services.FileKey { return keys[i] })
+    writeFilePage(w, files[:n], page, next, totals)
 }
 
 
@@ -152,14 +108,26 @@ func MakeAdminHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // checking role from context : 
-    role, ok := r.Context().Value(middleware.ContextUserRoleKey).(string)
-    if !ok || role != "admin" {
-        http.Error(w, "Forbidden: Admins only", http.StatusForbidden)
+    // parsing request body for filters  :
+    var req struct {
+        Username string `json:"username"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+        http.Error(w, "Invalid input", http.StatusBadRequest)
         return
     }
 
-    // parsing request body for filters  :
+    setUserRole(w, r, req.Username, "admin")
+}
+
+// MakeUserHandler – change user role to normal user
+func MakeUserHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    // parsing request body : 
     var req struct {
         Username string `json:"username"`
     }
@@ -168,57 +136,128 @@ func MakeAdminHandler(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // updating entry in DB :
-    _, err := db.DB.Exec("UPDATE users SET role = $1 WHERE username = $2", "admin", req.Username)
+    setUserRole(w, r, req.Username, "user")
+}
+
+// AdminSetUserRoleHandler – assign any existing role to a user
+func AdminSetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    // parsing request body :
+    var req struct {
+        Username string `json:"username"`
+        Role     string `json:"role"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" || req.Role == "" {
+        http.Error(w, "Invalid input", http.StatusBadRequest)
+        return
+    }
+
+    setUserRole(w, r, req.Username, req.Role)
+}
+
+// setUserRole updates a user's role after checking the caller isn't granting more than they hold
+func setUserRole(w http.ResponseWriter, r *http.Request, username string, newRole string) {
+    // role must exist :
+    exists, err := services.RoleExists(newRole)
     if err != nil {
         http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
         return
     }
+    if !exists {
+        http.Error(w, "Role not found", http.StatusNotFound)
+        return
+    }
+
+    // no privilege escalation :
+    callerRole, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+    covers, err := services.RoleCovers(callerRole, newRole)
+    if err != nil {
+        http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if !covers {
+        audit(r, services.AuditEvent{Action: services.AuditUserRoleChange, TargetType: "user", TargetID: username, Outcome: services.AuditDenied},
+            map[string]interface{}{"new_role": newRole, "reason": "escalation"})
+        http.Error(w, "Forbidden: cannot grant a role with permissions you don't have", http.StatusForbidden)
+        return
+    }
+
+    // updating entry in DB (never demoting the last active admin) :
+    targetID, oldRole, err := services.SetUserRole(username, newRole)
+    if err == services.ErrUserNotFound {
+        http.Error(w, "User not found", http.StatusNotFound)
+        return
+    } else if err == services.ErrLastAdmin {
+        http.Error(w, err.Error(), http.StatusConflict)
+        return
+    } else if err != nil {
+        http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    audit(r, services.AuditEvent{Action: services.AuditUserRoleChange, TargetType: "user", TargetID: strconv.Itoa(targetID), Outcome: services.AuditSuccess},
+        map[string]interface{}{"username": username, "old_role": oldRole, "new_role": newRole})
 
     // response : 
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(map[string]string{
         "status":   "ok",
-        "username": req.Username,
-        "newRole":  "admin",
+        "username": username,
+        "newRole":  newRole,
     })
 }
 
-// MakeUserHandler – change user role to normal user
-func MakeUserHandler(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodPost {
-        http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+// AdminLockoutsHandler – lists tracked failed-login keys and active lockouts
+func AdminLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
         return
     }
 
-    // checking for admin role : 
-    role, ok := r.Context().Value(middleware.ContextUserRoleKey).(string)
-    if !ok || role != "admin" {
-        http.Error(w, "Forbidden: Admins only", http.StatusForbidden)
+    lockouts := services.ListLockouts()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "lockouts": lockouts,
+        "total":    len(lockouts),
+    })
+}
+
+// AdminClearLockoutHandler – clears one key ("ip:<addr>" / "user:<name>") or all of them
+func AdminClearLockoutHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
         return
     }
 
-    // parsing request body : 
+    // parsing request body :
     var req struct {
-        Username string `json:"username"`
+        Key string `json:"key"`
+        All bool   `json:"all"`
     }
-    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Key == "" && !req.All) {
         http.Error(w, "Invalid input", http.StatusBadRequest)
         return
     }
 
-    // updating entry in DB : 
-    _, err := db.DB.Exec("UPDATE users SET role = $1 WHERE username = $2", "user", req.Username)
-    if err != nil {
-        http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+    cleared := 0
+    if req.All {
+        cleared = services.ClearAllLockouts()
+    } else if services.ClearLockout(req.Key) {
+        cleared = 1
+    } else {
+        http.Error(w, "Lockout not found", http.StatusNotFound)
         return
     }
+    audit(r, services.AuditEvent{Action: services.AuditLockoutClear, TargetType: "lockout", TargetID: req.Key, Outcome: services.AuditSuccess},
+        map[string]interface{}{"all": req.All, "cleared": cleared})
 
-    // sending response : 
     w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(map[string]string{
-        "status":   "ok",
-        "username": req.Username,
-        "newRole":  "user",
+    json.NewEncoder(w).Encode(map[string]interface{}{
+        "status":  "ok",
+        "cleared": cleared,
     })
 }
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// annotatableFile loads the {id} file and checks the caller may annotate it (same rule as
+// modifying it), writing the HTTP error otherwise :
+func annotatableFile(w http.ResponseWriter, r *http.Request) (*services.FileMeta, int, bool) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+	id := mux.Vars(r)["id"]
+	meta, status, err := lookupFile(id)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return nil, 0, false
+	}
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	if !services.CanModifyFile(userID, role, meta) {
+		audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: id, Outcome: services.AuditDenied}, nil)
+		http.Error(w, "Not allowed", http.StatusForbidden)
+		return nil, 0, false
+	}
+	return meta, userID, true
+}
+
+// annotationError writes the HTTP error for a failed annotation change :
+func annotationError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrInvalidTag, services.ErrInvalidMetadataKey, services.ErrInvalidMetadata, services.ErrDescriptionTooLong:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrTooManyTags, services.ErrTooManyMetadata:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeAnnotations answers with the file's current description, tags & metadata :
+func writeAnnotations(w http.ResponseWriter, fileID int) {
+	meta, err := services.GetFileByID(fileID)
+	if err == nil && meta != nil {
+		err = services.LoadFileAnnotations(meta)
+	}
+	if err != nil || meta == nil {
+		http.Error(w, "Failed to load annotations", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":          meta.ID,
+		"description": meta.Description,
+		"tags":        meta.Tags,
+		"metadata":    meta.Metadata,
+	})
+}
+
+// FileDescriptionHandler – sets (or clears with "") the file description
+func FileDescriptionHandler(w http.ResponseWriter, r *http.Request) {
+	meta, _, ok := annotatableFile(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := services.SetFileDescription(meta.ID, req.Description); err != nil {
+		annotationError(w, err)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"change": "description"})
+	writeAnnotations(w, meta.ID)
+}
+
+// AddFileTagsHandler – attaches tags to a file ({"tags": ["invoice", "2025"]})
+func AddFileTagsHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, ok := annotatableFile(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tags) == 0 {
+		http.Error(w, "Invalid input, expected {\"tags\": [...]}", http.StatusBadRequest)
+		return
+	}
+	if len(req.Tags) > services.MaxTagsPerFile {
+		annotationError(w, services.ErrTooManyTags)
+		return
+	}
+	tags := make([]string, 0, len(req.Tags))
+	for _, t := range req.Tags {
+		tag, err := services.NormalizeTag(t)
+		if err != nil {
+			annotationError(w, err)
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	if _, err := services.AddFileTags(meta.ID, tags, userID); err != nil {
+		annotationError(w, err)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"change": "tags_add", "tags": tags})
+	writeAnnotations(w, meta.ID)
+}
+
+// RemoveFileTagHandler – detaches one tag from a file
+func RemoveFileTagHandler(w http.ResponseWriter, r *http.Request) {
+	meta, _, ok := annotatableFile(w, r)
+	if !ok {
+		return
+	}
+	tag, err := services.NormalizeTag(mux.Vars(r)["tag"])
+	if err != nil {
+		annotationError(w, err)
+		return
+	}
+	removed, err := services.RemoveFileTag(meta.ID, tag)
+	if err != nil {
+		annotationError(w, err)
+		return
+	}
+	if !removed {
+		http.Error(w, "Tag not set on this file", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"change": "tag_remove", "tag": tag})
+	writeAnnotations(w, meta.ID)
+}
+
+// SetFileMetadataHandler – sets one metadata key ({"value": "..."})
+func SetFileMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, ok := annotatableFile(w, r)
+	if !ok {
+		return
+	}
+	key, err := services.NormalizeMetadataKey(mux.Vars(r)["key"])
+	if err != nil {
+		annotationError(w, err)
+		return
+	}
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if err := services.SetFileMetadata(meta.ID, key, req.Value, userID); err != nil {
+		annotationError(w, err)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"change": "metadata_set", "key": key})
+	writeAnnotations(w, meta.ID)
+}
+
+// DeleteFileMetadataHandler – removes one metadata key
+func DeleteFileMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	meta, _, ok := annotatableFile(w, r)
+	if !ok {
+		return
+	}
+	key, err := services.NormalizeMetadataKey(mux.Vars(r)["key"])
+	if err != nil {
+		annotationError(w, err)
+		return
+	}
+	removed, err := services.DeleteFileMetadata(meta.ID, key)
+	if err != nil {
+		annotationError(w, err)
+		return
+	}
+	if !removed {
+		http.Error(w, "Metadata key not set on this file", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditFileAnnotate, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"change": "metadata_remove", "key": key})
+	writeAnnotations(w, meta.ID)
+}
+
+// TagSuggestionsHandler – tag autocomplete (?prefix=&limit=) over the files the caller can see
+func TagSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	q := r.URL.Query()
+	limit := 10
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 50 {
+			http.Error(w, "Invalid limit (1-50)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	tags, err := services.SuggestTags(userID, q.Get("prefix"), limit)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+}
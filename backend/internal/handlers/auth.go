@@ -1,15 +1,23 @@
 package handlers
 
 import (
+	"backend/internal/config"
 	"backend/internal/db"
 	"backend/internal/middleware"
 	"backend/internal/models"
 	"backend/internal/services"
 	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"backend/internal/utils"
+
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -40,17 +48,47 @@ func RefershHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// collecting the role's permissions for the UI :
+	perms := make([]string, 0)
+	if set, err := services.PermissionsForRole(user.Role); err == nil {
+		for p := range set {
+			perms = append(perms, p)
+		}
+		sort.Strings(perms)
+	}
+
 	// return safe user info :
 	resp := map[string]interface{}{
-		"id":       user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"role":     user.Role,
+		"id":             user.ID,
+		"username":       user.Username,
+		"email":          user.Email,
+		"role":           user.Role,
+		"email_verified": user.EmailVerified,
+		"permissions":    perms,
+	}
+
+	// banner info while an admin is acting as this user :
+	if impID, ok := r.Context().Value(middleware.ContextImpersonatorIDKey).(int); ok {
+		impName, _ := r.Context().Value(middleware.ContextImpersonatorNameKey).(string)
+		allow, _ := r.Context().Value(middleware.ContextAllowDestructiveKey).(bool)
+		resp["impersonation"] = map[string]interface{}{
+			"active":                true,
+			"impersonator_id":       impID,
+			"impersonator_username": impName,
+			"allow_destructive":     allow,
+		}
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// signupRequest is the accepted signup payload :
+type signupRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
 // Signup handler - Registers new user :
 func SignupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -58,12 +96,28 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
+	var u signupRequest
 	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
+	u.Username = strings.TrimSpace(u.Username)
+	u.Email = strings.TrimSpace(u.Email)
+
+	// validating all fields :
+	var fieldErrs []utils.FieldError
+	if msg := utils.ValidateUsername(u.Username); msg != "" {
+		fieldErrs = append(fieldErrs, utils.FieldError{Field: "username", Message: msg})
+	}
+	if msg := utils.ValidateEmail(u.Email); msg != "" {
+		fieldErrs = append(fieldErrs, utils.FieldError{Field: "email", Message: msg})
+	}
+	fieldErrs = append(fieldErrs, services.ValidatePassword(u.Password, u.Username, u.Email)...)
+	if len(fieldErrs) > 0 {
+		writeValidationErrors(w, fieldErrs)
+		return
+	}
 
 	// hasing the pswd :
 	hashed, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
@@ -73,15 +127,29 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// database entry :
-	_, err = db.DB.Exec(
-		"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4)",
+	var newID int
+	err = db.DB.QueryRow(
+		"INSERT INTO users (username, email, password, role) VALUES ($1, $2, $3, $4) RETURNING id",
 		u.Username, u.Email, string(hashed), "user",
-	)
-	if err != nil {
-		http.Error(w, "User already exists or DB error: "+err.Error(), http.StatusBadRequest)
+	).Scan(&newID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		http.Error(w, "Username or email already taken", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("❌ signup insert failed: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	// sending verification mail (signup still succeeds if mailer fails) :
+	if err := services.SendVerificationEmail(newID, u.Email); err != nil {
+		log.Printf("⚠️ verification mail to user %d failed: %v", newID, err)
+	}
+	audit(r, services.AuditEvent{
+		ActorID: &newID, ActorUsername: u.Username,
+		Action: services.AuditSignup, TargetType: "user", TargetID: strconv.Itoa(newID), Outcome: services.AuditSuccess,
+	}, nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "ok",
@@ -96,35 +164,83 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
+	var u struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
 	err := json.NewDecoder(r.Body).Decode(&u)
 	if err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
+	// refusing while the IP or username is locked out, otherwise counting the attempt as failed
+	// until the pswd checks out (parallel guesses can't get past the check together) :
+	ip := utils.ClientIP(r)
+	if wait := services.ReserveLoginAttempt(ip, u.Username); wait > 0 {
+		audit(r, services.AuditEvent{
+			ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditDenied,
+		}, map[string]interface{}{"reason": "locked_out"})
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return
+	}
+
 	// getting hashed password from DB :
 	var id int
 	var hashedPwd string
 	var role string
-	err = db.DB.QueryRow("SELECT id, password, role FROM users WHERE username=$1", u.Username).Scan(&id, &hashedPwd, &role)
+	var emailVerified, isActive bool
+	var sessionVersion int
+	err = db.DB.QueryRow(
+		"SELECT id, password, role, email_verified, is_active, session_version FROM users WHERE username=$1", u.Username,
+	).Scan(&id, &hashedPwd, &role, &emailVerified, &isActive, &sessionVersion)
 	if err == sql.ErrNoRows {
-		http.Error(w, "User not found", http.StatusUnauthorized)
+		// same bcrypt cost & same answer as a wrong pswd :
+		services.CompareDummyPassword(u.Password)
+		audit(r, services.AuditEvent{
+			ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditFailure,
+		}, map[string]interface{}{"reason": "unknown_user"})
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	} else if err != nil {
-		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("❌ login lookup failed: %v", err)
+		services.CancelLoginAttempt(ip, u.Username)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	// comparing the hashes :
 	err = bcrypt.CompareHashAndPassword([]byte(hashedPwd), []byte(u.Password))
 	if err != nil {
-		http.Error(w, "Invalid password compare hash and pswd", http.StatusUnauthorized)
+		audit(r, services.AuditEvent{
+			ActorID: &id, ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditFailure,
+		}, map[string]interface{}{"reason": "bad_password"})
+		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	services.RecordLoginSuccess(ip, u.Username)
+
+	// blocking deactivated accounts (only revealed once the pswd is right) :
+	if !isActive {
+		audit(r, services.AuditEvent{
+			ActorID: &id, ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditDenied,
+		}, map[string]interface{}{"reason": "account_deactivated"})
+		http.Error(w, "Account deactivated", http.StatusForbidden)
+		return
+	}
+
+	// blocking unverified accounts when configured :
+	if config.AppConfig.RequireEmailVerification && !emailVerified {
+		audit(r, services.AuditEvent{
+			ActorID: &id, ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditDenied,
+		}, map[string]interface{}{"reason": "email_not_verified"})
+		http.Error(w, "Email not verified", http.StatusForbidden)
 		return
 	}
 
 	// generate JWT :
-	token, err := services.GenerateJWT(id, u.Username, role)
+	token, err := services.GenerateJWT(id, u.Username, role, sessionVersion)
 	if err != nil {
 		http.Error(w, "failed to generate the JWT ", http.StatusExpectationFailed)
 		return
@@ -141,6 +257,13 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		SameSite: http.SameSiteLaxMode,
 	})
 
+	audit(r, services.AuditEvent{
+		ActorID: &id, ActorUsername: u.Username, Action: services.AuditLogin, Outcome: services.AuditSuccess,
+	}, nil)
+	if _, err := db.DB.Exec(`UPDATE users SET last_login = NOW() WHERE id=$1`, id); err != nil {
+		log.Printf("⚠️ last_login update for user %d failed: %v", id, err)
+	}
+
 	// sucess response :
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -151,6 +274,16 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 // Logout handler - clearing JWT cookie :
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	// the route isn't behind AuthMiddleware, so the actor is read from the cookie if still valid :
+	if cookie, err := r.Cookie("token"); err == nil {
+		if claims, err := services.ParseJWT(cookie.Value); err == nil {
+			audit(r, services.AuditEvent{
+				ActorID: &claims.UserID, ActorUsername: claims.Username,
+				Action: services.AuditLogout, Outcome: services.AuditSuccess,
+			}, nil)
+		}
+	}
+
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    "",
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"backend/internal/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminBulkFilesHandler – runs delete / make_private / transfer / (un)quarantine on many files,
+// picked by ids or by the listing filters (services.FileQuery). dry_run only returns what would be affected.
+func AdminBulkFilesHandler(w http.ResponseWriter, r *http.Request) {
+	var req services.BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); errors.Is(err, services.ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	if req.DryRun {
+		preview, err := services.PreviewBulkAction(req)
+		if !bulkError(w, err) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+
+	job, err := services.StartBulkJob(req, auditActor(r, services.AuditEvent{}))
+	if !bulkError(w, err) {
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditBulkJob, TargetType: "bulk_job", TargetID: strconv.FormatInt(job.ID, 10), Outcome: services.AuditSuccess},
+		map[string]interface{}{"action": req.Action, "total": job.Total, "by_filter": len(req.IDs) == 0})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// bulkError writes the HTTP error for err, reporting whether the caller may go on :
+func bulkError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case services.ErrInvalidBulkAction, services.ErrBulkNoSelection, services.ErrInvalidTarget:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrBulkTooMany:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
+
+// AdminBulkJobsHandler – latest bulk jobs (?limit=&offset=)
+func AdminBulkJobsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 20
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "Invalid limit (1-100)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	jobs, err := services.ListBulkJobs(limit, offset)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":   jobs,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// AdminBulkJobHandler – progress of one bulk job
+func AdminBulkJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+	job, err := services.GetBulkJob(id)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// commentableFile loads the {id} file and checks the caller may read it (anyone who can read a
+// file can see and post comments), writing the HTTP error otherwise :
+func commentableFile(w http.ResponseWriter, r *http.Request) (*services.FileMeta, int, string, bool) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, 0, "", false
+	}
+	meta, status, err := lookupFile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return nil, 0, "", false
+	}
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	if !services.CanReadFile(userID, role, meta) {
+		http.Error(w, "Forbidden: private file", http.StatusForbidden)
+		return nil, 0, "", false
+	}
+	return meta, userID, role, true
+}
+
+// fileComment loads the {commentId} comment of the file (404 if it's on another file) :
+func fileComment(w http.ResponseWriter, r *http.Request, meta *services.FileMeta) (*services.Comment, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["commentId"])
+	if err != nil {
+		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		return nil, false
+	}
+	c, err := services.GetComment(id)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if c == nil || c.FileID != meta.ID {
+		http.Error(w, services.ErrCommentNotFound.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return c, true
+}
+
+// isCommentAuthor reports whether userID wrote the comment :
+func isCommentAuthor(c *services.Comment, userID int) bool {
+	return c.AuthorID != nil && *c.AuthorID == userID
+}
+
+// commentError writes the HTTP error for a failed comment change :
+func commentError(w http.ResponseWriter, err error) {
+	switch err {
+	case services.ErrCommentEmpty, services.ErrCommentTooLong, services.ErrCommentParent:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrCommentNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrCommentDeleted:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// ListFileCommentsHandler – the file's comment threads, oldest first
+func ListFileCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	meta, _, _, ok := commentableFile(w, r)
+	if !ok {
+		return
+	}
+	threads, err := services.ListFileComments(meta.ID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	count, err := services.CountFileComments(meta.ID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"comments": threads, "count": count})
+}
+
+// AddFileCommentHandler – posts a comment or a reply ({"body": "...", "parent_id": 4})
+func AddFileCommentHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, _, ok := commentableFile(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Body     string `json:"body"`
+		ParentID *int   `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	c, err := services.AddComment(meta.ID, userID, req.ParentID, req.Body)
+	if err != nil {
+		commentError(w, err)
+		return
+	}
+
+	// mentions, then the feed of the file's owner / team and the thread's author :
+	services.NotifyMentions(meta, c, "")
+	recipients := services.ActivityAudience(meta.TeamID, meta.UploaderID)
+	if c.ParentID != nil {
+		if parent, err := services.GetComment(*c.ParentID); err == nil && parent != nil && parent.AuthorID != nil {
+			recipients = append(recipients, *parent.AuthorID)
+		}
+	}
+	services.RecordActivity(services.Activity{Kind: services.ActivityComment, ActorID: &userID, FileID: &meta.ID, Filename: meta.Filename,
+		TeamID: meta.TeamID, Details: map[string]interface{}{"comment_id": c.ID}}, recipients)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// EditFileCommentHandler – replaces a comment's body (author only), the old one goes to its history
+func EditFileCommentHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, _, ok := commentableFile(w, r)
+	if !ok {
+		return
+	}
+	c, ok := fileComment(w, r, meta)
+	if !ok {
+		return
+	}
+	if !isCommentAuthor(c, userID) {
+		http.Error(w, "Forbidden: not the comment's author", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	updated, err := services.EditComment(c.ID, userID, req.Body)
+	if err != nil {
+		commentError(w, err)
+		return
+	}
+	services.NotifyMentions(meta, updated, c.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteFileCommentHandler – deletes a comment (its author, or whoever may modify the file)
+func DeleteFileCommentHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, role, ok := commentableFile(w, r)
+	if !ok {
+		return
+	}
+	c, ok := fileComment(w, r, meta)
+	if !ok {
+		return
+	}
+	commentID := strconv.Itoa(c.ID)
+	if !isCommentAuthor(c, userID) && !services.CanModifyFile(userID, role, meta) {
+		audit(r, services.AuditEvent{Action: services.AuditCommentDelete, TargetType: "comment", TargetID: commentID, Outcome: services.AuditDenied}, nil)
+		http.Error(w, "Forbidden: not the comment's author", http.StatusForbidden)
+		return
+	}
+	if err := services.DeleteComment(c.ID, userID); err != nil {
+		commentError(w, err)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditCommentDelete, TargetType: "comment", TargetID: commentID, Outcome: services.AuditSuccess},
+		map[string]interface{}{"file_id": meta.ID, "author_id": c.AuthorID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CommentHistoryHandler – a comment's previous bodies (its author, or whoever may modify the file)
+func CommentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	meta, userID, role, ok := commentableFile(w, r)
+	if !ok {
+		return
+	}
+	c, ok := fileComment(w, r, meta)
+	if !ok {
+		return
+	}
+	if !isCommentAuthor(c, userID) && !services.CanModifyFile(userID, role, meta) {
+		http.Error(w, "Forbidden: not the comment's author", http.StatusForbidden)
+		return
+	}
+	history, err := services.CommentHistory(c.ID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"comment": c, "history": history})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fileForOwner loads the {id} file and checks the caller may see its access history :
+// whoever can manage the file (uploader, team owners/admins, files.manage.any) or files.read.any.
+func fileForOwner(w http.ResponseWriter, r *http.Request) (*services.FileMeta, bool) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+
+	meta, status, err := lookupFile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return nil, false
+	}
+	if !services.CanModifyFile(userID, role, meta) && !services.HasPermission(role, services.PermFilesReadAny) {
+		http.Error(w, "Forbidden: not file owner", http.StatusForbidden)
+		return nil, false
+	}
+	return meta, true
+}
+
+// FileAccessHandler - who downloaded a file, when, from where (?limit=&offset=) :
+func FileAccessHandler(w http.ResponseWriter, r *http.Request) {
+	meta, ok := fileForOwner(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	list, total, err := services.ListFileAccess(meta.ID, limit, offset)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file_id":        meta.ID,
+		"download_count": meta.DownloadCount,
+		"access":         list,
+		"total":          total,
+	})
+}
+
+// FileStatsHandler - downloads of a file per time bucket (?bucket=hour|day|week|month&from=&to=) :
+func FileStatsHandler(w http.ResponseWriter, r *http.Request) {
+	meta, ok := fileForOwner(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	bucket := q.Get("bucket")
+	if bucket == "" {
+		bucket = "day"
+	}
+
+	// default window : last 30 days :
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid from", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid to", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	buckets, err := services.FileDownloadStats(meta.ID, bucket, from, to)
+	if err == services.ErrInvalidStatsArg {
+		http.Error(w, "bucket must be hour, day, week or month", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"file_id": meta.ID,
+		"bucket":  bucket,
+		"from":    from,
+		"to":      to,
+		"buckets": buckets,
+	})
+}
+
+// AdminTopFilesHandler - top-N files (?by=downloads|size|shared&limit=10&days=) :
+func AdminTopFilesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	by := q.Get("by")
+	if by == "" {
+		by = "downloads"
+	}
+
+	limit := 10
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// window in days, 0 = all time ("shared" always needs the history table, all of it by default) :
+	var since *time.Time
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		if n > 0 {
+			t := time.Now().AddDate(0, 0, -n)
+			since = &t
+		}
+	}
+
+	files, err := services.TopFiles(by, limit, since)
+	if err == services.ErrInvalidStatsArg {
+		http.Error(w, "by must be downloads, size or shared", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"by":    by,
+		"files": files,
+	})
+}
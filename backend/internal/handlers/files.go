@@ -10,13 +10,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/mux"
+	"github.com/lib/pq"
 )
 
 // file handler - gets user's own files :
@@ -33,73 +37,81 @@ func FilesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//  parsing query params for filters :
+	//  parsing query params for filters (shared with the admin & public listings) :
 	q := r.URL.Query()
-	search := q.Get("search")
-	mimeType := q.Get("mimeType")
-	minSize := q.Get("minSize")
-	maxSize := q.Get("maxSize")
-	startDate := q.Get("startDate")
-	endDate := q.Get("endDate")
-	uploader := q.Get("uploader")
-
-	// writing dynamic SQL query with filters :
-	query := `
-		SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, f.is_public,
-		u.username 
-		FROM files f 
-		JOIN users u ON f.user_id = u.id
-		WHERE f.user_id = $1
-	`
-	args := []interface{}{userID}
-	argPos := 2
-
-	if search != "" {
-		query += fmt.Sprintf(" AND f.filename ILIKE $%d", argPos)
-		args = append(args, "%"+search+"%")
-		argPos++
-	}
-	if mimeType != "" {
-		query += fmt.Sprintf(" AND f.mime_type = $%d", argPos)
-		args = append(args, mimeType)
-		argPos++
-	}
-	if minSize != "" {
-		if kb, err := strconv.ParseInt(minSize, 10, 64); err == nil {
-			bytes := kb * 1024
-			query += fmt.Sprintf(" AND f.size >= $%d", argPos)
-			args = append(args, bytes)
-			argPos++
+
+	// smart folder : the saved search's filters & space replace the request's, paging stays :
+	if v := q.Get("saved_search"); v != "" {
+		savedID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid saved_search", http.StatusBadRequest)
+			return
 		}
-	}
-	if maxSize != "" {
-		if kb, err := strconv.ParseInt(maxSize, 10, 64); err == nil {
-			bytes := kb * 1024
-			query += fmt.Sprintf(" AND f.size <= $%d", argPos)
-			args = append(args, bytes)
-			argPos++
+		saved, err := services.GetSavedSearch(savedID)
+		if err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
+		if saved == nil || !services.CanUseSavedSearch(userID, saved) {
+			http.Error(w, "Saved search not found", http.StatusNotFound)
+			return
+		}
+		q = saved.Apply(q)
+	}
+
+	filter, err := services.ParseFileQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if startDate != "" {
-		query += fmt.Sprintf(" AND f.uploaded_at >= $%d", argPos)
-		args = append(args, startDate)
-		argPos++
+
+	// paging & sorting :
+	page, err := services.ParseFilePage(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if endDate != "" {
-		query += fmt.Sprintf(" AND f.uploaded_at <= $%d", argPos)
-		args = append(args, endDate)
-		argPos++
+
+	// picking the space to list : personal, team (needs team_id) or all (default), optionally one folder :
+	where, args, err := services.UserFileScope(userID, q, nil)
+	switch err {
+	case nil:
+	case services.ErrNotTeamMember:
+		http.Error(w, "Forbidden: not a team member", http.StatusForbidden)
+		return
+	case services.ErrInvalidSpace, services.ErrInvalidTeamID, services.ErrInvalidFolderID:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
-	if uploader != "" {
-		query += fmt.Sprintf(" AND u.username ILIKE $%d", argPos)
-		args = append(args, "%"+uploader+"%")
-		argPos++
+
+	filterWhere, args := filter.Where(args)
+	where += filterWhere
+
+	// totals over every match, only for the first page :
+	var totals *services.FileTotals
+	if page.First() {
+		if totals, err = services.CountFiles(where, args); err != nil {
+			http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
-	query += " ORDER BY f.uploaded_at DESC"
+	keyset, pageArgs := page.Where(args)
+	query := `
+		SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, f.is_public,
+		u.username, f.team_id, t.name, f.folder_id, f.quarantined_at IS NOT NULL, f.download_count,
+		ARRAY(SELECT tg.name FROM file_tags ft JOIN tags tg ON tg.id = ft.tag_id WHERE ft.file_id = f.id ORDER BY tg.name),
+		EXISTS (SELECT 1 FROM file_previews p WHERE p.hash = f.hash AND p.status = 'done')
+		FROM files f 
+		JOIN users u ON f.user_id = u.id
+		LEFT JOIN teams t ON f.team_id = t.id
+		WHERE ` + where + keyset + page.OrderLimit()
 
 	// executing query :
-	rows, err := db.DB.Query(query, args...)
+	rows, err := db.DB.Query(query, pageArgs...)
 	if err != nil {
 		http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -107,8 +119,8 @@ func FilesHandler(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	// processing results :
-	var files []map[string]interface{}
-	var originalSize, dedupSize int64
+	files := make([]map[string]interface{}, 0)
+	var keys []services.FileKey
 	for rows.Next() {
 		var id int
 		var filename string
@@ -117,36 +129,69 @@ func FilesHandler(w http.ResponseWriter, r *http.Request) {
 		var isMaster bool
 		var username string
 		var is_public bool
-
-		if err := rows.Scan(&id, &filename, &size, &uploadedAt, &isMaster, &is_public, &username); err != nil {
+		var teamID, folderID *int
+		var teamName *string
+		var quarantined bool
+		var downloads int
+		tags := []string{}
+		var hasPreview bool
+
+		if err := rows.Scan(&id, &filename, &size, &uploadedAt, &isMaster, &is_public, &username, &teamID, &teamName, &folderID, &quarantined, &downloads, pq.Array(&tags), &hasPreview); err != nil {
 			http.Error(w, "DB scan error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		space := "personal"
+		if teamID != nil {
+			space = "team"
+		}
+		var thumbnail *string
+		if hasPreview {
+			url := fmt.Sprintf("/api/files/%d/thumbnail", id)
+			thumbnail = &url
+		}
+
 		// preparing response :
 		files = append(files, map[string]interface{}{
-			"id":           id,
-			"filename":     filename,
-			"size":         size,
-			"uploaded_at":  uploadedAt.Format(time.RFC3339),
-			"deduplicated": isMaster,
-			"uploader":     username,
-			"is_public":    is_public,
+			"id":            id,
+			"filename":      filename,
+			"size":          size,
+			"uploaded_at":   uploadedAt.Format(time.RFC3339),
+			"deduplicated":  isMaster,
+			"uploader":      username,
+			"is_public":     is_public,
+			"space":         space,
+			"team_id":       teamID,
+			"team_name":     teamName,
+			"folder_id":     folderID,
+			"quarantined":   quarantined,
+			"downloads":     downloads,
+			"tags":          tags,
+			"thumbnail_url": thumbnail,
 		})
-
-		// with adding sizes :
-		originalSize += size
-		if isMaster {
-			dedupSize += size
-		}
+		keys = append(keys, services.FileKey{ID: id, Filename: filename, Size: size, UploadedAt: uploadedAt, Downloads: downloads})
 	}
 
 	// sending messsages :
+	n, next := page.Next(len(files), func(i int) services.FileKey { return keys[i] })
+	writeFilePage(w, files[:n], page, next, totals)
+}
+
+// writeFilePage sends one page of a file listing; totals (first page only) add the
+// match count and the size sums (dedupSize / originalSize / saveSize).
+func writeFilePage(w http.ResponseWriter, files interface{}, page services.FilePage, next string, totals *services.FileTotals) {
 	resp := map[string]interface{}{
-		"files":        files,
-		"dedupSize":    dedupSize,
-		"originalSize": originalSize,
-		"saveSize":     originalSize - dedupSize,
+		"files":       files,
+		"next_cursor": next,
+		"limit":       page.Limit,
+		"sort":        page.Sort,
+		"order":       page.Order,
+	}
+	if totals != nil {
+		resp["total"] = totals.Total
+		resp["dedupSize"] = totals.DedupSize
+		resp["originalSize"] = totals.OriginalSize
+		resp["saveSize"] = totals.OriginalSize - totals.DedupSize
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -180,6 +225,20 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
+	// resolving the target space (personal / team) & folder :
+	teamID, folderID, status, err := resolveUploadSpace(r, userID)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// optional description (searchable) :
+	description := strings.TrimSpace(r.FormValue("description"))
+	if utf8.RuneCountInString(description) > services.MaxDescriptionLength {
+		http.Error(w, fmt.Sprintf("Description longer than %d characters", services.MaxDescriptionLength), http.StatusBadRequest)
+		return
+	}
+
 	// doing mime validation :
 	buf := make([]byte, 512)
 	_, _ = file.Read(buf)
@@ -227,78 +286,118 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	_, _ = file.Seek(0, io.SeekStart) // rewind for saving if new
 
-	if dup != nil {
-		// Duplicate found: insert metadata + add ref count
-		_, err = db.DB.Exec(
-			`INSERT INTO files (user_id, filename, filepath, hash, size, mime_type, is_master)
-			 VALUES ($1, $2, $3, $4, $5, $6, FALSE)`,
-			userID, handler.Filename, dup.Filepath, hash, size, mimeType,
-		)
+	// New file: saved physically before taking any DB locks :
+	var filePath string
+	if dup == nil {
+		timestamp := time.Now().UnixNano()
+		filePath = fmt.Sprintf("./uploads/%d_%s", timestamp, handler.Filename)
+		out, err := os.Create(filePath)
 		if err != nil {
-			http.Error(w, "DB insert error: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Could not create file", http.StatusInternalServerError)
+			return
+		}
+		_, err = io.Copy(out, file)
+		out.Close()
+		if err != nil {
+			_ = os.Remove(filePath)
+			http.Error(w, "Failed to save file", http.StatusInternalServerError)
 			return
 		}
-		_, _ = db.DB.Exec(`UPDATE files SET reference_count = reference_count + 1 WHERE id=$1`, dup.ID)
-
-		resp := map[string]string{"status": "duplicate-linked", "hash": hash}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
-		return
 	}
 
-	// quota checking  :
-	var used int64
-	err = db.DB.QueryRow(`SELECT COALESCE(SUM(size),0) FROM files WHERE user_id=$1`, userID).Scan(&used)
+	// one transaction : quota counter + file rows commit or roll back together :
+	tx, err := db.DB.Begin()
 	if err != nil {
+		discardUpload(filePath)
 		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	// quota resulting :
-	quota := utils.GetUserQuotaBytes()
-	if used+size > quota {
+	// quota checking on every path (duplicates count in full), team files count against the team :
+	used, quota, err := services.ReserveQuota(tx, userID, teamID, size)
+	if err == services.ErrQuotaExceeded {
+		discardUpload(filePath)
 		resp := map[string]interface{}{
 			"error":   "Storage quota exceeded",
 			"allowed": fmt.Sprintf("%d MB", quota/1024/1024),
-			"used":    fmt.Sprintf("%.2f MB", float64(used+size)/1024.0/1024.0),
+			"used":    fmt.Sprintf("%.2f MB", float64(used)/1024.0/1024.0),
 		}
+		audit(r, services.AuditEvent{Action: services.AuditFileUpload, Outcome: services.AuditDenied},
+			map[string]interface{}{"reason": "quota_exceeded", "filename": handler.Filename, "size": size, "team_id": teamID})
+		setQuotaHeaders(w, userID, teamID)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusForbidden)
 		json.NewEncoder(w).Encode(resp)
 		return
+	} else if err != nil {
+		discardUpload(filePath)
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// New file: saved physically :
-	timestamp := time.Now().UnixNano()
-	filePath := fmt.Sprintf("./uploads/%d_%s", timestamp, handler.Filename)
-	out, err := os.Create(filePath)
-	if err != nil {
-		http.Error(w, "Could not create file", http.StatusInternalServerError)
-		return
+	uploadStatus := "new-upload"
+	var newID int
+	if dup != nil {
+		// Duplicate found: insert metadata + add ref count
+		err = tx.QueryRow(
+			`INSERT INTO files (user_id, filename, filepath, hash, size, mime_type, is_master, team_id, folder_id, description)
+			 VALUES ($1, $2, $3, $4, $5, $6, FALSE, $7, $8, NULLIF($9, '')) RETURNING id`,
+			userID, handler.Filename, dup.Filepath, hash, size, mimeType, teamID, folderID, description,
+		).Scan(&newID)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE files SET reference_count = reference_count + 1 WHERE id=$1`, dup.ID)
+		}
+		uploadStatus = "duplicate-linked"
+	} else {
+		// Inserted as master file  :
+		err = tx.QueryRow(
+			`INSERT INTO files (user_id, filename, filepath, hash, size, mime_type, reference_count, is_master, team_id, folder_id, description)
+			 VALUES ($1, $2, $3, $4, $5, $6, 1, TRUE, $7, $8, NULLIF($9, '')) RETURNING id`,
+			userID, handler.Filename, filePath, hash, size, mimeType, teamID, folderID, description,
+		).Scan(&newID)
 	}
-	defer out.Close()
-	_, err = io.Copy(out, file)
-	if err != nil {
-		http.Error(w, "Failed to save file", http.StatusInternalServerError)
-		return
+	if err == nil {
+		err = tx.Commit()
 	}
-
-	// Inserted as master file  :
-	_, err = db.DB.Exec(
-		`INSERT INTO files (user_id, filename, filepath, hash, size, mime_type, reference_count, is_master)
-		 VALUES ($1, $2, $3, $4, $5, $6, 1, TRUE)`,
-		userID, handler.Filename, filePath, hash, size, mimeType,
-	)
 	if err != nil {
+		discardUpload(filePath)
 		http.Error(w, "DB insert error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	resp := map[string]string{"status": "new-upload", "hash": hash}
+	audit(r, services.AuditEvent{
+		Action: services.AuditFileUpload, TargetType: "file", TargetID: strconv.Itoa(newID), Outcome: services.AuditSuccess,
+	}, map[string]interface{}{"filename": handler.Filename, "size": size, "hash": hash, "status": uploadStatus, "team_id": teamID})
+	services.CheckQuotaWarnings(userID, teamID)
+	services.IndexFile(newID, hash)
+	services.QueuePreview(hash)
+	services.RecordActivity(services.Activity{Kind: services.ActivityUpload, ActorID: &userID, FileID: &newID, Filename: handler.Filename, TeamID: teamID},
+		services.ActivityAudience(teamID, userID))
+
+	resp := map[string]string{"status": uploadStatus, "hash": hash}
+	setQuotaHeaders(w, userID, teamID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// setQuotaHeaders reports the remaining quota of the upload's space on the response :
+func setQuotaHeaders(w http.ResponseWriter, userID int, teamID *int) {
+	remaining, limit, err := services.GetRemainingQuota(userID, teamID)
+	if err != nil {
+		return
+	}
+	w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+	w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+}
+
+// discardUpload removes a stored upload whose DB insert didn't go through :
+func discardUpload(filePath string) {
+	if filePath != "" {
+		_ = os.Remove(filePath)
+	}
+}
+
 // delete handler - deletes a specific file :
 func FileDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	// Auth: only owner can delete :
@@ -313,76 +412,33 @@ func FileDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	// Lookup file :
-	var uploaderID int
-	var filepathOnDisk string
-	var isMaster bool
-	var refCount int
-	err := db.DB.QueryRow(
-		`SELECT user_id, filepath, is_master, reference_count 
-		 FROM files WHERE id=$1`, id,
-	).Scan(&uploaderID, &filepathOnDisk, &isMaster, &refCount)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "File not found", http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	// checking who may delete (uploader, team admins, files.manage.any) :
+	meta, status, err := lookupFile(id)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
-
-	// ensuring only uploader can delete :
-	if uploaderID != userID {
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	if !services.CanModifyFile(userID, role, meta) {
+		audit(r, services.AuditEvent{Action: services.AuditFileDelete, TargetType: "file", TargetID: id, Outcome: services.AuditDenied}, nil)
 		http.Error(w, "Forbidden: not file owner", http.StatusForbidden)
 		return
 	}
 
-	// Case 1: Not master → just remove row + decrement master's ref_count :
-	if !isMaster {
-		// Decrement reference count of master
-		_, _ = db.DB.Exec(`
-			UPDATE files 
-			SET reference_count = reference_count - 1 
-			WHERE hash = (SELECT hash FROM files WHERE id=$1) 
-			  AND is_master = TRUE`, id)
-
-		_, err = db.DB.Exec(`DELETE FROM files WHERE id=$1`, id)
-		if err != nil {
-			http.Error(w, "DB delete error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-	} else {
-		// Case 2: Master file :
-		if refCount > 1 {
-			// Pick a duplicate to promote as new master :
-			var newMasterID int
-			err := db.DB.QueryRow(`
-				SELECT id FROM files 
-				WHERE hash = (SELECT hash FROM files WHERE id=$1) 
-				  AND is_master = FALSE 
-				LIMIT 1`, id).Scan(&newMasterID)
-			if err != nil {
-				http.Error(w, "Could not find duplicate to promote: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			// Promote duplicate → master :
-			_, _ = db.DB.Exec(`
-				UPDATE files SET is_master = TRUE, filepath = $1 
-				WHERE id=$2`, filepathOnDisk, newMasterID)
-
-			// Delete current master row :
-			_, _ = db.DB.Exec(`DELETE FROM files WHERE id=$1`, id)
-		} else {
-			// Case 3: Only reference → delete DB row + physical file :
-			_, err = db.DB.Exec(`DELETE FROM files WHERE id=$1`, id)
-			if err != nil {
-				http.Error(w, "DB delete error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			_ = os.Remove(filepathOnDisk) // remove file physically
-		}
+	// removing row, dedup refs & quota usage in one transaction :
+	if err := services.DeleteFile(meta.ID); err == services.ErrFileNotFound {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "DB delete error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	audit(r, services.AuditEvent{Action: services.AuditFileDelete, TargetType: "file", TargetID: id, Outcome: services.AuditSuccess},
+		map[string]interface{}{"filename": meta.Filename, "owner_id": meta.UploaderID, "size": meta.Size})
+	services.CheckQuotaWarnings(meta.UploaderID, meta.TeamID)
+	services.RecordActivity(services.Activity{Kind: services.ActivityDelete, ActorID: &userID, Filename: meta.Filename, TeamID: meta.TeamID},
+		services.ActivityAudience(meta.TeamID, meta.UploaderID))
+
 	// Responding success :
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]any{"success": true})
@@ -397,9 +453,24 @@ func FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// checking read access (public, uploader, team member, files.read.any) :
+	meta, status, err := lookupFile(id)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	via := services.ReadAccessVia(userID, role, meta)
+	if via == "" {
+		audit(r, services.AuditEvent{Action: services.AuditFileDownload, TargetType: "file", TargetID: id, Outcome: services.AuditDenied}, nil)
+		http.Error(w, "Forbidden: private file", http.StatusForbidden)
+		return
+	}
+
 	// looking up for the file in DB :
 	var filename, filepathOnDisk string
-	err := db.DB.QueryRow(
+	err = db.DB.QueryRow(
 		`SELECT filename, filepath FROM files WHERE id=$1`, id,
 	).Scan(&filename, &filepathOnDisk)
 
@@ -419,12 +490,37 @@ func FileDownloadHandler(w http.ResponseWriter, r *http.Request) {
 
 	// increasing the download_count :
 	_, _ = db.DB.Exec(`UPDATE files SET download_count = download_count + 1 WHERE id=$1`, id)
+	audit(r, services.AuditEvent{Action: services.AuditFileDownload, TargetType: "file", TargetID: id, Outcome: services.AuditSuccess},
+		map[string]interface{}{"filename": filename})
 
-	// sending the response :
+	// sending the response (counting what was actually served, ranges included) :
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
 	w.Header().Set("Content-Type", "application/octet-stream")
 
-	http.ServeFile(w, r, filepath.Clean(filepathOnDisk))
+	cw := &countingWriter{ResponseWriter: w}
+	http.ServeFile(cw, r, filepath.Clean(filepathOnDisk))
+
+	// per-download history :
+	var downloader *int
+	if userID != 0 {
+		downloader = &userID
+	}
+	if err := services.RecordDownload(meta.ID, downloader, via, utils.ClientIP(r), r.UserAgent(), cw.n); err != nil {
+		log.Printf("❌ download record for file %d failed: %v", meta.ID, err)
+	}
+	services.RecordFileView(userID, meta.ID)
+}
+
+// countingWriter counts the body bytes written through it :
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+	return n, err
 }
 
 // privacy change handler - changes a file's privacy  :
@@ -443,22 +539,17 @@ func FileTogglePrivacyHandler(w http.ResponseWriter, r *http.Request) {
 	id := vars["id"]
 
 	// checking the file in DB :
-	var uploaderID int
-	var isPublic bool
-	err := db.DB.QueryRow(
-		`SELECT user_id, is_public FROM files WHERE id=$1`, id,
-	).Scan(&uploaderID, &isPublic)
-
-	if err == sql.ErrNoRows {
-		http.Error(w, "File not found", http.StatusNotFound)
-		return
-	} else if err != nil {
-		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	meta, status, err := lookupFile(id)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
+	isPublic := meta.IsPublic
 
-	// making sure only uploader can toggle :
-	if uploaderID != userID {
+	// making sure only uploader (or team admins / files.manage.any) can toggle :
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	if !services.CanModifyFile(userID, role, meta) {
+		audit(r, services.AuditEvent{Action: services.AuditFileVisibility, TargetType: "file", TargetID: id, Outcome: services.AuditDenied}, nil)
 		http.Error(w, "Not allowed", http.StatusForbidden)
 		return
 	}
@@ -470,6 +561,8 @@ func FileTogglePrivacyHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to update privacy", http.StatusInternalServerError)
 		return
 	}
+	audit(r, services.AuditEvent{Action: services.AuditFileVisibility, TargetType: "file", TargetID: id, Outcome: services.AuditSuccess},
+		map[string]interface{}{"is_public": newPrivacy})
 
 	resp := map[string]interface{}{
 		"success":   true,
@@ -517,15 +610,95 @@ func FileDetailHandler(w http.ResponseWriter, r *http.Request) {
 		userID, _ = uidVal.(int)
 	}
 
-	// If file is NOT public, allow only uploader or admin :
-	if !file.IsPublic {
-		if role != "admin" && file.UploaderID != userID {
-			http.Error(w, "Forbidden: private file", http.StatusForbidden)
+	// If file is NOT public, allow only uploader, team members or roles that can read any file :
+	if !services.CanReadFile(userID, role, file) {
+		http.Error(w, "Forbidden: private file", http.StatusForbidden)
+		return
+	}
+
+	// tags & custom metadata :
+	if err := services.LoadFileAnnotations(file); err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if file.CommentsCount, err = services.CountFileComments(file.ID); err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// caller's star & recent files :
+	if userID != 0 {
+		if file.Starred, err = services.IsStarred(userID, file.ID); err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		services.RecordFileView(userID, file.ID)
 	}
 
 	// Respond with JSON :
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(file)
 }
+
+// lookupFile loads file metadata by its string ID, returning an HTTP status on failure :
+func lookupFile(id string) (*services.FileMeta, int, error) {
+	fileID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("Invalid file ID")
+	}
+	meta, err := services.GetFileByID(fileID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("DB error: %v", err)
+	}
+	if meta == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("File not found")
+	}
+	return meta, http.StatusOK, nil
+}
+
+// resolveUploadSpace reads optional team_id / folder_id form fields and checks the
+// uploader may write there. Both nil means the user's personal space.
+func resolveUploadSpace(r *http.Request, userID int) (*int, *int, int, error) {
+	var teamID, folderID *int
+
+	if v := r.FormValue("folder_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("Invalid folder_id")
+		}
+		folder, err := services.GetFolder(id)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("DB error: %v", err)
+		}
+		// personal folders are only visible to their owner :
+		if folder == nil || (folder.TeamID == nil && folder.UserID != userID) {
+			return nil, nil, http.StatusNotFound, fmt.Errorf("Folder not found")
+		}
+		folderID = &id
+		teamID = folder.TeamID
+	}
+
+	if v := r.FormValue("team_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("Invalid team_id")
+		}
+		if folderID != nil && (teamID == nil || *teamID != id) {
+			return nil, nil, http.StatusBadRequest, fmt.Errorf("Folder does not belong to that team")
+		}
+		teamID = &id
+	}
+
+	// team uploads need membership :
+	if teamID != nil {
+		teamRole, err := services.GetTeamRole(*teamID, userID)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("DB error: %v", err)
+		}
+		if teamRole == "" {
+			return nil, nil, http.StatusForbidden, fmt.Errorf("Forbidden: not a team member")
+		}
+	}
+	return teamID, folderID, http.StatusOK, nil
+}
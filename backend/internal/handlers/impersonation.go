@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// impersonateRequest is the optional body of AdminImpersonateHandler :
+type impersonateRequest struct {
+	AllowDestructive bool   `json:"allow_destructive"`
+	Reason           string `json:"reason"`
+}
+
+// AdminImpersonateHandler – starts acting as the user (read-only unless allow_destructive)
+func AdminImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	// no nesting, the admin has to stop first :
+	if _, ok := r.Context().Value(middleware.ContextImpersonatorIDKey).(int); ok {
+		http.Error(w, services.ErrAlreadyImpersonated.Error(), http.StatusConflict)
+		return
+	}
+
+	var req impersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+	adminID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+
+	token, target, err := services.StartImpersonation(adminID, user.ID, req.AllowDestructive)
+	switch err {
+	case nil:
+	case services.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrImpersonateSelf:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrImpersonateInactive:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "Failed to start impersonation", http.StatusInternalServerError)
+		return
+	}
+	setTokenCookie(w, token)
+
+	audit(r, services.AuditEvent{Action: services.AuditImpersonateStart, TargetType: "user", TargetID: strconv.Itoa(target.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"username": target.Username, "allow_destructive": req.AllowDestructive, "reason": req.Reason})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            "ok",
+		"user":              target,
+		"allow_destructive": req.AllowDestructive,
+	})
+}
+
+// StopImpersonationHandler – swaps the impersonation token back for the admin's own
+func StopImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := r.Context().Value(middleware.ContextImpersonatorIDKey).(int)
+	if !ok {
+		http.Error(w, services.ErrNotImpersonating.Error(), http.StatusBadRequest)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+
+	token, err := services.StopImpersonation(adminID)
+	if err == services.ErrSessionRevoked {
+		http.Error(w, "Admin session is no longer valid", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to stop impersonation", http.StatusInternalServerError)
+		return
+	}
+	setTokenCookie(w, token)
+
+	audit(r, services.AuditEvent{Action: services.AuditImpersonateStop, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditSuccess}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"user_id": adminID,
+	})
+}
+
+// setTokenCookie stores a freshly issued JWT the same way login does :
+func setTokenCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    token,
+		Path:     "/",
+		Expires:  time.Now().Add(5 * time.Minute),
+		HttpOnly: true,
+		Secure:   false,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// FileThumbnailHandler – serves the file's generated thumbnail (guests too, for public files)
+func FileThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	meta, status, err := lookupFile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	if !services.CanReadFile(userID, role, meta) {
+		http.Error(w, "Forbidden: private file", http.StatusForbidden)
+		return
+	}
+
+	preview, err := services.GetFilePreview(meta.Hash)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if preview != nil && preview.Status == services.PreviewPending {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": preview.Status})
+		return
+	}
+	if preview == nil || preview.Status != services.PreviewDone {
+		http.Error(w, "No preview available", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(preview.Path)
+	if err != nil {
+		http.Error(w, "Preview missing on server", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// same content → same preview, so the hash (plus the render time) makes a stable validator.
+	// Never stored by shared caches & always revalidated, so a file made private (or unshared)
+	// stops showing its thumbnail : the access check above runs before any 304 :
+	w.Header().Set("Content-Type", preview.MimeType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, meta.Hash, preview.UpdatedAt.Unix()))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	http.ServeContent(w, r, "", preview.UpdatedAt, f)
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 
 	"backend/internal/db"
+	"backend/internal/services"
 )
 
 // structure for public listing files :
@@ -18,6 +19,7 @@ type PublicFile struct {
 	IsMaster      bool      `json:"is_master"`
 	Uploader      string    `json:"uploader"`
 	DownloadCount int       `json:"download_count"`
+	ThumbnailURL  *string   `json:"thumbnail_url"`
 }
 
 // PublicFilesHandler - gets all public files :
@@ -27,14 +29,38 @@ func PublicFilesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// filters, paging & sorting (same contract as the user & admin listings) :
+	q := r.URL.Query()
+	filter, err := services.ParseFileQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := services.ParseFilePage(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filterWhere, filterArgs := filter.Where(nil)
+	where := "f.is_public = TRUE AND f.quarantined_at IS NULL" + filterWhere
+
+	// adding total count (first page only) :
+	var totals *services.FileTotals
+	if page.First() {
+		if totals, err = services.CountFiles(where, filterArgs); err != nil {
+			http.Error(w, "DB count error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// executing the query :
+	keyset, args := page.Where(filterArgs)
 	rows, err := db.DB.Query(`
-		SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, u.username, f.download_count
+		SELECT f.id, f.filename, f.size, f.uploaded_at, f.is_master, u.username, f.download_count,
+		EXISTS (SELECT 1 FROM file_previews p WHERE p.hash = f.hash AND p.status = 'done')
 		FROM files f
 		JOIN users u ON f.user_id = u.id
-		WHERE f.is_public = TRUE
-		ORDER BY f.uploaded_at DESC
-	`)
+		WHERE `+where+keyset+page.OrderLimit(), args...)
 	if err != nil {
 		http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -45,24 +71,32 @@ func PublicFilesHandler(w http.ResponseWriter, r *http.Request) {
 	files := make([]PublicFile, 0)
 	for rows.Next() {
 		var pf PublicFile
-		if err := rows.Scan(&pf.ID, &pf.Filename, &pf.Size, &pf.UploadedAt, &pf.IsMaster, &pf.Uploader, &pf.DownloadCount); err != nil {
+		var hasPreview bool
+		if err := rows.Scan(&pf.ID, &pf.Filename, &pf.Size, &pf.UploadedAt, &pf.IsMaster, &pf.Uploader, &pf.DownloadCount, &hasPreview); err != nil {
 			http.Error(w, "DB scan error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if hasPreview {
+			url := fmt.Sprintf("/api/files/%d/thumbnail", pf.ID)
+			pf.ThumbnailURL = &url
+		}
 		files = append(files, pf)
 	}
 
-	// adding total count :
-	var total int
-	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM files WHERE is_public = TRUE`).Scan(&total); err != nil && err != sql.ErrNoRows {
-		http.Error(w, "DB count error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
 	// sending response : 
+	n, next := page.Next(len(files), func(i int) services.FileKey {
+		f := files[i]
+		return services.FileKey{ID: f.ID, Filename: f.Filename, Size: f.Size, UploadedAt: f.UploadedAt, Downloads: f.DownloadCount}
+	})
 	resp := map[string]interface{}{
-		"files": files,
-		"total": total,
+		"files":       files[:n],
+		"next_cursor": next,
+		"limit":       page.Limit,
+		"sort":        page.Sort,
+		"order":       page.Order,
+	}
+	if totals != nil {
+		resp["total"] = totals.Total
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
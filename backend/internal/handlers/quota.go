@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// MyUsageHandler - storage usage of the caller's personal space :
+func MyUsageHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	usage, err := services.GetUserUsage(userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
+
+// AdminSetUserQuotaHandler – overrides one user's quota (null resets to the role default)
+func AdminSetUserQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	quota, ok := decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := services.SetUserQuota(userID, quota)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditQuotaChange, TargetType: "user", TargetID: strconv.Itoa(userID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"quota_bytes": quota})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "ok",
+		"user_id":     userID,
+		"quota_bytes": quota,
+	})
+}
+
+// AdminSetRoleQuotaHandler – sets the default quota for a role (null resets to USER_QUOTA_MB)
+func AdminSetRoleQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	role := mux.Vars(r)["name"]
+
+	quota, ok := decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := services.SetRoleDefaultQuota(role, quota)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Role not found", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditQuotaChange, TargetType: "role", TargetID: role, Outcome: services.AuditSuccess},
+		map[string]interface{}{"quota_bytes": quota})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "ok",
+		"role":        role,
+		"quota_bytes": quota,
+	})
+}
+
+// decodeQuotaRequest reads {"quota_bytes": n | null}, writing 400 on bad input :
+func decodeQuotaRequest(w http.ResponseWriter, r *http.Request) (*int64, bool) {
+	var req struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.QuotaBytes != nil && *req.QuotaBytes < 0) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return nil, false
+	}
+	return req.QuotaBytes, true
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminRolesHandler – lists roles with their permissions
+func AdminRolesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	roles, err := services.ListRoles()
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"roles": roles,
+	})
+}
+
+// AdminPermissionsHandler – lists every grantable permission
+func AdminPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	perms, err := services.ListPermissions()
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"permissions": perms,
+	})
+}
+
+// AdminSaveRoleHandler – creates a role or replaces its permission set
+func AdminSaveRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// parsing request body :
+	var req struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// no privilege escalation (checked again by SaveRole against the stored permissions) :
+	callerRole, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	err := services.SaveRole(callerRole, req.Name, req.Description, req.Permissions)
+	switch err {
+	case nil:
+	case services.ErrInvalidRoleName, services.ErrUnknownPermission:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrRoleEscalation, services.ErrBuiltinAdminRole, services.ErrNoRoleManager:
+		reason := map[error]string{
+			services.ErrRoleEscalation:   "escalation",
+			services.ErrBuiltinAdminRole: "builtin_admin",
+			services.ErrNoRoleManager:    "no_role_manager",
+		}[err]
+		audit(r, services.AuditEvent{Action: services.AuditRoleSave, TargetType: "role", TargetID: req.Name, Outcome: services.AuditDenied},
+			map[string]interface{}{"permissions": req.Permissions, "reason": reason})
+		if err == services.ErrNoRoleManager {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit(r, services.AuditEvent{Action: services.AuditRoleSave, TargetType: "role", TargetID: req.Name, Outcome: services.AuditSuccess},
+		map[string]interface{}{"permissions": req.Permissions})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "ok",
+		"name":        req.Name,
+		"permissions": req.Permissions,
+	})
+}
+
+// AdminDeleteRoleHandler – deletes a custom role nobody holds
+func AdminDeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Only DELETE allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	err := services.DeleteRole(name)
+	switch err {
+	case nil:
+	case services.ErrRoleNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrSystemRole, services.ErrRoleInUse:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit(r, services.AuditEvent{Action: services.AuditRoleDelete, TargetType: "role", TargetID: name, Outcome: services.AuditSuccess}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "ok",
+		"name":   name,
+	})
+}
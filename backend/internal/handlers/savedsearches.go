@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// savedSearchError writes the HTTP error for a failed saved search change :
+func savedSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidFilter), err == services.ErrSavedSearchName,
+		err == services.ErrInvalidSpace, err == services.ErrInvalidTeamID, err == services.ErrInvalidFolderID:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err == services.ErrNotTeamMember:
+		http.Error(w, "Forbidden: not a team member", http.StatusForbidden)
+	case err == services.ErrSavedSearchNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err == services.ErrSavedSearchTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// usableSavedSearch loads the {id} saved search if the caller may use it (404 otherwise) :
+func usableSavedSearch(w http.ResponseWriter, r *http.Request, userID int) (*services.SavedSearch, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+		return nil, false
+	}
+	saved, err := services.GetSavedSearch(id)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if saved == nil || !services.CanUseSavedSearch(userID, saved) {
+		http.Error(w, services.ErrSavedSearchNotFound.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	return saved, true
+}
+
+// ListSavedSearchesHandler – the caller's saved searches plus those shared with their teams
+func ListSavedSearchesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	searches, err := services.ListSavedSearches(userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"saved_searches": searches})
+}
+
+// CreateSavedSearchHandler – saves a named filter set (optionally shared with a team)
+func CreateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var in services.SavedSearchInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	saved, err := services.CreateSavedSearch(userID, in)
+	if err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// UpdateSavedSearchHandler – replaces name, sharing & filters (owner only)
+func UpdateSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+		return
+	}
+	var in services.SavedSearchInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	saved, err := services.UpdateSavedSearch(id, userID, in)
+	if err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(saved)
+}
+
+// DeleteSavedSearchHandler – deletes a saved search (owner only)
+func DeleteSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+		return
+	}
+	deleted, err := services.DeleteSavedSearch(id, userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, services.ErrSavedSearchNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SubscribeSavedSearchHandler – POST subscribes to new matches, DELETE unsubscribes
+func SubscribeSavedSearchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	saved, ok := usableSavedSearch(w, r, userID)
+	if !ok {
+		return
+	}
+
+	subscribed := r.Method == http.MethodPost
+	var err error
+	if subscribed {
+		err = services.SubscribeSavedSearch(saved.ID, userID)
+	} else {
+		_, err = services.UnsubscribeSavedSearch(saved.ID, userID)
+	}
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": saved.ID, "subscribed": subscribed})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxSearchPageSize = 50
+
+// SearchFilesHandler – ranked full-text search over filenames, descriptions & document contents.
+// ?q= takes web-search syntax ("exact phrase", or, -excluded); scope=mine (default: personal + team
+// files), public, or all (needs files.read.any). The listing filters (services.FileQuery) apply too.
+func SearchFilesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized userID", http.StatusUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+
+	q := r.URL.Query()
+	text := strings.TrimSpace(q.Get("q"))
+	if text == "" || utf8.RuneCountInString(text) > 200 {
+		http.Error(w, "q is required (at most 200 characters)", http.StatusBadRequest)
+		return
+	}
+	filter, err := services.ParseFileQuery(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 20
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchPageSize {
+			http.Error(w, "Invalid limit (1-50)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 10000 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	// what the caller may read; quarantined files only with files.read.any :
+	var where string
+	var args []interface{}
+	scope := q.Get("scope")
+	switch scope {
+	case "", "mine":
+		scope = "mine"
+		where = `f.quarantined_at IS NULL AND ((f.user_id = $1 AND f.team_id IS NULL)
+			OR f.team_id IN (SELECT team_id FROM team_members WHERE user_id = $1))`
+		args = append(args, userID)
+	case "public":
+		where = "f.is_public = TRUE AND f.quarantined_at IS NULL"
+	case "all":
+		if !services.HasPermission(role, services.PermFilesReadAny) {
+			http.Error(w, "Forbidden: scope=all needs "+services.PermFilesReadAny, http.StatusForbidden)
+			return
+		}
+		where = "TRUE"
+	default:
+		http.Error(w, "Invalid scope, use mine, public or all", http.StatusBadRequest)
+		return
+	}
+	filterWhere, args := filter.Where(args)
+	where += filterWhere
+
+	results, err := services.SearchFiles(text, where, args, limit, offset)
+	if err != nil {
+		http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := map[string]interface{}{
+		"results": results,
+		"query":   text,
+		"scope":   scope,
+		"limit":   limit,
+		"offset":  offset,
+	}
+	// total only for the first page, like the listings :
+	if offset == 0 {
+		total, err := services.CountSearchMatches(text, where, args)
+		if err != nil {
+			http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp["total"] = total
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
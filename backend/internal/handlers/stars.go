@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// queryLimit reads an optional ?limit= between 1 and max, writing the HTTP error otherwise :
+func queryLimit(w http.ResponseWriter, r *http.Request, def int, max int) (int, bool) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > max {
+		http.Error(w, "Invalid limit (1-"+strconv.Itoa(max)+")", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// StarFileHandler – PUT stars a readable file, DELETE removes the star
+func StarFileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	meta, status, err := lookupFile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	starred := r.Method == http.MethodPut
+	if starred {
+		role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+		if !services.CanReadFile(userID, role, meta) {
+			http.Error(w, "Forbidden: private file", http.StatusForbidden)
+			return
+		}
+		err = services.StarFile(userID, meta.ID)
+	} else {
+		// unstarring needs no access, a file may have become unreadable since :
+		_, err = services.UnstarFile(userID, meta.ID)
+	}
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": meta.ID, "starred": starred})
+}
+
+// StarredFilesHandler – the caller's starred files (?limit=&offset=), latest stars first
+func StarredFilesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, ok := queryLimit(w, r, 50, 100)
+	if !ok {
+		return
+	}
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	files, total, err := services.ListStarredFiles(userID, limit, offset)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"files": files, "total": total, "limit": limit, "offset": offset})
+}
+
+// RecentFilesHandler – files the caller recently downloaded or opened (?limit=, up to 100)
+func RecentFilesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, ok := queryLimit(w, r, 20, 100)
+	if !ok {
+		return
+	}
+	files, err := services.ListRecentFiles(userID, limit)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"files": files})
+}
+
+// ActivityFeedHandler – the caller's activity feed, newest first (?limit=&cursor=)
+func ActivityFeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	limit, ok := queryLimit(w, r, 50, 100)
+	if !ok {
+		return
+	}
+	feed, next, err := services.ListActivity(userID, r.URL.Query().Get("cursor"), limit)
+	if err == services.ErrInvalidCursor {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"activities": feed, "next_cursor": next, "limit": limit})
+}
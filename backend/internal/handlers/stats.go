@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminStatsHandler – system-wide storage & dedup overview (?days=30&top=10)
+func AdminStatsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	days := 30
+	if v := q.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	top := 10
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "top must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		top = n
+	}
+
+	stats, err := services.GetSystemStats(days, top)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// AdminStatsHistoryHandler – stored daily snapshots for charting (?from=YYYY-MM-DD&to=YYYY-MM-DD)
+func AdminStatsHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	// default window : last 90 days :
+	to := time.Now()
+	from := to.AddDate(0, 0, -90)
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid from (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, "Invalid to (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	snapshots, err := services.ListStatsSnapshots(from, to)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":      from.Format("2006-01-02"),
+		"to":        to.Format("2006-01-02"),
+		"snapshots": snapshots,
+	})
+}
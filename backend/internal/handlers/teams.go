@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/models"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateTeamHandler - creates a team with the caller as owner :
+func CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	teamID, err := services.CreateTeam(req.Name, userID)
+	switch err {
+	case nil:
+	case services.ErrInvalidTeamName:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrTeamNameTaken:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit(r, services.AuditEvent{Action: services.AuditTeamCreate, TargetType: "team", TargetID: strconv.Itoa(teamID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"name": req.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ok",
+		"id":     teamID,
+		"name":   req.Name,
+	})
+}
+
+// ListTeamsHandler - lists the caller's teams with quota usage :
+func ListTeamsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	teams, err := services.ListUserTeams(userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"teams": teams,
+	})
+}
+
+// TeamMembersHandler - lists members of a team the caller belongs to :
+func TeamMembersHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, _, ok := requireTeamRole(w, r, false)
+	if !ok {
+		return
+	}
+
+	members, err := services.ListTeamMembers(teamID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"members": members,
+	})
+}
+
+// AddTeamMemberHandler - team owners/admins add a user by username :
+func AddTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, myRole, ok := requireTeamRole(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if req.Role == "" {
+		req.Role = services.TeamRoleMember
+	}
+
+	// only owners can hand out ownership :
+	if req.Role == services.TeamRoleOwner && myRole != services.TeamRoleOwner {
+		http.Error(w, "Forbidden: only owners can add owners", http.StatusForbidden)
+		return
+	}
+
+	user, err := models.GetUserByUsername(req.Username)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	err = services.AddTeamMember(teamID, user.ID, req.Role)
+	switch err {
+	case nil:
+	case services.ErrInvalidTeamRole:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrAlreadyMember:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit(r, services.AuditEvent{Action: services.AuditTeamMemberAdd, TargetType: "team", TargetID: strconv.Itoa(teamID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"user_id": user.ID, "role": req.Role})
+	if actorID, ok := r.Context().Value(middleware.ContextUserIDKey).(int); ok {
+		services.RecordActivity(services.Activity{Kind: services.ActivityTeamJoined, ActorID: &actorID, TeamID: &teamID,
+			Details: map[string]interface{}{"role": req.Role}}, []int{user.ID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"user_id": user.ID,
+		"role":    req.Role,
+	})
+}
+
+// UpdateTeamMemberHandler - team owners/admins change a member's team role :
+func UpdateTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, myRole, ok := requireTeamRole(w, r, true)
+	if !ok {
+		return
+	}
+	memberID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// admins can't touch owners or create them :
+	if myRole != services.TeamRoleOwner {
+		current, err := services.GetTeamRole(teamID, memberID)
+		if err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if current == services.TeamRoleOwner || req.Role == services.TeamRoleOwner {
+			http.Error(w, "Forbidden: only owners can change ownership", http.StatusForbidden)
+			return
+		}
+	}
+
+	if !writeMembershipError(w, services.UpdateTeamMemberRole(teamID, memberID, req.Role)) {
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditTeamMemberUpdate, TargetType: "team", TargetID: strconv.Itoa(teamID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"user_id": memberID, "role": req.Role})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"user_id": memberID,
+		"role":    req.Role,
+	})
+}
+
+// RemoveTeamMemberHandler - team owners/admins remove a member; anyone can remove themselves :
+func RemoveTeamMemberHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	memberID, err := strconv.Atoi(mux.Vars(r)["userID"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// leaving needs plain membership, removing others needs manager rights :
+	teamID, myRole, ok := requireTeamRole(w, r, memberID != userID)
+	if !ok {
+		return
+	}
+	if memberID != userID && myRole != services.TeamRoleOwner {
+		current, err := services.GetTeamRole(teamID, memberID)
+		if err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if current == services.TeamRoleOwner {
+			http.Error(w, "Forbidden: only owners can remove owners", http.StatusForbidden)
+			return
+		}
+	}
+
+	if !writeMembershipError(w, services.RemoveTeamMember(teamID, memberID)) {
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditTeamMemberRemove, TargetType: "team", TargetID: strconv.Itoa(teamID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"user_id": memberID})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"user_id": memberID,
+	})
+}
+
+// CreateFolderHandler - creates a folder in the personal space or in a team space :
+func CreateFolderHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name     string `json:"name"`
+		TeamID   *int   `json:"team_id"`
+		ParentID *int   `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	// team folders need membership :
+	if req.TeamID != nil {
+		teamRole, err := services.GetTeamRole(*req.TeamID, userID)
+		if err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if teamRole == "" {
+			http.Error(w, "Forbidden: not a team member", http.StatusForbidden)
+			return
+		}
+	}
+
+	folder, err := services.CreateFolder(req.Name, userID, req.TeamID, req.ParentID)
+	switch err {
+	case nil:
+	case services.ErrInvalidFolderArg:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrFolderNotFound:
+		http.Error(w, "Parent folder not found", http.StatusNotFound)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folder)
+}
+
+// ListFoldersHandler - lists folders of the personal space, or of a team with ?team_id= :
+func ListFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var teamID *int
+	if v := r.URL.Query().Get("team_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid team_id", http.StatusBadRequest)
+			return
+		}
+		teamRole, err := services.GetTeamRole(id, userID)
+		if err != nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if teamRole == "" {
+			http.Error(w, "Forbidden: not a team member", http.StatusForbidden)
+			return
+		}
+		teamID = &id
+	}
+
+	folders, err := services.ListFolders(userID, teamID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"folders": folders,
+	})
+}
+
+// AdminSetTeamQuotaHandler – overrides a team's quota (null resets to TEAM_QUOTA_MB)
+func AdminSetTeamQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	teamID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	quota, ok := decodeQuotaRequest(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := services.SetTeamQuota(teamID, quota)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Team not found", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditQuotaChange, TargetType: "team", TargetID: strconv.Itoa(teamID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"quota_bytes": quota})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":      "ok",
+		"team_id":     teamID,
+		"quota_bytes": quota,
+	})
+}
+
+// requireTeamRole reads {id} from the route and checks the caller's membership.
+// With manage=true only team owners/admins pass. Writes the error response itself.
+func requireTeamRole(w http.ResponseWriter, r *http.Request, manage bool) (int, string, bool) {
+	userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, "", false
+	}
+	teamID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid team ID", http.StatusBadRequest)
+		return 0, "", false
+	}
+
+	role, err := services.GetTeamRole(teamID, userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return 0, "", false
+	}
+	if role == "" {
+		http.Error(w, "Forbidden: not a team member", http.StatusForbidden)
+		return 0, "", false
+	}
+	if manage && !services.IsTeamManager(role) {
+		http.Error(w, "Forbidden: team owners/admins only", http.StatusForbidden)
+		return 0, "", false
+	}
+	return teamID, role, true
+}
+
+// writeMembershipError maps membership errors to responses, returns true if err was nil :
+func writeMembershipError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case services.ErrInvalidTeamRole:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case services.ErrNotTeamMember:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case services.ErrLastTeamOwner:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
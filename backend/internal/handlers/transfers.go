@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/services"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// transferActor names the admin behind a transfer, for the history :
+func transferActor(r *http.Request) services.TransferActor {
+	by := services.TransferActor{Source: services.TransferViaAPI}
+	if userID, ok := r.Context().Value(middleware.ContextUserIDKey).(int); ok {
+		by.UserID = &userID
+	}
+	by.Username, _ = r.Context().Value(middleware.ContextUsernameKey).(string)
+	return by
+}
+
+// AdminTransferFilesHandler – hands all or selected files & folders of the user to another one
+func AdminTransferFilesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	req.FromUserID = user.ID
+
+	t, err := services.TransferOwnership(req, transferActor(r))
+	switch err {
+	case nil:
+	case services.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrTransferSameUser, services.ErrTransferSelection, services.ErrInvalidTarget:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		if t == nil {
+			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// the transfer itself went through, the reconciler will fix the counters :
+		log.Printf("❌ usage recalculation after transfer %d failed: %v", t.ID, err)
+	}
+
+	audit(r, services.AuditEvent{Action: services.AuditFileTransfer, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"transfer_id": t.ID, "to_user_id": t.ToUserID, "files": t.Files, "folders": t.Folders, "bytes": t.Bytes, "reason": t.Reason})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t)
+}
+
+// AdminTransfersHandler – transfer history (?user_id=&limit=&offset=)
+func AdminTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var userID *int
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+		userID = &id
+	}
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			http.Error(w, "Invalid limit (1-200)", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	transfers, total, err := services.ListOwnershipTransfers(userID, limit, offset)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transfers": transfers,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
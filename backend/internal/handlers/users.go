@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"backend/internal/middleware"
+	"backend/internal/models"
+	"backend/internal/services"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminUsersHandler – paginated user listing (?search=&role=&active=&limit=&offset=)
+func AdminUsersHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := services.UserFilter{Search: q.Get("search"), Role: q.Get("role")}
+	if v := q.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid active", http.StatusBadRequest)
+			return
+		}
+		f.Active = &active
+	}
+
+	limit := 50
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 200 {
+			http.Error(w, "limit must be between 1 and 200", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	users, total, err := services.ListUsers(f, limit, offset)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// AdminUserDetailHandler – one user with storage usage, teams and last_login
+func AdminUserDetailHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	detail, err := services.GetUserDetail(userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if detail == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+// manageableUser reads {id} and checks the caller may act on that user : not themselves,
+// and only users whose role the caller's role covers. Writes the error response itself.
+func manageableUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+	callerID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+	if userID == callerID {
+		http.Error(w, "Cannot perform this action on your own account", http.StatusBadRequest)
+		return nil, false
+	}
+
+	user, err := models.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if user == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	callerRole, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+	covers, err := services.RoleCovers(callerRole, user.Role)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if !covers {
+		http.Error(w, "Forbidden: user holds permissions you don't have", http.StatusForbidden)
+		return nil, false
+	}
+	return user, true
+}
+
+// AdminActivateUserHandler – re-enables a deactivated account
+func AdminActivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	setUserActive(w, r, true)
+}
+
+// AdminDeactivateUserHandler – disables an account and ends its sessions
+func AdminDeactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	setUserActive(w, r, false)
+}
+
+func setUserActive(w http.ResponseWriter, r *http.Request, active bool) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := services.SetUserActive(user.ID, active)
+	switch err {
+	case nil:
+	case services.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrLastAdmin:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	action := services.AuditUserActivate
+	if !active {
+		action = services.AuditUserDeactivate
+	}
+	audit(r, services.AuditEvent{Action: action, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"username": user.Username})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "ok",
+		"user_id":   user.ID,
+		"is_active": active,
+	})
+}
+
+// AdminForceLogoutHandler – invalidates every token issued to the user
+func AdminForceLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := services.RevokeSessions(user.ID)
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditUserLogout, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"username": user.Username})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"user_id": user.ID,
+	})
+}
+
+// AdminDeleteUserHandler – deletes a user (?files=delete | files=transfer&transfer_to=<id>)
+func AdminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := manageableUser(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	mode := q.Get("files")
+	transferTo := 0
+	if mode == services.UserFilesTransfer {
+		n, err := strconv.Atoi(q.Get("transfer_to"))
+		if err != nil {
+			http.Error(w, "transfer_to must be a user ID", http.StatusBadRequest)
+			return
+		}
+		transferTo = n
+	}
+
+	result, err := services.DeleteUser(user.ID, mode, transferTo, transferActor(r))
+	switch err {
+	case nil:
+	case services.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case services.ErrInvalidFilesMode, services.ErrInvalidTarget:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case services.ErrLastAdmin, services.ErrUserOwnsFiles, services.ErrSoleTeamOwner:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		// files may already have been handled, so the partial result is recorded too :
+		audit(r, services.AuditEvent{Action: services.AuditUserDelete, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditFailure},
+			map[string]interface{}{"username": user.Username, "files": mode, "result": result, "error": err.Error()})
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	audit(r, services.AuditEvent{Action: services.AuditUserDelete, TargetType: "user", TargetID: strconv.Itoa(user.ID), Outcome: services.AuditSuccess},
+		map[string]interface{}{"username": user.Username, "files": mode, "transfer_to": transferTo, "result": result})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":            "ok",
+		"user_id":           user.ID,
+		"files_deleted":     result.FilesDeleted,
+		"files_transferred": result.FilesTransferred,
+	})
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"backend/internal/config"
+	"backend/internal/middleware"
+	"backend/internal/models"
+	"backend/internal/services"
+	"context"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// FileViewHandler – shows a file inline (images, PDF, text, audio/video; HTML/SVG sandboxed), guests
+// too for public files. With VIEW_SANDBOX_ORIGIN set it redirects there through a signed link instead.
+func FileViewHandler(w http.ResponseWriter, r *http.Request) {
+	meta, status, err := lookupFile(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	userID, _ := r.Context().Value(middleware.ContextUserIDKey).(int)
+	role, _ := r.Context().Value(middleware.ContextUserRoleKey).(string)
+
+	if config.AppConfig.ViewSandboxOrigin != "" {
+		// access is checked here and again on the sandbox origin :
+		if !services.CanReadFile(userID, role, meta) {
+			audit(r, services.AuditEvent{Action: services.AuditFileView, TargetType: "file", TargetID: strconv.Itoa(meta.ID), Outcome: services.AuditDenied}, nil)
+			http.Error(w, "Forbidden: private file", http.StatusForbidden)
+			return
+		}
+		grant := services.ViewGrant{FileID: meta.ID}
+		if userID != 0 {
+			// the link carries the session it was made with (already checked by SoftAuthMiddleware) :
+			claims, err := sessionClaims(r)
+			if err != nil {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+			grant = services.ViewGrantFromClaims(meta.ID, claims)
+		}
+		link, err := services.SandboxViewURL(grant)
+		if err != nil {
+			http.Error(w, "Failed to sign view link", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		http.Redirect(w, r, link, http.StatusTemporaryRedirect)
+		return
+	}
+	serveInline(w, r, meta, userID, role)
+}
+
+// SandboxViewHandler – serves a signed /api/view/{token} link, only on the VIEW_SANDBOX_ORIGIN host
+func SandboxViewHandler(w http.ResponseWriter, r *http.Request) {
+	sandbox, err := url.Parse(config.AppConfig.ViewSandboxOrigin)
+	if config.AppConfig.ViewSandboxOrigin == "" || err != nil || !strings.EqualFold(r.Host, sandbox.Host) {
+		http.NotFound(w, r)
+		return
+	}
+	grant, err := services.ParseViewToken(mux.Vars(r)["token"])
+	if err == services.ErrInvalidToken {
+		http.Error(w, "Invalid or expired link", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	meta, status, err := lookupFile(strconv.Itoa(grant.FileID))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// no session cookie on this origin : the view is audited as the link's viewer (and admin) :
+	if grant.UserID != 0 {
+		ctx := context.WithValue(r.Context(), middleware.ContextUserIDKey, grant.UserID)
+		ctx = context.WithValue(ctx, middleware.ContextUserRoleKey, grant.Role)
+		ctx = context.WithValue(ctx, middleware.ContextUsernameKey, grant.Username)
+		if grant.ImpersonatorID != 0 {
+			ctx = context.WithValue(ctx, middleware.ContextImpersonatorIDKey, grant.ImpersonatorID)
+			ctx = context.WithValue(ctx, middleware.ContextImpersonatorNameKey, grant.ImpersonatorUsername)
+		}
+		r = r.WithContext(ctx)
+	}
+	serveInline(w, r, meta, grant.UserID, grant.Role)
+}
+
+// sessionClaims reads the claims of the request's session cookie :
+func sessionClaims(r *http.Request) (*models.Claims, error) {
+	cookie, err := r.Cookie("token")
+	if err != nil {
+		return nil, err
+	}
+	return services.ParseJWT(cookie.Value)
+}
+
+// serveInline checks read access and streams the file with its real type, inline, under a strict CSP.
+// Ranges are supported (media seeking); only the first request of a view is audited.
+func serveInline(w http.ResponseWriter, r *http.Request, meta *services.FileMeta, userID int, role string) {
+	id := strconv.Itoa(meta.ID)
+	if !services.CanReadFile(userID, role, meta) {
+		audit(r, services.AuditEvent{Action: services.AuditFileView, TargetType: "file", TargetID: id, Outcome: services.AuditDenied}, nil)
+		http.Error(w, "Forbidden: private file", http.StatusForbidden)
+		return
+	}
+	contentType, rendering, err := services.InlineType(meta.Filename, meta.MimeType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	f, err := os.Open(filepath.Clean(meta.Filepath))
+	if err != nil {
+		http.Error(w, "File missing on server", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	if rng := r.Header.Get("Range"); rng == "" || strings.HasPrefix(rng, "bytes=0-") {
+		audit(r, services.AuditEvent{Action: services.AuditFileView, TargetType: "file", TargetID: id, Outcome: services.AuditSuccess},
+			map[string]interface{}{"filename": meta.Filename, "content_type": contentType})
+		services.RecordFileView(userID, meta.ID)
+	}
+
+	disposition := mime.FormatMediaType("inline", map[string]string{"filename": meta.Filename})
+	if disposition == "" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Security-Policy", services.InlineCSP(rendering))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, "", meta.UploadedAt, f)
+}
@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/config"
+)
+
+// Mailer sends plain-text emails to a single recipient :
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Default is the mailer picked from config on app booting :
+var Default Mailer = &LogMailer{}
+
+// Init selects the mailer implementation from MAILER (smtp | log) :
+func Init() {
+	cfg := config.AppConfig
+	switch cfg.Mailer {
+	case "smtp":
+		Default = &SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUser,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	default:
+		Default = &LogMailer{Path: cfg.MailLogFile}
+	}
+}
+
+// Send delivers an email through the default mailer :
+func Send(to, subject, body string) error {
+	return Default.Send(to, subject, body)
+}
+
+// SMTPMailer sends emails through an SMTP relay :
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send builds a minimal RFC 5322 message and hands it to the relay :
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	if m.Host == "" {
+		return fmt.Errorf("SMTP_HOST not configured")
+	}
+
+	// rejecting header injection through recipient / subject :
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header value")
+	}
+
+	msg := "From: " + m.From + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" + body
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer writes emails to a file (or the app log) instead of sending them,
+// meant for local development and tests :
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Send appends the email to Path, or logs it when Path is empty :
+func (m *LogMailer) Send(to, subject, body string) error {
+	entry := fmt.Sprintf("=== %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), to, subject, body)
+
+	if m.Path == "" {
+		log.Printf("📧 mail (not sent):\n%s", entry)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(entry)
+	return err
+}
@@ -8,6 +8,7 @@ import (
 
 	"backend/internal/config"
 	"backend/internal/models"
+	"backend/internal/services"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -18,6 +19,12 @@ type contextKey string
 // exported keys for handlers :
 const ContextUserIDKey = contextKey("userID")
 const ContextUserRoleKey = contextKey("role")
+const ContextUsernameKey = contextKey("username")
+
+// only set on impersonated requests (the admin behind the token) :
+const ContextImpersonatorIDKey = contextKey("impersonatorID")
+const ContextImpersonatorNameKey = contextKey("impersonatorName")
+const ContextAllowDestructiveKey = contextKey("allowDestructive")
 
 
 // fn. for validating JWT & adding user info to context :
@@ -49,11 +56,33 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// rejecting tokens of deactivated / deleted / force-logged-out users :
+		if err := services.CheckSession(claims.UserID, claims.Session); err == services.ErrAccountInactive || err == services.ErrSessionRevoked {
+			http.Error(w, "Session is no longer valid: "+err.Error(), http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Printf("❌ session check failed: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 
 		//  storing userID  & role in context for handlers
 		ctx := context.WithValue(r.Context(), ContextUserIDKey, claims.UserID)
 		ctx = context.WithValue(ctx,ContextUserRoleKey,claims.Role)
-		// calling next handler :
-		next.ServeHTTP(w, r.WithContext(ctx))
+		ctx = context.WithValue(ctx, ContextUsernameKey, claims.Username)
+
+		if claims.ImpersonatorID == 0 {
+			// calling next handler :
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// impersonation token : the admin's own session must still be valid too :
+		if err := serveImpersonated(w, r.WithContext(ctx), claims, next); err == services.ErrAccountInactive || err == services.ErrSessionRevoked {
+			http.Error(w, "Impersonation is no longer valid: "+err.Error(), http.StatusUnauthorized)
+		} else if err != nil {
+			log.Printf("❌ session check failed: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
 	})
 }
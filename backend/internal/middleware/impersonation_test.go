@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"backend/internal/db"
+	"backend/internal/models"
+	"backend/internal/services"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	_ "github.com/lib/pq"
+)
+
+// testDB connects to the migrated database in TEST_DB_URL, skipping the test without one :
+func testDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set, skipping database test")
+	}
+	conn, err := sql.Open("postgres", url)
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		t.Skipf("test database unavailable: %v", err)
+	}
+	prev := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db.DB = prev
+	})
+}
+
+// testUser creates a user removed after the test, returning its id & session version :
+func testUser(t *testing.T, prefix string, role string) (int, string, int) {
+	t.Helper()
+	name := fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+	var id, session int
+	err := db.DB.QueryRow(`
+		INSERT INTO users (username, email, password, role)
+		VALUES ($1, $1 || '@example.com', 'x', $2) RETURNING id, session_version`, name, role,
+	).Scan(&id, &session)
+	if err != nil {
+		t.Fatalf("creating test user: %v", err)
+	}
+	t.Cleanup(func() { db.DB.Exec(`DELETE FROM users WHERE id = $1`, id) })
+	return id, name, session
+}
+
+func TestImpersonatedRequestAuditedWithLongPath(t *testing.T) {
+	testDB(t)
+	adminID, adminName, adminSession := testUser(t, "imp_admin", "admin")
+	userID, userName, userSession := testUser(t, "imp_user", "user")
+	claims := &models.Claims{
+		UserID: userID, Username: userName, Role: "user", Session: userSession,
+		ImpersonatorID: adminID, ImpersonatorUsername: adminName, ImpersonatorSession: adminSession,
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "short path", path: "/api/fileDetails/5", status: http.StatusOK},
+		{name: "zero padded id", path: "/api/fileDetails/" + strings.Repeat("0", 300) + "5", status: http.StatusOK},
+		{name: "multi-byte padding", path: "/api/fileDetails/" + strings.Repeat("é", 200), status: http.StatusOK},
+		{name: "refused route", path: "/api/fileDelete/" + strings.Repeat("0", 300) + "5", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before int64
+			if err := db.DB.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM audit_events`).Scan(&before); err != nil {
+				t.Fatalf("reading audit head: %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.URL.Path = tt.path
+			ctx := context.WithValue(r.Context(), ContextUserIDKey, userID)
+			ctx = context.WithValue(ctx, ContextUserRoleKey, "user")
+			ctx = context.WithValue(ctx, ContextUsernameKey, userName)
+			w := httptest.NewRecorder()
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("file details"))
+			})
+			if err := serveImpersonated(w, r.WithContext(ctx), claims, next); err != nil {
+				t.Fatalf("serveImpersonated: %v", err)
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+
+			var targetID, details string
+			err := db.DB.QueryRow(`
+				SELECT target_id, details FROM audit_events
+				WHERE id > $1 AND action = $2 AND impersonator_id = $3
+				ORDER BY id DESC LIMIT 1`, before, services.AuditImpersonateRequest, adminID,
+			).Scan(&targetID, &details)
+			if err == sql.ErrNoRows {
+				t.Fatalf("request to %d-char path served without an audit row", len(tt.path))
+			}
+			if err != nil {
+				t.Fatalf("reading audit row: %v", err)
+			}
+			if n := utf8.RuneCountInString(targetID); n > services.AuditTargetIDMax {
+				t.Errorf("target_id has %d characters", n)
+			}
+			if !strings.HasPrefix("GET "+tt.path, targetID) {
+				t.Errorf("target_id %q isn't a prefix of the route", targetID)
+			}
+			if !strings.Contains(details, tt.path) {
+				t.Errorf("details %s don't hold the full path", details)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/services"
+	"backend/internal/utils"
+)
+
+// RequirePermission only lets the request through if the caller's role grants
+// every listed permission. Must be wrapped by AuthMiddleware.
+func RequirePermission(perms ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, ok := r.Context().Value(ContextUserRoleKey).(string)
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			// checking every permission :
+			for _, p := range perms {
+				if !services.HasPermission(role, p) {
+					auditDenied(r, p)
+					http.Error(w, "Forbidden: missing permission "+p, http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// auditDenied records a request refused for a missing permission :
+func auditDenied(r *http.Request, perm string) {
+	e := services.AuditEvent{
+		Action:     services.AuditPermissionDenied,
+		TargetType: "route",
+		TargetID:   routeTarget(r),
+		IP:         utils.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+		Outcome:    services.AuditDenied,
+	}
+	if userID, ok := r.Context().Value(ContextUserIDKey).(int); ok {
+		e.ActorID = &userID
+	}
+	e.ActorUsername, _ = r.Context().Value(ContextUsernameKey).(string)
+	if impID, ok := r.Context().Value(ContextImpersonatorIDKey).(int); ok {
+		e.ImpersonatorID = &impID
+		e.ImpersonatorUsername, _ = r.Context().Value(ContextImpersonatorNameKey).(string)
+	}
+	e.Details, _ = json.Marshal(map[string]string{"permission": perm, "path": r.URL.Path})
+	services.RecordAudit(e)
+}
@@ -7,6 +7,7 @@ import (
 
 	"backend/internal/config"
 	"backend/internal/models"
+	"backend/internal/services"
 
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -14,16 +15,31 @@ import (
 // ParseJWTFromRequest attempts to read the "token" cookie and parse JWT.
 // Returns userID, role, nil on success. Returns non-nil error when no token or invalid.
 func ParseJWTFromRequest(r *http.Request) (int, string, error) {
+	claims, err := parseSoftClaims(r)
+	if err != nil {
+		return 0, "", err
+	}
+	// an impersonation token is only as valid as the admin's session :
+	if claims.ImpersonatorID != 0 {
+		if err := services.CheckSession(claims.ImpersonatorID, claims.ImpersonatorSession); err != nil {
+			return 0, "", err
+		}
+	}
+	return claims.UserID, claims.Role, nil
+}
+
+// parseSoftClaims reads & validates the "token" cookie, checking the user's session :
+func parseSoftClaims(r *http.Request) (*models.Claims, error) {
 	// JWT secret
 	jwtKey := []byte(config.AppConfig.JWTKey)
 	if len(jwtKey) == 0 {
-		return 0, "", fmt.Errorf("JWT_KEY not configured")
+		return nil, fmt.Errorf("JWT_KEY not configured")
 	}
 
 	// reading cookie  for token: 
 	cookie, err := r.Cookie("token")
 	if err != nil {
-		return 0, "", err 
+		return nil, err 
 	}
 	tokenStr := cookie.Value
 
@@ -36,23 +52,40 @@ func ParseJWTFromRequest(r *http.Request) (int, string, error) {
 		return jwtKey, nil
 	})
 	if err != nil || !token.Valid {
-		return 0, "", fmt.Errorf("invalid token: %v", err)
+		return nil, fmt.Errorf("invalid token: %v", err)
 	}
 
-	return claims.UserID, claims.Role, nil
+	// revoked sessions fall back to guest :
+	if err := services.CheckSession(claims.UserID, claims.Session); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
 }
 
 // SoftAuthMiddleware will parse JWT if present and set user id & role in context.
 // It will NOT reject requests without a valid token — it continues as guest.
 func SoftAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if uid, role, err := ParseJWTFromRequest(r); err == nil {
-			// set values in context only if token parsed OK
-			ctx := context.WithValue(r.Context(), ContextUserIDKey, uid)
-			ctx = context.WithValue(ctx, ContextUserRoleKey, role)
-			r = r.WithContext(ctx)
+		claims, err := parseSoftClaims(r)
+		if err != nil {
+			// continue as guest
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// set values in context only if token parsed OK
+		ctx := context.WithValue(r.Context(), ContextUserIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, ContextUserRoleKey, claims.Role)
+		ctx = context.WithValue(ctx, ContextUsernameKey, claims.Username)
+		if claims.ImpersonatorID == 0 {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		// impersonated : same checks & audit as AuthMiddleware, an ended admin session means guest :
+		if err := serveImpersonated(w, r.WithContext(ctx), claims, next); err != nil {
+			next.ServeHTTP(w, r)
 		}
-		// continue in all cases (guest or authenticated)
-		next.ServeHTTP(w, r)
 	})
 }
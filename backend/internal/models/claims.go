@@ -8,5 +8,13 @@ type Claims struct {
 	UserID   int    `json:"userID"`   // unique user ID
 	Username string `json:"username"` // unique username of the user
 	Role     string `json:"role"`     // user role (admin/user)
+	Session  int    `json:"sv"`       // users.session_version at issue time
+
+	// set only on impersonation tokens (an admin acting as UserID) :
+	ImpersonatorID       int    `json:"imp,omitempty"`
+	ImpersonatorUsername string `json:"imp_name,omitempty"`
+	ImpersonatorSession  int    `json:"imp_sv,omitempty"`
+	AllowDestructive     bool   `json:"imp_rw,omitempty"`
+
 	jwt.RegisteredClaims              // standard JWT fields
 }
@@ -9,23 +9,64 @@ import (
 
 // User represents a row in the users table
 type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
+	ID            int    `json:"id"`
+	Username      string `json:"username"`
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	Role          string `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
 }
 
 // GetUserByID fetches a user by their ID
 func GetUserByID(id int) (*User, error) {
 	row := db.DB.QueryRow(`
-        SELECT id, username, email, role
+        SELECT id, username, email, role, email_verified
         FROM users
         WHERE id = $1
     `, id)
 
 	u := &User{}
-	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role)
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.EmailVerified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// GetUserByEmail fetches a user by their email (case-insensitive)
+func GetUserByEmail(email string) (*User, error) {
+	row := db.DB.QueryRow(`
+        SELECT id, username, email, role, email_verified
+        FROM users
+        WHERE LOWER(email) = LOWER($1)
+    `, email)
+
+	u := &User{}
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.EmailVerified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// GetUserByUsername fetches a user by their username
+func GetUserByUsername(username string) (*User, error) {
+	row := db.DB.QueryRow(`
+        SELECT id, username, email, role, email_verified
+        FROM users
+        WHERE username = $1
+    `, username)
+
+	u := &User{}
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -0,0 +1,64 @@
+package services
+
+import "log"
+
+// how a reader got access to a file (recorded with every download) :
+const (
+	AccessViaOwner  = "owner"
+	AccessViaTeam   = "team"
+	AccessViaPublic = "public"
+	AccessViaAdmin  = "admin"
+)
+
+// CanReadFile reports whether the caller may view / download the file :
+// public files, the uploader, members of the file's team, or roles with files.read.any
+// (only the latter once the file is quarantined).
+func CanReadFile(userID int, role string, f *FileMeta) bool {
+	return ReadAccessVia(userID, role, f) != ""
+}
+
+// ReadAccessVia returns which rule grants the caller read access, "" when none does :
+func ReadAccessVia(userID int, role string, f *FileMeta) string {
+	// quarantined files are only readable with files.read.any :
+	if f.QuarantinedAt != nil {
+		if role != "" && HasPermission(role, PermFilesReadAny) {
+			return AccessViaAdmin
+		}
+		return ""
+	}
+	if userID != 0 && f.UploaderID == userID {
+		return AccessViaOwner
+	}
+	if userID != 0 && f.TeamID != nil {
+		teamRole, err := GetTeamRole(*f.TeamID, userID)
+		if err != nil {
+			log.Printf("❌ team role lookup failed: %v", err)
+		} else if teamRole != "" {
+			return AccessViaTeam
+		}
+	}
+	if f.IsPublic {
+		return AccessViaPublic
+	}
+	if role != "" && HasPermission(role, PermFilesReadAny) {
+		return AccessViaAdmin
+	}
+	return ""
+}
+
+// CanModifyFile reports whether the caller may delete / change the file :
+// the uploader, owners & admins of the file's team, or roles with files.manage.any.
+func CanModifyFile(userID int, role string, f *FileMeta) bool {
+	if userID != 0 && f.UploaderID == userID {
+		return true
+	}
+	if userID != 0 && f.TeamID != nil {
+		teamRole, err := GetTeamRole(*f.TeamID, userID)
+		if err != nil {
+			log.Printf("❌ team role lookup failed: %v", err)
+		} else if IsTeamManager(teamRole) {
+			return true
+		}
+	}
+	return role != "" && HasPermission(role, PermFilesManageAny)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/mailer"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// SendVerificationEmail issues a verification token and mails the link :
+func SendVerificationEmail(userID int, email string) error {
+	ttl := time.Duration(config.AppConfig.VerifyTokenTTLHours) * time.Hour
+	token, err := CreateUserToken(userID, TokenPurposeEmailVerify, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := config.AppConfig.AppBaseURL + "/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		"Welcome to FileVault!\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n",
+		link, config.AppConfig.VerifyTokenTTLHours,
+	)
+	return mailer.Send(email, "Verify your FileVault email", body)
+}
+
+// SendPasswordResetEmail issues a reset token and mails the link :
+func SendPasswordResetEmail(userID int, email string) error {
+	ttl := time.Duration(config.AppConfig.ResetTokenTTLMinutes) * time.Minute
+	token, err := CreateUserToken(userID, TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := config.AppConfig.AppBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf(
+		"A password reset was requested for your FileVault account.\n\nOpen the link below to choose a new password:\n\n%s\n\nThe link expires in %d minutes. If you did not request this, you can ignore this email.\n",
+		link, config.AppConfig.ResetTokenTTLMinutes,
+	)
+	return mailer.Send(email, "Reset your FileVault password", body)
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"backend/internal/db"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// activity kinds :
+const (
+	ActivityUpload     = "file.upload"   // to the uploader and the team's members
+	ActivityDelete     = "file.delete"   // to the owner and the team's members
+	ActivityReceived   = "file.received" // files transferred to the user
+	ActivityTeamJoined = "team.joined"   // added to a team, its files became readable
+	ActivityComment    = "comment.add"   // to the file's owner, team members and the thread's author
+)
+
+// Activity is one entry of a user's feed :
+type Activity struct {
+	ID        int64                  `json:"id"`
+	Kind      string                 `json:"kind"`
+	ActorID   *int                   `json:"actor_id"`
+	Actor     string                 `json:"actor"`
+	FileID    *int                   `json:"file_id"` // null once the file is gone
+	Filename  string                 `json:"filename,omitempty"`
+	TeamID    *int                   `json:"team_id"`
+	TeamName  string                 `json:"team_name,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// ActivityAudience returns userIDs plus, for team content, every member of the team :
+func ActivityAudience(teamID *int, userIDs ...int) []int {
+	if teamID == nil {
+		return userIDs
+	}
+	members, err := ListTeamMembers(*teamID)
+	if err != nil {
+		log.Printf("❌ activity audience lookup failed: %v", err)
+		return userIDs
+	}
+	for _, m := range members {
+		userIDs = append(userIDs, m.UserID)
+	}
+	return userIDs
+}
+
+// RecordActivity adds the event to the feed of every recipient (once each). The actor's name is
+// looked up from ActorID. Failures are only logged : the feed never blocks the action itself.
+func RecordActivity(a Activity, recipients []int) {
+	recipients = uniqueInts(recipients)
+	if len(recipients) == 0 {
+		return
+	}
+	var details interface{}
+	if a.Details != nil {
+		raw, _ := json.Marshal(a.Details)
+		details = string(raw)
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO activities (user_id, kind, actor_id, actor_username, file_id, filename, team_id, details)
+		SELECT r, $2, $3, (SELECT username FROM users WHERE id = $3), $4, NULLIF($5, ''), $6, $7::jsonb
+		FROM unnest($1::int[]) AS r`,
+		pq.Array(recipients), a.Kind, a.ActorID, a.FileID, a.Filename, a.TeamID, details,
+	)
+	if err != nil {
+		log.Printf("❌ recording %s activity failed: %v", a.Kind, err)
+	}
+}
+
+// ListActivity returns a page of the user's feed, newest first, and the cursor of the next page
+// ("" on the last one). cursor is the next_cursor of the previous page.
+func ListActivity(userID int, cursor string, limit int) ([]Activity, string, error) {
+	before := int64(1<<63 - 1)
+	if cursor != "" {
+		n, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || n <= 0 {
+			return nil, "", ErrInvalidCursor
+		}
+		before = n
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT a.id, a.kind, a.actor_id, COALESCE(a.actor_username, ''), a.file_id, COALESCE(a.filename, ''),
+		       a.team_id, COALESCE(t.name, ''), a.details, a.created_at
+		FROM activities a
+		LEFT JOIN teams t ON t.id = a.team_id
+		WHERE a.user_id = $1 AND a.id < $2
+		ORDER BY a.id DESC
+		LIMIT $3`, userID, before, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	feed := []Activity{}
+	for rows.Next() {
+		var a Activity
+		var details []byte
+		if err := rows.Scan(&a.ID, &a.Kind, &a.ActorID, &a.Actor, &a.FileID, &a.Filename,
+			&a.TeamID, &a.TeamName, &details, &a.CreatedAt); err != nil {
+			return nil, "", err
+		}
+		if details != nil {
+			_ = json.Unmarshal(details, &a.Details)
+		}
+		feed = append(feed, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(feed) > limit {
+		feed = feed[:limit]
+		next = strconv.FormatInt(feed[limit-1].ID, 10)
+	}
+	return feed, next, nil
+}
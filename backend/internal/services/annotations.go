@@ -0,0 +1,248 @@
+package services
+
+import (
+	"backend/internal/db"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// annotation limits :
+const (
+	MaxDescriptionLength = 2000 // characters
+	MaxTagsPerFile       = 20
+	MaxMetadataPerFile   = 50
+	maxMetadataValue     = 500 // characters
+)
+
+var (
+	ErrInvalidTag         = errors.New("tags are 1-50 letters, digits, spaces, '.', '_' or '-'")
+	ErrTooManyTags        = fmt.Errorf("at most %d tags per file", MaxTagsPerFile)
+	ErrInvalidMetadataKey = errors.New("metadata keys are 1-64 lower case letters, digits, '.', '_' or '-'")
+	ErrInvalidMetadata    = fmt.Errorf("metadata values are 1-%d characters", maxMetadataValue)
+	ErrTooManyMetadata    = fmt.Errorf("at most %d metadata keys per file", MaxMetadataPerFile)
+	ErrDescriptionTooLong = fmt.Errorf("description longer than %d characters", MaxDescriptionLength)
+	tagPattern            = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} ._-]{0,49}$`)
+	metadataKeyPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+	tagSpaces             = regexp.MustCompile(`\s+`)
+)
+
+// NormalizeTag trims, lower-cases and validates a tag :
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(tagSpaces.ReplaceAllString(strings.TrimSpace(tag), " "))
+	if !tagPattern.MatchString(tag) {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// NormalizeMetadataKey lower-cases and validates a metadata key :
+func NormalizeMetadataKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !metadataKeyPattern.MatchString(key) {
+		return "", ErrInvalidMetadataKey
+	}
+	return key, nil
+}
+
+// SetFileDescription replaces the description ("" clears it) :
+func SetFileDescription(fileID int, description string) error {
+	description = strings.TrimSpace(description)
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		return ErrDescriptionTooLong
+	}
+	if _, err := db.DB.Exec(`UPDATE files SET description = NULLIF($2, '') WHERE id = $1`, fileID, description); err != nil {
+		return err
+	}
+	return RefreshSearchVectors("f.id = $1", fileID)
+}
+
+// AddFileTags attaches tags (already normalized) to the file, returning its full tag list :
+func AddFileTags(fileID int, tags []string, by int) ([]string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// serializing tag changes of the file so the cap holds :
+	if _, err := tx.Exec(`SELECT 1 FROM files WHERE id = $1 FOR UPDATE`, fileID); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, tag); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO file_tags (file_id, tag_id, created_by)
+			SELECT $1, id, $3 FROM tags WHERE name = $2
+			ON CONFLICT (file_id, tag_id) DO NOTHING`, fileID, tag, by,
+		); err != nil {
+			return nil, err
+		}
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM file_tags WHERE file_id = $1`, fileID).Scan(&n); err != nil {
+		return nil, err
+	}
+	if n > MaxTagsPerFile {
+		return nil, ErrTooManyTags
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := RefreshSearchVectors("f.id = $1", fileID); err != nil {
+		return nil, err
+	}
+	return FileTags(fileID)
+}
+
+// RemoveFileTag detaches a tag, reporting whether the file had it (unused tags stay in tags,
+// autocomplete only offers tags of visible files anyway) :
+func RemoveFileTag(fileID int, tag string) (bool, error) {
+	res, err := db.DB.Exec(`
+		DELETE FROM file_tags
+		WHERE file_id = $1 AND tag_id = (SELECT id FROM tags WHERE name = $2)`, fileID, tag)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, RefreshSearchVectors("f.id = $1", fileID)
+}
+
+// FileTags lists the file's tags by name :
+func FileTags(fileID int) ([]string, error) {
+	rows, err := db.DB.Query(`
+		SELECT t.name FROM file_tags ft JOIN tags t ON t.id = ft.tag_id
+		WHERE ft.file_id = $1
+		ORDER BY t.name`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// SetFileMetadata sets one key (normalized) to value :
+func SetFileMetadata(fileID int, key string, value string, by int) error {
+	value = strings.TrimSpace(value)
+	if value == "" || utf8.RuneCountInString(value) > maxMetadataValue {
+		return ErrInvalidMetadata
+	}
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT 1 FROM files WHERE id = $1 FOR UPDATE`, fileID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO file_metadata (file_id, key, value, updated_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (file_id, key) DO UPDATE
+		SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()`,
+		fileID, key, value, by,
+	); err != nil {
+		return err
+	}
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM file_metadata WHERE file_id = $1`, fileID).Scan(&n); err != nil {
+		return err
+	}
+	if n > MaxMetadataPerFile {
+		return ErrTooManyMetadata
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return RefreshSearchVectors("f.id = $1", fileID)
+}
+
+// DeleteFileMetadata removes one key, reporting whether it was set :
+func DeleteFileMetadata(fileID int, key string) (bool, error) {
+	res, err := db.DB.Exec(`DELETE FROM file_metadata WHERE file_id = $1 AND key = $2`, fileID, key)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, RefreshSearchVectors("f.id = $1", fileID)
+}
+
+// FileMetadata returns the file's key / value pairs :
+func FileMetadata(fileID int) (map[string]string, error) {
+	rows, err := db.DB.Query(`SELECT key, value FROM file_metadata WHERE file_id = $1`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := map[string]string{}
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, err
+		}
+		meta[k] = v
+	}
+	return meta, rows.Err()
+}
+
+// LoadFileAnnotations fills the tags & metadata of f :
+func LoadFileAnnotations(f *FileMeta) error {
+	var err error
+	if f.Tags, err = FileTags(f.ID); err != nil {
+		return err
+	}
+	f.Metadata, err = FileMetadata(f.ID)
+	return err
+}
+
+// TagSuggestion is one autocomplete entry, with how many visible files carry the tag :
+type TagSuggestion struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+}
+
+// SuggestTags autocompletes tags from the files the user can read : their personal files, their
+// teams' files and public files (quarantined files excluded), most used first.
+func SuggestTags(userID int, prefix string, limit int) ([]TagSuggestion, error) {
+	rows, err := db.DB.Query(`
+		SELECT t.name, COUNT(*) AS n
+		FROM tags t
+		JOIN file_tags ft ON ft.tag_id = t.id
+		JOIN files f ON f.id = ft.file_id
+		WHERE t.name LIKE $2 AND f.quarantined_at IS NULL
+		  AND ((f.user_id = $1 AND f.team_id IS NULL)
+		       OR f.team_id IN (SELECT team_id FROM team_members WHERE user_id = $1)
+		       OR f.is_public = TRUE)
+		GROUP BY t.name
+		ORDER BY n DESC, t.name
+		LIMIT $3`, userID, escapeLike(strings.ToLower(strings.TrimSpace(prefix)))+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []TagSuggestion{}
+	for rows.Next() {
+		var s TagSuggestion
+		if err := rows.Scan(&s.Name, &s.Files); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
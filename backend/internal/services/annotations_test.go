@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", in: "invoice", want: "invoice"},
+		{name: "lower-cased", in: "Q3-Report", want: "q3-report"},
+		{name: "trimmed", in: "  archived \t", want: "archived"},
+		{name: "inner spaces collapsed", in: "to   be\treviewed", want: "to be reviewed"},
+		{name: "unicode letters", in: "Éte_2025", want: "éte_2025"},
+		{name: "punctuation allowed inside", in: "v1.2_final-b", want: "v1.2_final-b"},
+		{name: "50 characters", in: strings.Repeat("a", 50), want: strings.Repeat("a", 50)},
+		{name: "too long", in: strings.Repeat("a", 51), wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+		{name: "only spaces", in: "   ", wantErr: true},
+		{name: "leading punctuation", in: "-draft", wantErr: true},
+		{name: "hash", in: "#todo", wantErr: true},
+		{name: "comma", in: "a,b", wantErr: true},
+		{name: "slash", in: "a/b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeTag(tt.in)
+			if tt.wantErr {
+				if err != ErrInvalidTag {
+					t.Fatalf("NormalizeTag(%q) = %q, %v, want ErrInvalidTag", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeTag(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+			// normalizing twice changes nothing :
+			if again, _ := NormalizeTag(got); again != got {
+				t.Errorf("NormalizeTag(%q) = %q, not stable", got, again)
+			}
+		})
+	}
+}
+
+func TestNormalizeMetadataKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", in: "client", want: "client"},
+		{name: "lower-cased and trimmed", in: " Client.Name ", want: "client.name"},
+		{name: "digits and separators", in: "po_number-2", want: "po_number-2"},
+		{name: "64 characters", in: strings.Repeat("k", 64), want: strings.Repeat("k", 64)},
+		{name: "too long", in: strings.Repeat("k", 65), wantErr: true},
+		{name: "empty", in: "", wantErr: true},
+		{name: "inner space", in: "client name", wantErr: true},
+		{name: "colon", in: "client:acme", wantErr: true},
+		{name: "leading dot", in: ".hidden", wantErr: true},
+		{name: "non-ascii letter", in: "année", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeMetadataKey(tt.in)
+			if tt.wantErr {
+				if err != ErrInvalidMetadataKey {
+					t.Fatalf("NormalizeMetadataKey(%q) = %q, %v, want ErrInvalidMetadataKey", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeMetadataKey(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"backend/internal/db"
+	"backend/internal/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// audit outcomes :
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+	AuditDenied  = "denied"
+)
+
+// audited actions, grouped by prefix so they can be filtered with "auth.*" etc. :
+const (
+	AuditLogin                = "auth.login"
+	AuditLogout               = "auth.logout"
+	AuditSignup               = "auth.signup"
+	AuditPasswordResetRequest = "auth.password_reset_request"
+	AuditPasswordReset        = "auth.password_reset"
+	AuditPasswordChange       = "auth.password_change"
+	AuditEmailVerify          = "auth.email_verify"
+
+	AuditFileUpload       = "file.upload"
+	AuditFileDownload     = "file.download"
+	AuditFileView         = "file.view"
+	AuditFileDelete       = "file.delete"
+	AuditFileVisibility   = "file.visibility"
+	AuditFileTransfer     = "file.transfer"
+	AuditFileQuarantine   = "file.quarantine"
+	AuditFileUnquarantine = "file.unquarantine"
+	AuditFileAnnotate     = "file.annotate"
+	AuditCommentDelete    = "file.comment_delete"
+
+	AuditTeamCreate       = "team.create"
+	AuditTeamMemberAdd    = "team.member_add"
+	AuditTeamMemberUpdate = "team.member_update"
+	AuditTeamMemberRemove = "team.member_remove"
+
+	AuditUserRoleChange = "admin.user_role_change"
+	AuditUserActivate   = "admin.user_activate"
+	AuditUserDeactivate = "admin.user_deactivate"
+	AuditUserLogout     = "admin.user_force_logout"
+	AuditUserDelete     = "admin.user_delete"
+	AuditRoleSave       = "admin.role_save"
+	AuditRoleDelete     = "admin.role_delete"
+	AuditLockoutClear   = "admin.lockout_clear"
+	AuditQuotaChange    = "admin.quota_change"
+	AuditExport         = "admin.audit_export"
+	AuditBulkJob        = "admin.bulk_job"
+
+	AuditPermissionDenied = "access.permission_denied"
+
+	AuditImpersonateStart   = "impersonation.start"
+	AuditImpersonateStop    = "impersonation.stop"
+	AuditImpersonateRequest = "impersonation.request"
+)
+
+// AuditTargetIDMax is the size of audit_events.target_id, longer ids are cut :
+const AuditTargetIDMax = 128
+
+// AuditEvent is one row of the append-only audit log :
+type AuditEvent struct {
+	ID            int64           `json:"id"`
+	ActorID       *int            `json:"actor_id"`
+	ActorUsername string          `json:"actor_username"`
+	Action        string          `json:"action"`
+	TargetType    string          `json:"target_type"`
+	TargetID      string          `json:"target_id"`
+	IP            string          `json:"ip"`
+	UserAgent     string          `json:"user_agent"`
+	Outcome       string          `json:"outcome"`
+	Details       json.RawMessage `json:"details"`
+	CreatedAt     time.Time       `json:"created_at"`
+	PrevHash      string          `json:"prev_hash"`
+	Hash          string          `json:"hash"`
+
+	// the admin really behind the action when impersonating :
+	ImpersonatorID       *int   `json:"impersonator_id,omitempty"`
+	ImpersonatorUsername string `json:"impersonator_username,omitempty"`
+}
+
+// RecordAudit appends an event, chained to the previous one (see auditchain.go).
+// Failures are logged, never returned : the action being audited has already
+// happened and the caller can't undo it.
+func RecordAudit(e AuditEvent) {
+	if len(e.Details) == 0 {
+		e.Details = json.RawMessage("{}")
+	}
+	// an over-long value would fail the insert and lose the event : every bounded column is cut to
+	// its size (on a rune boundary) before the event is hashed :
+	e.ActorUsername = utils.TruncateRunes(e.ActorUsername, 50)
+	e.ImpersonatorUsername = utils.TruncateRunes(e.ImpersonatorUsername, 50)
+	e.Action = utils.TruncateRunes(e.Action, 64)
+	e.TargetType = utils.TruncateRunes(e.TargetType, 32)
+	e.TargetID = utils.TruncateRunes(e.TargetID, AuditTargetIDMax)
+	e.IP = utils.TruncateRunes(e.IP, 64)
+	e.Outcome = utils.TruncateRunes(e.Outcome, 16)
+	e.UserAgent = utils.TruncateRunes(e.UserAgent, 512)
+
+	if err := appendAuditEvent(&e); err != nil {
+		log.Printf("❌ audit write failed (%s %s/%s): %v", e.Action, e.TargetType, e.TargetID, err)
+	}
+}
+
+// AuditFilter narrows audit queries; zero values are ignored :
+type AuditFilter struct {
+	ActorID      *int
+	Actor        string // username
+	Impersonator *int   // only actions done while impersonating, by this admin
+	Action       string // exact, or a prefix ending in "*" (e.g. "file.*")
+	TargetType   string
+	TargetID     string
+	Outcome      string
+	IP           string
+	From         *time.Time
+	To           *time.Time
+}
+
+// where builds the WHERE clause and its args for f :
+func (f AuditFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.ActorID != nil {
+		add("actor_id = $%d", *f.ActorID)
+	}
+	if f.Actor != "" {
+		add("actor_username = $%d", f.Actor)
+	}
+	if f.Impersonator != nil {
+		add("impersonator_id = $%d", *f.Impersonator)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, "*") {
+			prefix := strings.TrimSuffix(f.Action, "*")
+			prefix = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)
+			add("action LIKE $%d", prefix+"%")
+		} else {
+			add("action = $%d", f.Action)
+		}
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Outcome != "" {
+		add("outcome = $%d", f.Outcome)
+	}
+	if f.IP != "" {
+		add("ip = $%d", f.IP)
+	}
+	if f.From != nil {
+		add("created_at >= $%d", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < $%d", *f.To)
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+const auditColumns = `id, actor_id, actor_username, action, target_type, target_id, ip, user_agent, outcome, details, created_at, prev_hash, hash, impersonator_id, impersonator_username`
+
+// scanAuditEvent reads one audit_events row selected with auditColumns :
+func scanAuditEvent(rows *sql.Rows) (AuditEvent, error) {
+	var e AuditEvent
+	var details string
+	var prevHash, hash, impersonator sql.NullString
+	err := rows.Scan(&e.ID, &e.ActorID, &e.ActorUsername, &e.Action, &e.TargetType, &e.TargetID,
+		&e.IP, &e.UserAgent, &e.Outcome, &details, &e.CreatedAt, &prevHash, &hash,
+		&e.ImpersonatorID, &impersonator)
+	e.ImpersonatorUsername = impersonator.String
+	e.Details = json.RawMessage(details)
+	e.PrevHash = prevHash.String
+	e.Hash = hash.String
+	return e, err
+}
+
+// QueryAuditEvents returns one page of matching events (newest first) and the total match count :
+func QueryAuditEvents(f AuditFilter, limit int, offset int) ([]AuditEvent, int, error) {
+	where, args := f.where()
+
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM audit_events%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		auditColumns, where, len(args)+1, len(args)+2)
+	rows, err := db.DB.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := make([]AuditEvent, 0)
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	return events, total, rows.Err()
+}
+
+// ExportAuditEvents streams every matching event (oldest first) to fn without buffering them :
+func ExportAuditEvents(f AuditFilter, fn func(AuditEvent) error) error {
+	where, args := f.where()
+	rows, err := db.DB.Query(`SELECT `+auditColumns+` FROM audit_events`+where+` ORDER BY id ASC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
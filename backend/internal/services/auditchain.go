@@ -0,0 +1,425 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// AuditGenesisHash is the prev_hash of the very first event :
+var AuditGenesisHash = strings.Repeat("0", 64)
+
+// advisory lock serialising writers, so ids and the chain follow the same order :
+const auditChainLockKey = 0x61756469
+
+// ErrNoAuditSigner is returned when AUDIT_SIGNING_KEY / AUDIT_PUBLIC_KEY aren't usable :
+var ErrNoAuditSigner = errors.New("no audit signing key configured")
+
+// auditCanonical is what gets hashed; field order is fixed by the struct.
+// details is hashed as the exact stored text, created_at in UTC with microseconds.
+type auditCanonical struct {
+	ID            int64  `json:"id"`
+	ActorID       *int   `json:"actor_id"`
+	ActorUsername string `json:"actor_username"`
+	Action        string `json:"action"`
+	TargetType    string `json:"target_type"`
+	TargetID      string `json:"target_id"`
+	IP            string `json:"ip"`
+	UserAgent     string `json:"user_agent"`
+	Outcome       string `json:"outcome"`
+	Details       string `json:"details"`
+	CreatedAt     string `json:"created_at"`
+
+	// omitted when empty, so events from before impersonation existed keep their hash :
+	ImpersonatorID       *int   `json:"impersonator_id,omitempty"`
+	ImpersonatorUsername string `json:"impersonator_username,omitempty"`
+}
+
+// AuditHash computes SHA-256(prevHash + "\n" + canonical JSON of e) as hex :
+func AuditHash(prevHash string, e *AuditEvent) string {
+	canon, _ := json.Marshal(auditCanonical{
+		ID:            e.ID,
+		ActorID:       e.ActorID,
+		ActorUsername: e.ActorUsername,
+		Action:        e.Action,
+		TargetType:    e.TargetType,
+		TargetID:      e.TargetID,
+		IP:            e.IP,
+		UserAgent:     e.UserAgent,
+		Outcome:       e.Outcome,
+		Details:       string(e.Details),
+		CreatedAt:     e.CreatedAt.UTC().Format(time.RFC3339Nano),
+
+		ImpersonatorID:       e.ImpersonatorID,
+		ImpersonatorUsername: e.ImpersonatorUsername,
+	})
+	sum := sha256.Sum256(append([]byte(prevHash+"\n"), canon...))
+	return hex.EncodeToString(sum[:])
+}
+
+// appendAuditEvent inserts e as the new head of the chain :
+func appendAuditEvent(e *AuditEvent) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLockKey); err != nil {
+		return err
+	}
+	prev, err := auditChainHead(tx)
+	if err != nil {
+		return err
+	}
+
+	// id & timestamp are fixed here because both are part of the hash :
+	if err := tx.QueryRow(`SELECT nextval(pg_get_serial_sequence('audit_events', 'id'))`).Scan(&e.ID); err != nil {
+		return err
+	}
+	e.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	e.PrevHash = prev
+	e.Hash = AuditHash(prev, e)
+
+	_, err = tx.Exec(`
+		INSERT INTO audit_events (id, actor_id, actor_username, action, target_type, target_id, ip, user_agent, outcome, details,
+			created_at, prev_hash, hash, impersonator_id, impersonator_username)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''))`,
+		e.ID, e.ActorID, e.ActorUsername, e.Action, e.TargetType, e.TargetID, e.IP, e.UserAgent, e.Outcome,
+		string(e.Details), e.CreatedAt, e.PrevHash, e.Hash, e.ImpersonatorID, e.ImpersonatorUsername,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// auditChainHead returns the hash of the newest event, chaining older unhashed
+// events first (rows written before the chain existed). Caller holds the lock.
+func auditChainHead(tx *sql.Tx) (string, error) {
+	var head sql.NullString
+	err := tx.QueryRow(`SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&head)
+	if err == sql.ErrNoRows {
+		return AuditGenesisHash, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if head.Valid {
+		return head.String, nil
+	}
+
+	// backlog : unhashed rows can only precede the first hashed one, so they start at genesis :
+	rows, err := tx.Query(`SELECT ` + auditColumns + ` FROM audit_events WHERE hash IS NULL ORDER BY id ASC`)
+	if err != nil {
+		return "", err
+	}
+	var backlog []AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			rows.Close()
+			return "", err
+		}
+		backlog = append(backlog, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	prev := AuditGenesisHash
+	for i := range backlog {
+		hash := AuditHash(prev, &backlog[i])
+		if _, err := tx.Exec(`UPDATE audit_events SET prev_hash=$2, hash=$3 WHERE id=$1`, backlog[i].ID, prev, hash); err != nil {
+			return "", err
+		}
+		prev = hash
+	}
+	log.Printf("ℹ️ chained %d audit events written before the hash chain", len(backlog))
+	return prev, nil
+}
+
+// ChainAuditBacklog hashes events written before the chain existed (no-op otherwise) :
+func ChainAuditBacklog() error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, auditChainLockKey); err != nil {
+		return err
+	}
+	if _, err := auditChainHead(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---------- signed checkpoints ----------
+
+// AuditCheckpoint is a signed snapshot of the chain head :
+type AuditCheckpoint struct {
+	ID        int       `json:"id"`
+	EventID   int64     `json:"event_id"`
+	EventHash string    `json:"event_hash"`
+	Algorithm string    `json:"algorithm"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// message is the exact byte string that gets signed :
+func (c *AuditCheckpoint) message() []byte {
+	return []byte(fmt.Sprintf("filevault-audit-checkpoint/v1\n%d\n%s\n%s",
+		c.EventID, c.EventHash, c.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// auditSigner signs / verifies checkpoints; sign is nil for a verify-only Ed25519 public key :
+type auditSigner struct {
+	alg    string
+	sign   func(msg []byte) []byte
+	verify func(msg []byte, sig []byte) bool
+}
+
+// loadAuditSigner builds the signer from AUDIT_SIGNING_ALG / AUDIT_SIGNING_KEY / AUDIT_PUBLIC_KEY :
+//   - hmac    → AUDIT_SIGNING_KEY is the shared secret
+//   - ed25519 → AUDIT_SIGNING_KEY is a base64 32-byte seed, or only AUDIT_PUBLIC_KEY (base64) to verify
+func loadAuditSigner() (*auditSigner, error) {
+	cfg := config.AppConfig
+	switch cfg.AuditSigningAlg {
+	case "hmac":
+		if cfg.AuditSigningKey == "" {
+			return nil, ErrNoAuditSigner
+		}
+		key := []byte(cfg.AuditSigningKey)
+		mac := func(msg []byte) []byte {
+			h := hmac.New(sha256.New, key)
+			h.Write(msg)
+			return h.Sum(nil)
+		}
+		return &auditSigner{
+			alg:    "hmac",
+			sign:   mac,
+			verify: func(msg, sig []byte) bool { return hmac.Equal(mac(msg), sig) },
+		}, nil
+
+	case "ed25519":
+		var priv ed25519.PrivateKey
+		var pub ed25519.PublicKey
+		if cfg.AuditSigningKey != "" {
+			seed, err := base64.StdEncoding.DecodeString(cfg.AuditSigningKey)
+			if err != nil || len(seed) != ed25519.SeedSize {
+				return nil, fmt.Errorf("AUDIT_SIGNING_KEY must be a base64 %d-byte ed25519 seed", ed25519.SeedSize)
+			}
+			priv = ed25519.NewKeyFromSeed(seed)
+			pub = priv.Public().(ed25519.PublicKey)
+		} else if cfg.AuditPublicKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(cfg.AuditPublicKey)
+			if err != nil || len(raw) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("AUDIT_PUBLIC_KEY must be a base64 %d-byte ed25519 key", ed25519.PublicKeySize)
+			}
+			pub = ed25519.PublicKey(raw)
+		} else {
+			return nil, ErrNoAuditSigner
+		}
+
+		s := &auditSigner{
+			alg:    "ed25519",
+			verify: func(msg, sig []byte) bool { return ed25519.Verify(pub, msg, sig) },
+		}
+		if priv != nil {
+			s.sign = func(msg []byte) []byte { return ed25519.Sign(priv, msg) }
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown AUDIT_SIGNING_ALG %q (hmac or ed25519)", cfg.AuditSigningAlg)
+}
+
+// CreateAuditCheckpoint signs the current chain head. Returns nil when nothing
+// was appended since the last checkpoint.
+func CreateAuditCheckpoint() (*AuditCheckpoint, error) {
+	signer, err := loadAuditSigner()
+	if err != nil {
+		return nil, err
+	}
+	if signer.sign == nil {
+		return nil, ErrNoAuditSigner
+	}
+
+	c := &AuditCheckpoint{Algorithm: signer.alg}
+	err = db.DB.QueryRow(`SELECT id, hash FROM audit_events WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1`).
+		Scan(&c.EventID, &c.EventHash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var last int64
+	if err := db.DB.QueryRow(`SELECT COALESCE(MAX(event_id), 0) FROM audit_checkpoints`).Scan(&last); err != nil {
+		return nil, err
+	}
+	if last >= c.EventID {
+		return nil, nil
+	}
+
+	c.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	c.Signature = base64.StdEncoding.EncodeToString(signer.sign(c.message()))
+	err = db.DB.QueryRow(`
+		INSERT INTO audit_checkpoints (event_id, event_hash, algorithm, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		c.EventID, c.EventHash, c.Algorithm, c.Signature, c.CreatedAt,
+	).Scan(&c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// StartAuditCheckpointer signs the chain head every interval in the background :
+func StartAuditCheckpointer(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if signer, err := loadAuditSigner(); err != nil || signer.sign == nil {
+		log.Printf("⚠️ audit checkpoints disabled: %v", ErrNoAuditSigner)
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(interval)
+			if _, err := CreateAuditCheckpoint(); err != nil {
+				log.Printf("❌ audit checkpoint failed: %v", err)
+			}
+		}
+	}()
+}
+
+// ---------- verification ----------
+
+// AuditVerifyReport is the outcome of walking the chain :
+type AuditVerifyReport struct {
+	Events      int    `json:"events"`
+	Checkpoints int    `json:"checkpoints"`
+	HeadID      int64  `json:"head_id"`
+	HeadHash    string `json:"head_hash"`
+
+	// first broken link (0 = chain intact) :
+	BrokenAt     int64  `json:"broken_at,omitempty"`
+	BrokenReason string `json:"broken_reason,omitempty"`
+
+	// checkpoints that don't match the chain or whose signature is wrong :
+	CheckpointErrors  []string `json:"checkpoint_errors,omitempty"`
+	SignaturesSkipped bool     `json:"signatures_skipped,omitempty"`
+}
+
+// OK reports whether the chain and every checkpoint verified :
+func (r *AuditVerifyReport) OK() bool {
+	return r.BrokenAt == 0 && len(r.CheckpointErrors) == 0
+}
+
+// VerifyAuditChain walks every event in id order, recomputing hashes, stopping at
+// the first broken link, then checks each checkpoint against the chain and its signature.
+func VerifyAuditChain() (*AuditVerifyReport, error) {
+	report := &AuditVerifyReport{}
+
+	// checkpoints, keyed by the event they sign :
+	rows, err := db.DB.Query(`SELECT id, event_id, event_hash, algorithm, signature, created_at FROM audit_checkpoints ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []AuditCheckpoint
+	for rows.Next() {
+		var c AuditCheckpoint
+		if err := rows.Scan(&c.ID, &c.EventID, &c.EventHash, &c.Algorithm, &c.Signature, &c.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	report.Checkpoints = len(checkpoints)
+
+	wanted := make(map[int64]string, len(checkpoints))
+	for _, c := range checkpoints {
+		wanted[c.EventID] = ""
+	}
+
+	// walking the chain :
+	errBroken := errors.New("broken")
+	prev := AuditGenesisHash
+	err = ExportAuditEvents(AuditFilter{}, func(e AuditEvent) error {
+		switch {
+		case e.Hash == "":
+			report.BrokenReason = "event is not chained (no hash)"
+		case e.PrevHash != prev:
+			report.BrokenReason = "prev_hash doesn't match the previous event (event removed, inserted or reordered)"
+		case AuditHash(prev, &e) != e.Hash:
+			report.BrokenReason = "content doesn't match its hash (event modified)"
+		}
+		if report.BrokenReason != "" {
+			report.BrokenAt = e.ID
+			return errBroken
+		}
+
+		if _, ok := wanted[e.ID]; ok {
+			wanted[e.ID] = e.Hash
+		}
+		prev = e.Hash
+		report.Events++
+		report.HeadID = e.ID
+		report.HeadHash = e.Hash
+		return nil
+	})
+	if err != nil && err != errBroken {
+		return nil, err
+	}
+
+	// checking checkpoints :
+	signer, signerErr := loadAuditSigner()
+	if signerErr != nil {
+		report.SignaturesSkipped = true
+	}
+	for _, c := range checkpoints {
+		hash := wanted[c.EventID]
+		switch {
+		case report.BrokenAt != 0 && c.EventID >= report.BrokenAt:
+			// past the break, nothing further can be trusted :
+			continue
+		case hash == "":
+			report.CheckpointErrors = append(report.CheckpointErrors,
+				fmt.Sprintf("checkpoint %d: event %d is missing from the chain", c.ID, c.EventID))
+			continue
+		case hash != c.EventHash:
+			report.CheckpointErrors = append(report.CheckpointErrors,
+				fmt.Sprintf("checkpoint %d: event %d hash differs from the signed one (chain rewritten)", c.ID, c.EventID))
+			continue
+		}
+
+		if signer == nil {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(c.Signature)
+		if c.Algorithm != signer.alg || err != nil || !signer.verify(c.message(), sig) {
+			report.CheckpointErrors = append(report.CheckpointErrors,
+				fmt.Sprintf("checkpoint %d: invalid %s signature", c.ID, c.Algorithm))
+		}
+	}
+	return report, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"backend/internal/models"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -10,19 +11,42 @@ import (
 )
 
 // jwt generator :
-func GenerateJWT(userID int, username string, role string) (string, error) {
+func GenerateJWT(userID int, username string, role string, sessionVersion int) (string, error) {
+    return SignClaims(&models.Claims{
+        UserID:   userID,
+        Username: username,
+        Role:     role, 
+        Session:  sessionVersion,
+    })
+}
+
+// jwt signer, sets the 5 min expiry on any claims set :
+func SignClaims(claims *models.Claims) (string, error) {
     var jwtKey = []byte(os.Getenv("JWT_KEY")) 
     if len(jwtKey) == 0 {log.Fatal("JWT_KEY not found, plz set it in .env file")}
 
     expiration := time.Now().Add(5 * time.Minute)
-    claims := &models.Claims{
-        UserID:   userID,
-        Username: username,
-        Role:     role, 
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(expiration),
-        },
+    claims.RegisteredClaims = jwt.RegisteredClaims{
+        ExpiresAt: jwt.NewNumericDate(expiration),
     }
     token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
     return token.SignedString(jwtKey)
 }
+
+// jwt parser, returns the claims of a valid (unexpired) token :
+func ParseJWT(tokenStr string) (*models.Claims, error) {
+    claims := &models.Claims{}
+    token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+            return nil, fmt.Errorf("unexpected signing method")
+        }
+        return []byte(os.Getenv("JWT_KEY")), nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    if !token.Valid {
+        return nil, fmt.Errorf("invalid token")
+    }
+    return claims, nil
+}
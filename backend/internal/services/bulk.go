@@ -0,0 +1,415 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// bulk actions on files :
+const (
+	BulkDelete       = "delete"
+	BulkMakePrivate  = "make_private"
+	BulkTransfer     = "transfer"
+	BulkQuarantine   = "quarantine"
+	BulkUnquarantine = "unquarantine"
+)
+
+// bulk job states :
+const (
+	BulkQueued  = "queued"
+	BulkRunning = "running"
+	BulkDone    = "done"
+	BulkFailed  = "failed"
+)
+
+const (
+	bulkMaxIDs       = 10000 // per request, explicit ids or filter matches
+	bulkPreviewFiles = 500   // files listed in a dry run
+	bulkMaxErrors    = 100   // per-file errors kept on the job
+)
+
+var (
+	ErrInvalidBulkAction = errors.New("action must be delete, make_private, transfer, quarantine or unquarantine")
+	ErrBulkNoSelection   = errors.New("give either ids or at least one filter")
+	ErrBulkTooMany       = fmt.Errorf("a bulk action may touch at most %d files", bulkMaxIDs)
+)
+
+// BulkRequest selects files by explicit ids or by the listing filters, and what to do with them :
+type BulkRequest struct {
+	Action     string     `json:"action"`
+	IDs        []int      `json:"ids,omitempty"`
+	Filter     *FileQuery `json:"filter,omitempty"`
+	TransferTo int        `json:"transfer_to,omitempty"`
+	DryRun     bool       `json:"dry_run,omitempty"`
+}
+
+// validate checks the request and the transfer target :
+func (req BulkRequest) validate() error {
+	switch req.Action {
+	case BulkDelete, BulkMakePrivate, BulkQuarantine, BulkUnquarantine:
+	case BulkTransfer:
+		var active bool
+		err := db.DB.QueryRow(`SELECT is_active FROM users WHERE id=$1`, req.TransferTo).Scan(&active)
+		if err == sql.ErrNoRows || (err == nil && !active) {
+			return ErrInvalidTarget
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidBulkAction
+	}
+
+	if len(req.IDs) > 0 {
+		if len(req.IDs) > bulkMaxIDs {
+			return ErrBulkTooMany
+		}
+		return nil
+	}
+	if req.Filter == nil || req.Filter.Empty() {
+		return ErrBulkNoSelection
+	}
+	return nil
+}
+
+// BulkTarget is one file a bulk action would touch :
+type BulkTarget struct {
+	ID          int    `json:"id"`
+	Filename    string `json:"filename"`
+	Uploader    string `json:"uploader"`
+	Size        int64  `json:"size"`
+	IsPublic    bool   `json:"is_public"`
+	TeamID      *int   `json:"team_id"`
+	Quarantined bool   `json:"quarantined"`
+}
+
+// bulkTargets resolves the selection, skipping files the action wouldn't change :
+func bulkTargets(req BulkRequest) ([]BulkTarget, error) {
+	var where string
+	var args []interface{}
+	if len(req.IDs) > 0 {
+		where, args = "f.id = ANY($1)", []interface{}{pq.Array(req.IDs)}
+	} else {
+		where, args = req.Filter.Where(nil)
+		where = "TRUE" + where
+	}
+
+	switch req.Action {
+	case BulkMakePrivate:
+		where += " AND f.is_public = TRUE"
+	case BulkQuarantine:
+		where += " AND f.quarantined_at IS NULL"
+	case BulkUnquarantine:
+		where += " AND f.quarantined_at IS NOT NULL"
+	case BulkTransfer:
+		args = append(args, req.TransferTo)
+		where += fmt.Sprintf(" AND f.user_id <> $%d", len(args))
+	}
+
+	// one row over the cap tells us the selection is too large :
+	rows, err := db.DB.Query(`
+		SELECT f.id, f.filename, u.username, f.size, f.is_public, f.team_id, f.quarantined_at IS NOT NULL
+		FROM files f JOIN users u ON u.id = f.user_id
+		WHERE `+where+fmt.Sprintf(` ORDER BY f.id LIMIT %d`, bulkMaxIDs+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := make([]BulkTarget, 0)
+	for rows.Next() {
+		var t BulkTarget
+		var teamID sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.Filename, &t.Uploader, &t.Size, &t.IsPublic, &teamID, &t.Quarantined); err != nil {
+			return nil, err
+		}
+		t.TeamID = nullIntPtr(teamID)
+		targets = append(targets, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(targets) > bulkMaxIDs {
+		return nil, ErrBulkTooMany
+	}
+	return targets, nil
+}
+
+// BulkPreview is the dry-run answer: what the action would affect :
+type BulkPreview struct {
+	Action     string       `json:"action"`
+	DryRun     bool         `json:"dry_run"`
+	Total      int          `json:"total"`
+	TotalBytes int64        `json:"total_bytes"`
+	Files      []BulkTarget `json:"files"`
+	Truncated  bool         `json:"truncated"`
+}
+
+// PreviewBulkAction runs the selection of req without changing anything :
+func PreviewBulkAction(req BulkRequest) (*BulkPreview, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	targets, err := bulkTargets(req)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &BulkPreview{Action: req.Action, DryRun: true, Total: len(targets), Files: targets}
+	for _, t := range targets {
+		p.TotalBytes += t.Size
+	}
+	if len(p.Files) > bulkPreviewFiles {
+		p.Files, p.Truncated = p.Files[:bulkPreviewFiles], true
+	}
+	return p, nil
+}
+
+// BulkError is a file the job couldn't process :
+type BulkError struct {
+	FileID int    `json:"file_id"`
+	Error  string `json:"error"`
+}
+
+// BulkJob is a bulk action running (or run) in the background :
+type BulkJob struct {
+	ID         int64           `json:"id"`
+	Action     string          `json:"action"`
+	Params     json.RawMessage `json:"params"`
+	CreatedBy  *int            `json:"created_by"`
+	Status     string          `json:"status"`
+	Total      int             `json:"total"`
+	Processed  int             `json:"processed"`
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	Progress   float64         `json:"progress"` // 0..1
+	Errors     []BulkError     `json:"errors"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at"`
+}
+
+// StartBulkJob snapshots the selection, stores the job and runs it in the background.
+// actor is the audit template (who / from where) for the per-file events.
+func StartBulkJob(req BulkRequest, actor AuditEvent) (*BulkJob, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	targets, err := bulkTargets(req)
+	if err != nil {
+		return nil, err
+	}
+
+	params, _ := json.Marshal(req)
+	var id int64
+	err = db.DB.QueryRow(`
+		INSERT INTO bulk_jobs (action, params, created_by, total)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
+		req.Action, string(params), actor.ActorID, len(targets),
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(targets))
+	for i, t := range targets {
+		ids[i] = t.ID
+	}
+	go runBulkJob(id, req, ids, actor)
+	return GetBulkJob(id)
+}
+
+// runBulkJob applies the action file by file, saving progress every few files :
+func runBulkJob(jobID int64, req BulkRequest, ids []int, actor AuditEvent) {
+	if _, err := db.DB.Exec(`UPDATE bulk_jobs SET status=$2, started_at=NOW() WHERE id=$1`, jobID, BulkRunning); err != nil {
+		log.Printf("❌ bulk job %d: %v", jobID, err)
+		return
+	}
+
+	var succeeded, failed int
+	errs := make([]BulkError, 0)
+	save := func(status string) {
+		errJSON, _ := json.Marshal(errs)
+		finished := "NULL"
+		if status != BulkRunning {
+			finished = "NOW()"
+		}
+		_, err := db.DB.Exec(`
+			UPDATE bulk_jobs SET status=$2, processed=$3, succeeded=$4, failed=$5, errors=$6, finished_at=`+finished+`
+			WHERE id=$1`, jobID, status, succeeded+failed, succeeded, failed, string(errJSON))
+		if err != nil {
+			log.Printf("❌ bulk job %d progress: %v", jobID, err)
+		}
+	}
+
+	lastSave := time.Now()
+	for _, id := range ids {
+		if err := applyBulkAction(req, id, actor.ActorID); err != nil {
+			failed++
+			if len(errs) < bulkMaxErrors {
+				errs = append(errs, BulkError{FileID: id, Error: err.Error()})
+			}
+		} else {
+			succeeded++
+			auditBulkFile(jobID, req, id, actor)
+		}
+		if time.Since(lastSave) > time.Second {
+			save(BulkRunning)
+			lastSave = time.Now()
+		}
+	}
+
+	status := BulkDone
+	if failed > 0 && succeeded == 0 {
+		status = BulkFailed
+	}
+	save(status)
+	log.Printf("🗂️ bulk job %d (%s): %d ok, %d failed", jobID, req.Action, succeeded, failed)
+}
+
+// applyBulkAction performs the action on one file :
+func applyBulkAction(req BulkRequest, fileID int, actorID *int) error {
+	switch req.Action {
+	case BulkDelete, BulkTransfer:
+		meta, err := GetFileByID(fileID)
+		if err != nil {
+			return err
+		}
+		if meta == nil {
+			return ErrFileNotFound
+		}
+		if req.Action == BulkDelete {
+			if err := DeleteFile(fileID); err != nil {
+				return err
+			}
+			RecordActivity(Activity{Kind: ActivityDelete, ActorID: actorID, Filename: meta.Filename, TeamID: meta.TeamID},
+				ActivityAudience(meta.TeamID, meta.UploaderID))
+			return nil
+		}
+		if err := TransferFile(fileID, req.TransferTo); err != nil {
+			return err
+		}
+		if meta.UploaderID != req.TransferTo {
+			RecordActivity(Activity{Kind: ActivityReceived, ActorID: actorID, FileID: &meta.ID, Filename: meta.Filename, TeamID: meta.TeamID,
+				Details: map[string]interface{}{"from_username": meta.UploaderUsername}}, []int{req.TransferTo})
+		}
+		return nil
+	}
+
+	var res sql.Result
+	var err error
+	switch req.Action {
+	case BulkMakePrivate:
+		res, err = db.DB.Exec(`UPDATE files SET is_public = FALSE WHERE id=$1`, fileID)
+	case BulkQuarantine:
+		res, err = db.DB.Exec(`UPDATE files SET quarantined_at = NOW(), quarantined_by = $2 WHERE id=$1`, fileID, actorID)
+	case BulkUnquarantine:
+		res, err = db.DB.Exec(`UPDATE files SET quarantined_at = NULL, quarantined_by = NULL WHERE id=$1`, fileID)
+	default:
+		return ErrInvalidBulkAction
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
+// auditBulkFile records one file changed by a job, under the admin who started it :
+func auditBulkFile(jobID int64, req BulkRequest, fileID int, actor AuditEvent) {
+	e := actor
+	e.TargetType = "file"
+	e.TargetID = fmt.Sprint(fileID)
+	e.Outcome = AuditSuccess
+	details := map[string]interface{}{"bulk_job_id": jobID}
+	switch req.Action {
+	case BulkDelete:
+		e.Action = AuditFileDelete
+	case BulkMakePrivate:
+		e.Action = AuditFileVisibility
+		details["is_public"] = false
+	case BulkTransfer:
+		e.Action = AuditFileTransfer
+		details["to_user_id"] = req.TransferTo
+	case BulkQuarantine:
+		e.Action = AuditFileQuarantine
+	case BulkUnquarantine:
+		e.Action = AuditFileUnquarantine
+	}
+	e.Details, _ = json.Marshal(details)
+	RecordAudit(e)
+}
+
+const bulkJobColumns = `id, action, params, created_by, status, total, processed, succeeded, failed, errors, created_at, started_at, finished_at`
+
+func scanBulkJob(row interface{ Scan(...interface{}) error }) (*BulkJob, error) {
+	var j BulkJob
+	var params, errs string
+	var createdBy sql.NullInt64
+	err := row.Scan(&j.ID, &j.Action, &params, &createdBy, &j.Status, &j.Total, &j.Processed,
+		&j.Succeeded, &j.Failed, &errs, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	j.Params = json.RawMessage(params)
+	j.CreatedBy = nullIntPtr(createdBy)
+	if err := json.Unmarshal([]byte(errs), &j.Errors); err != nil || j.Errors == nil {
+		j.Errors = make([]BulkError, 0)
+	}
+	j.Progress = 1
+	if j.Total > 0 {
+		j.Progress = float64(j.Processed) / float64(j.Total)
+	}
+	return &j, nil
+}
+
+// GetBulkJob returns one job, or (nil, nil) if it doesn't exist :
+func GetBulkJob(id int64) (*BulkJob, error) {
+	j, err := scanBulkJob(db.DB.QueryRow(`SELECT `+bulkJobColumns+` FROM bulk_jobs WHERE id=$1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+// ListBulkJobs returns the latest jobs, newest first :
+func ListBulkJobs(limit int, offset int) ([]BulkJob, error) {
+	rows, err := db.DB.Query(`SELECT `+bulkJobColumns+` FROM bulk_jobs ORDER BY id DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]BulkJob, 0)
+	for rows.Next() {
+		j, err := scanBulkJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// FailInterruptedBulkJobs marks jobs cut short by a restart; called once at startup :
+func FailInterruptedBulkJobs() {
+	res, err := db.DB.Exec(`
+		UPDATE bulk_jobs SET status=$1, finished_at=NOW()
+		WHERE status IN ($2, $3)`, BulkFailed, BulkQueued, BulkRunning)
+	if err != nil {
+		log.Printf("❌ bulk jobs cleanup failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("🗂️ marked %d interrupted bulk job(s) as failed", n)
+	}
+}
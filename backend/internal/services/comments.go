@@ -0,0 +1,305 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// MaxCommentLength caps a comment body (in characters) :
+const MaxCommentLength = 5000
+
+// NotifyCommentMention is sent to users @mentioned in a comment :
+const NotifyCommentMention = "comment.mention"
+
+var (
+	ErrCommentEmpty    = errors.New("comment must not be empty")
+	ErrCommentTooLong  = errors.New("comment is too long (max 5000 characters)")
+	ErrCommentParent   = errors.New("parent comment not found on this file")
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrCommentDeleted  = errors.New("comment was deleted")
+)
+
+// mentionPattern finds @username (see utils.ValidateUsername), not inside e-mail addresses :
+var mentionPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9._@-])@([A-Za-z0-9][A-Za-z0-9._-]{2,31})`)
+
+// Comment is one comment on a file; top-level comments carry their replies :
+type Comment struct {
+	ID        int        `json:"id"`
+	FileID    int        `json:"file_id"`
+	ParentID  *int       `json:"parent_id"`
+	AuthorID  *int       `json:"author_id"` // null once the author's account is gone
+	Author    string     `json:"author"`
+	Body      string     `json:"body"` // "" once deleted
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+	Deleted   bool       `json:"deleted"`
+	Replies   []Comment  `json:"replies,omitempty"`
+}
+
+// CommentRevision is a previous body of a comment, replaced by an edit or the deletion :
+type CommentRevision struct {
+	Body       string    `json:"body"`
+	ReplacedBy *int      `json:"replaced_by"`
+	Username   string    `json:"replaced_by_username"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+const commentColumns = `c.id, c.file_id, c.parent_id, c.user_id, COALESCE(u.username, ''), COALESCE(c.body, ''),
+	c.created_at, c.edited_at, c.deleted_at IS NOT NULL`
+
+func scanComment(row interface{ Scan(...interface{}) error }) (*Comment, error) {
+	var c Comment
+	if err := row.Scan(&c.ID, &c.FileID, &c.ParentID, &c.AuthorID, &c.Author, &c.Body,
+		&c.CreatedAt, &c.EditedAt, &c.Deleted); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// normalizeComment trims and checks a comment body :
+func normalizeComment(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrCommentEmpty
+	}
+	if utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", ErrCommentTooLong
+	}
+	return body, nil
+}
+
+// GetComment loads one comment, nil if it doesn't exist :
+func GetComment(id int) (*Comment, error) {
+	c, err := scanComment(db.DB.QueryRow(`
+		SELECT `+commentColumns+`
+		FROM file_comments c LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// ListFileComments returns the file's threads, oldest first. Deleted comments only stay
+// (without body) as the head of a thread that still has replies.
+func ListFileComments(fileID int) ([]Comment, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+commentColumns+`
+		FROM file_comments c LEFT JOIN users u ON u.id = c.user_id
+		WHERE c.file_id = $1
+		ORDER BY c.created_at, c.id`, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roots []*Comment
+	replies := map[int][]Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else if !c.Deleted {
+			replies[*c.ParentID] = append(replies[*c.ParentID], *c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	threads := []Comment{}
+	for _, c := range roots {
+		c.Replies = replies[c.ID]
+		if c.Deleted && len(c.Replies) == 0 {
+			continue
+		}
+		threads = append(threads, *c)
+	}
+	return threads, nil
+}
+
+// CountFileComments counts the file's comments that aren't deleted :
+func CountFileComments(fileID int) (int, error) {
+	var n int
+	err := db.DB.QueryRow(`SELECT COUNT(*) FROM file_comments WHERE file_id = $1 AND deleted_at IS NULL`, fileID).Scan(&n)
+	return n, err
+}
+
+// AddComment posts a comment on the file; a reply (parentID) joins the parent's thread :
+func AddComment(fileID int, userID int, parentID *int, body string) (*Comment, error) {
+	body, err := normalizeComment(body)
+	if err != nil {
+		return nil, err
+	}
+	if parentID != nil {
+		parent, err := GetComment(*parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.FileID != fileID || parent.Deleted {
+			return nil, ErrCommentParent
+		}
+		// threads are one level deep, replies to a reply go to its thread :
+		if parent.ParentID != nil {
+			parentID = parent.ParentID
+		}
+	}
+
+	var id int
+	err = db.DB.QueryRow(`
+		INSERT INTO file_comments (file_id, parent_id, user_id, body) VALUES ($1, $2, $3, $4) RETURNING id`,
+		fileID, parentID, userID, body,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	return GetComment(id)
+}
+
+// replaceComment keeps the current body in the history, then applies set (SQL SET clause, $1 is the
+// comment id and args follow from $2) :
+func replaceComment(id int, by int, set string, args ...interface{}) (*Comment, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	var deleted bool
+	err = tx.QueryRow(`SELECT body, deleted_at IS NOT NULL FROM file_comments WHERE id = $1 FOR UPDATE`, id).Scan(&current, &deleted)
+	if err == sql.ErrNoRows {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrCommentDeleted
+	}
+	if _, err := tx.Exec(`INSERT INTO file_comment_revisions (comment_id, body, replaced_by) VALUES ($1, $2, $3)`,
+		id, current.String, by); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE file_comments SET `+set+` WHERE id = $1`, append([]interface{}{id}, args...)...); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetComment(id)
+}
+
+// EditComment replaces the comment's body, keeping the previous one in its history :
+func EditComment(id int, by int, body string) (*Comment, error) {
+	body, err := normalizeComment(body)
+	if err != nil {
+		return nil, err
+	}
+	return replaceComment(id, by, `body = $2, edited_at = NOW()`, body)
+}
+
+// DeleteComment soft-deletes the comment (its replies stay), keeping the last body in its history :
+func DeleteComment(id int, by int) error {
+	_, err := replaceComment(id, by, `body = NULL, deleted_at = NOW(), deleted_by = $2`, by)
+	return err
+}
+
+// CommentHistory returns the comment's previous bodies, oldest first :
+func CommentHistory(id int) ([]CommentRevision, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.body, r.replaced_by, COALESCE(u.username, ''), r.replaced_at
+		FROM file_comment_revisions r LEFT JOIN users u ON u.id = r.replaced_by
+		WHERE r.comment_id = $1
+		ORDER BY r.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := []CommentRevision{}
+	for rows.Next() {
+		var rev CommentRevision
+		if err := rows.Scan(&rev.Body, &rev.ReplacedBy, &rev.Username, &rev.ReplacedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, rev)
+	}
+	return history, rows.Err()
+}
+
+// Mentions returns the distinct usernames @mentioned in body :
+func Mentions(body string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(m[1], ".")
+		if len(name) >= 3 && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// NotifyMentions notifies the users newly @mentioned in c (not already in previous) who can
+// read the file. The author is never notified. Failures are only logged.
+func NotifyMentions(f *FileMeta, c *Comment, previous string) {
+	already := map[string]bool{}
+	for _, name := range Mentions(previous) {
+		already[name] = true
+	}
+	var names []string
+	for _, name := range Mentions(c.Body) {
+		if !already[name] && name != c.Author {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	rows, err := db.DB.Query(`SELECT id, role FROM users WHERE username = ANY($1) AND is_active = TRUE`, pq.Array(names))
+	if err != nil {
+		log.Printf("❌ mention lookup failed: %v", err)
+		return
+	}
+	type mentioned struct {
+		id   int
+		role string
+	}
+	var users []mentioned
+	for rows.Next() {
+		var m mentioned
+		if err := rows.Scan(&m.id, &m.role); err != nil {
+			log.Printf("❌ mention lookup failed: %v", err)
+			break
+		}
+		users = append(users, m)
+	}
+	rows.Close()
+
+	excerpt := c.Body
+	if utf8.RuneCountInString(excerpt) > 200 {
+		excerpt = string([]rune(excerpt)[:200]) + "…"
+	}
+	for _, m := range users {
+		if !CanReadFile(m.id, m.role, f) {
+			continue
+		}
+		title := c.Author + " mentioned you on " + f.Filename
+		if err := Notify(m.id, NotifyCommentMention, title, excerpt, false); err != nil {
+			log.Printf("❌ mention notification for user %d failed: %v", m.id, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"backend/internal/db"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidStatsArg is returned for an unknown bucket / top-N metric :
+var ErrInvalidStatsArg = errors.New("invalid stats argument")
+
+// RecordDownload stores one download event :
+func RecordDownload(fileID int, userID *int, via string, ip string, userAgent string, bytesServed int64) error {
+	// same cut as RecordAudit, never leaving invalid UTF-8 behind :
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	_, err := db.DB.Exec(`
+		INSERT INTO file_downloads (file_id, user_id, via, ip, user_agent, bytes_served)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		fileID, userID, via, ip, userAgent, bytesServed,
+	)
+	return err
+}
+
+// FileAccess is one row of a file's access history :
+type FileAccess struct {
+	UserID      *int      `json:"user_id"`
+	Username    *string   `json:"username"`
+	Via         string    `json:"via"`
+	IP          string    `json:"ip"`
+	UserAgent   string    `json:"user_agent"`
+	BytesServed int64     `json:"bytes_served"`
+	At          time.Time `json:"at"`
+}
+
+// ListFileAccess returns a page of who downloaded the file (newest first) and the total :
+func ListFileAccess(fileID int, limit int, offset int) ([]FileAccess, int, error) {
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM file_downloads WHERE file_id=$1`, fileID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT d.user_id, u.username, d.via, d.ip, d.user_agent, d.bytes_served, d.created_at
+		FROM file_downloads d
+		LEFT JOIN users u ON u.id = d.user_id
+		WHERE d.file_id = $1
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $2 OFFSET $3`,
+		fileID, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	list := make([]FileAccess, 0)
+	for rows.Next() {
+		var a FileAccess
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Via, &a.IP, &a.UserAgent, &a.BytesServed, &a.At); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, a)
+	}
+	return list, total, rows.Err()
+}
+
+// DownloadBucket is the download volume of one time bucket :
+type DownloadBucket struct {
+	Bucket      time.Time `json:"bucket"`
+	Downloads   int       `json:"downloads"`
+	UniqueUsers int       `json:"unique_users"`
+	Bytes       int64     `json:"bytes"`
+}
+
+// FileDownloadStats groups a file's downloads in [from, to) by hour / day / week / month :
+func FileDownloadStats(fileID int, bucket string, from time.Time, to time.Time) ([]DownloadBucket, error) {
+	switch bucket {
+	case "hour", "day", "week", "month":
+	default:
+		return nil, ErrInvalidStatsArg
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT date_trunc($2, created_at) AS b, COUNT(*), COUNT(DISTINCT user_id), COALESCE(SUM(bytes_served), 0)
+		FROM file_downloads
+		WHERE file_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY b
+		ORDER BY b`,
+		fileID, bucket, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]DownloadBucket, 0)
+	for rows.Next() {
+		var b DownloadBucket
+		if err := rows.Scan(&b.Bucket, &b.Downloads, &b.UniqueUsers, &b.Bytes); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// TopFile is one entry of an admin top-N list; Value is the ranked metric :
+type TopFile struct {
+	ID         int       `json:"id"`
+	Filename   string    `json:"filename"`
+	Uploader   string    `json:"uploader"`
+	Size       int64     `json:"size"`
+	IsPublic   bool      `json:"is_public"`
+	TeamID     *int      `json:"team_id"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Value      int64     `json:"value"`
+}
+
+// TopFiles ranks files by:
+//   - "downloads" : downloads since `since` (all time from download_count when since is nil)
+//   - "size"      : file size
+//   - "shared"    : distinct people other than the uploader who downloaded it since `since`
+func TopFiles(by string, limit int, since *time.Time) ([]TopFile, error) {
+	const cols = `f.id, f.filename, u.username, f.size, f.is_public, f.team_id, f.uploaded_at`
+
+	var query string
+	var args []interface{}
+	switch {
+	case by == "size":
+		query = `SELECT ` + cols + `, f.size FROM files f JOIN users u ON u.id = f.user_id
+			ORDER BY f.size DESC, f.id LIMIT $1`
+		args = []interface{}{limit}
+	case by == "downloads" && since == nil:
+		query = `SELECT ` + cols + `, f.download_count FROM files f JOIN users u ON u.id = f.user_id
+			ORDER BY f.download_count DESC, f.id LIMIT $1`
+		args = []interface{}{limit}
+	case by == "downloads" || by == "shared":
+		// aggregating the (indexed) window first, joining files only for the top rows :
+		metric := `COUNT(*)`
+		filter := ``
+		if by == "shared" {
+			metric = `COUNT(DISTINCT COALESCE(d.user_id::text, 'ip:' || d.ip))`
+			filter = ` AND d.via <> 'owner'`
+		}
+		from := time.Time{}
+		if since != nil {
+			from = *since
+		}
+		query = fmt.Sprintf(`
+			WITH top AS (
+				SELECT d.file_id, %s AS value
+				FROM file_downloads d
+				WHERE d.created_at >= $2%s
+				GROUP BY d.file_id
+				ORDER BY value DESC, d.file_id
+				LIMIT $1
+			)
+			SELECT %s, top.value FROM top
+			JOIN files f ON f.id = top.file_id
+			JOIN users u ON u.id = f.user_id
+			ORDER BY top.value DESC, f.id`, metric, filter, cols)
+		args = []interface{}{limit, from}
+	default:
+		return nil, ErrInvalidStatsArg
+	}
+
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]TopFile, 0)
+	for rows.Next() {
+		var t TopFile
+		if err := rows.Scan(&t.ID, &t.Filename, &t.Uploader, &t.Size, &t.IsPublic, &t.TeamID, &t.UploadedAt, &t.Value); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
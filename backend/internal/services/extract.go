@@ -0,0 +1,392 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrContentUnsupported is returned for file types the extractor can't read (nothing to index) :
+var ErrContentUnsupported = errors.New("unsupported content type")
+
+const (
+	maxExtractInput  = 32 << 20  // bytes read from the upload (or from one archive entry)
+	maxContentLength = 256 << 10 // bytes of text kept per content hash (tsvector limits apply)
+	maxPDFInflate    = 64 << 20  // bytes inflated from all the Flate streams of one PDF together
+)
+
+var (
+	markupTag    = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRun     = regexp.MustCompile(`[ \t\f\v\r]+`)
+	blankLineRun = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// ExtractText reads the searchable text of an uploaded file :
+// plaintext (text/*, JSON, XML, CSV, …), PDF and Office (docx / pptx / xlsx, OpenDocument).
+func ExtractText(path string, filename string, mimeType string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case ext == ".pdf" || strings.HasPrefix(mimeType, "application/pdf"):
+		data, err := readLimited(path)
+		if err != nil {
+			return "", err
+		}
+		return cleanText(extractPDF(data)), nil
+
+	case ext == ".docx" || ext == ".pptx" || ext == ".xlsx" ||
+		ext == ".odt" || ext == ".odp" || ext == ".ods":
+		text, err := extractOffice(path, ext)
+		if err != nil {
+			return "", err
+		}
+		return cleanText(text), nil
+
+	case isPlainText(ext, mimeType):
+		data, err := readLimited(path)
+		if err != nil {
+			return "", err
+		}
+		text := string(data)
+		if ext == ".html" || ext == ".htm" || ext == ".xml" || strings.Contains(mimeType, "html") {
+			text = markupTag.ReplaceAllString(text, " ")
+		}
+		return cleanText(text), nil
+	}
+	return "", ErrContentUnsupported
+}
+
+// isPlainText tells files worth indexing as they are :
+func isPlainText(ext string, mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") ||
+		strings.HasPrefix(mimeType, "application/json") || strings.HasPrefix(mimeType, "application/xml") {
+		return true
+	}
+	switch ext {
+	case ".txt", ".md", ".csv", ".tsv", ".json", ".xml", ".log", ".yaml", ".yml", ".html", ".htm", ".rtf":
+		return true
+	}
+	return false
+}
+
+func readLimited(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, maxExtractInput))
+}
+
+// cleanText makes extracted text storable (valid UTF-8, no NULs), squeezes whitespace & caps its size :
+func cleanText(s string) string {
+	s = strings.ToValidUTF8(s, " ")
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	s = spaceRun.ReplaceAllString(s, " ")
+	s = blankLineRun.ReplaceAllString(s, "\n")
+	s = strings.TrimSpace(s)
+	if len(s) > maxContentLength {
+		s = s[:maxContentLength]
+		for !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	return s
+}
+
+// ---- Office documents : zipped XML ----
+
+// extractOffice reads the text parts of OOXML (docx / pptx / xlsx) and OpenDocument files :
+func extractOffice(path string, ext string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", ErrContentUnsupported
+	}
+	defer zr.Close()
+
+	var parts []*zip.File
+	onlyT := true // OOXML keeps text in <w:t> / <a:t> / <t>, OpenDocument in any element
+	for _, f := range zr.File {
+		switch ext {
+		case ".docx":
+			if f.Name == "word/document.xml" {
+				parts = append(parts, f)
+			}
+		case ".pptx":
+			if strings.HasPrefix(f.Name, "ppt/slides/slide") && strings.HasSuffix(f.Name, ".xml") {
+				parts = append(parts, f)
+			}
+		case ".xlsx":
+			if f.Name == "xl/sharedStrings.xml" {
+				parts = append(parts, f)
+			}
+		default:
+			onlyT = false
+			if f.Name == "content.xml" {
+				parts = append(parts, f)
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return "", ErrContentUnsupported
+	}
+	sort.Slice(parts, func(i, j int) bool { return naturalLess(parts[i].Name, parts[j].Name) })
+
+	var out strings.Builder
+	for _, f := range parts {
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		err = xmlText(io.LimitReader(rc, maxExtractInput), onlyT, &out)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		if out.Len() > maxContentLength {
+			break
+		}
+	}
+	return out.String(), nil
+}
+
+// xmlText writes the character data of an XML part, one line per paragraph / shared string :
+func xmlText(r io.Reader, onlyT bool, out *strings.Builder) error {
+	dec := xml.NewDecoder(r)
+	inText := 0
+	for out.Len() <= maxContentLength {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "t" {
+				inText++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText--
+			case "p", "h", "si", "tab", "br":
+				out.WriteByte('\n')
+			}
+		case xml.CharData:
+			if !onlyT || inText > 0 {
+				out.Write(t)
+				if !onlyT {
+					out.WriteByte(' ')
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// naturalLess orders slide2.xml before slide10.xml :
+func naturalLess(a string, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
+
+// ---- PDF : text operators of the (Flate) content streams ----
+
+var pdfStream = regexp.MustCompile(`(?s)<<(.{0,1000}?)>>\s*stream\r?\n`)
+
+// extractPDF is a best-effort reader : literal & hex strings shown by Tj / TJ / ' / " inside BT … ET.
+// Fonts with custom encodings (CID / subset fonts) aren't decoded, their text is mostly lost.
+func extractPDF(data []byte) string {
+	var out strings.Builder
+	budget := int64(maxPDFInflate) // many small bombs add up, the budget is shared by all streams
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		var content []byte
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			content, _ = io.ReadAll(io.LimitReader(zr, min(budget, maxExtractInput)))
+			zr.Close()
+			budget -= int64(len(content))
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue // images & other encodings
+		default:
+			content = raw
+		}
+		if bytes.Contains(content, []byte("BT")) {
+			pdfTextOps(content, &out)
+		}
+		if out.Len() > maxContentLength || budget <= 0 {
+			break
+		}
+	}
+	return out.String()
+}
+
+// pdfTextOps scans one content stream for the strings of its text objects :
+func pdfTextOps(c []byte, out *strings.Builder) {
+	inText := false
+	for i := 0; i < len(c); i++ {
+		switch ch := c[i]; {
+		case ch == '(':
+			s, n := pdfLiteral(c[i:])
+			if inText {
+				out.WriteString(s)
+			}
+			i += n - 1
+		case ch == '<' && i+1 < len(c) && c[i+1] == '<':
+			i++
+		case ch == '<':
+			s, n := pdfHex(c[i:])
+			if inText {
+				out.WriteString(s)
+			}
+			i += n - 1
+		case ch == '%':
+			for i < len(c) && c[i] != '\n' && c[i] != '\r' {
+				i++
+			}
+		case isPDFDelim(ch):
+		default:
+			j := i
+			for j < len(c) && !isPDFDelim(c[j]) && c[j] != '(' && c[j] != '<' && c[j] != '%' {
+				j++
+			}
+			switch op := string(c[i:j]); op {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				out.WriteByte('\n')
+			case "Td", "TD", "T*", "'", "\"":
+				if inText {
+					out.WriteByte(' ')
+				}
+			default:
+				// a big negative TJ kerning is a word gap :
+				if inText && len(op) > 3 && op[0] == '-' && op[1] >= '1' && op[1] <= '9' {
+					out.WriteByte(' ')
+				}
+			}
+			i = j - 1
+		}
+	}
+}
+
+func isPDFDelim(ch byte) bool {
+	switch ch {
+	case ' ', '\t', '\r', '\n', '\f', 0, '[', ']', '>', ')', '{', '}', '/':
+		return true
+	}
+	return false
+}
+
+// pdfLiteral decodes a (…) string (PDFDocEncoding read as Latin-1), returning it & the bytes consumed :
+func pdfLiteral(c []byte) (string, int) {
+	var b strings.Builder
+	depth := 0
+	i := 0
+	for ; i < len(c); i++ {
+		ch := c[i]
+		switch {
+		case ch == '\\' && i+1 < len(c):
+			i++
+			switch e := c[i]; e {
+			case 'n', 'r':
+				b.WriteByte(' ')
+			case 't', 'b', 'f':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := 0
+				k := 0
+				for ; k < 3 && i+k < len(c) && c[i+k] >= '0' && c[i+k] <= '7'; k++ {
+					v = v*8 + int(c[i+k]-'0')
+				}
+				i += k - 1
+				b.WriteRune(rune(v & 0xff))
+			case '\r', '\n':
+			default:
+				b.WriteRune(rune(e))
+			}
+		case ch == '(':
+			if depth > 0 {
+				b.WriteByte('(')
+			}
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(')')
+		default:
+			b.WriteRune(rune(ch))
+		}
+	}
+	return b.String(), i
+}
+
+// pdfHex decodes a <…> string byte per byte (control bytes skipped) :
+func pdfHex(c []byte) (string, int) {
+	end := bytes.IndexByte(c, '>')
+	if end < 0 {
+		return "", len(c)
+	}
+	var digits []byte
+	for _, ch := range c[1:end] {
+		if (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F') {
+			digits = append(digits, ch)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var b strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		v := hexVal(digits[i])<<4 | hexVal(digits[i+1])
+		if v >= 0x20 {
+			b.WriteRune(rune(v))
+		}
+	}
+	return b.String(), end + 1
+}
+
+func hexVal(ch byte) byte {
+	switch {
+	case ch >= 'a':
+		return ch - 'a' + 10
+	case ch >= 'A':
+		return ch - 'A' + 10
+	}
+	return ch - '0'
+}
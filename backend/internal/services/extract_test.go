@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// flateStream is one FlateDecode PDF stream object holding content :
+func flateStream(content []byte) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(content)
+	zw.Close()
+	return []byte(fmt.Sprintf("1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", z.Len(), z.Bytes()))
+}
+
+func TestExtractPDFInflateBudget(t *testing.T) {
+	text := func(s string) []byte { return flateStream([]byte("BT (" + s + ") Tj ET")) }
+	bomb := flateStream(make([]byte, maxExtractInput)) // kilobytes on disk, 32 MB inflated
+
+	tests := []struct {
+		name    string
+		streams [][]byte
+		want    []string
+		notWant []string
+	}{
+		{name: "text streams", streams: [][]byte{text("first"), text("second")}, want: []string{"first", "second"}},
+		{name: "one bomb", streams: [][]byte{text("first"), bomb, text("last")}, want: []string{"first", "last"}},
+		{
+			name:    "bombs add up",
+			streams: [][]byte{text("first"), bomb, bomb, bomb, text("last")},
+			want:    []string{"first"},
+			notWant: []string{"last"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf := append([]byte("%PDF-1.4\n"), bytes.Join(tt.streams, nil)...)
+			got := extractPDF(pdf)
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("text %q doesn't contain %q", got, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("text %q contains %q, read past the inflate budget", got, w)
+				}
+			}
+		})
+	}
+}
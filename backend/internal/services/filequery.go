@@ -0,0 +1,383 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter wraps every file filter parsing error (the message says which one) :
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// listing scope errors (see UserFileScope) :
+var (
+	ErrInvalidSpace    = errors.New("invalid space, use personal, team or all")
+	ErrInvalidTeamID   = errors.New("invalid team_id")
+	ErrInvalidFolderID = errors.New("invalid folder_id")
+)
+
+const maxFilterValues = 20 // per filter
+
+var mimePattern = regexp.MustCompile(`^(\*|[a-z0-9][a-z0-9.+-]*/(\*|[a-z0-9][a-z0-9.+-]*))$`)
+
+// TextFilter is one multi-value filter: rows match any Include value and no Exclude value.
+type TextFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (t TextFilter) empty() bool {
+	return len(t.Include) == 0 && len(t.Exclude) == 0
+}
+
+// FileQuery is the filter set shared by the file listings and bulk actions :
+//
+//	search=report&search=!draft          filename contains (any of / none of)
+//	mimeType=image/*,application/pdf     exact types or "type/*" prefixes; "!video/*" excludes
+//	uploader=alice,!bob                  uploader username contains
+//	minSize=10&maxSize=2048              KB, inclusive
+//	startDate=2025-09-01&endDate=2025-09-30   YYYY-MM-DD (whole days) or RFC 3339
+//	tag=invoice,!archived                tagged with (any of / none of)
+//	meta=client:acme&meta=!reviewed      metadata key:value (value case-insensitive) or key is set
+//
+// Repeated params and comma separated values (except search & meta) are OR-ed, "!" negates,
+// different filters are AND-ed.
+type FileQuery struct {
+	Search    TextFilter
+	MimeTypes TextFilter
+	Uploaders TextFilter
+	Tags      TextFilter
+	Metadata  TextFilter // "key" or "key:value"
+	MinSize   *int64     // bytes
+	MaxSize   *int64     // bytes
+	From      *time.Time // inclusive
+	To        *time.Time // exclusive
+}
+
+// ParseFileQuery reads and validates the filter params :
+func ParseFileQuery(v url.Values) (FileQuery, error) {
+	var q FileQuery
+	var err error
+	if q.Search, err = parseTextFilter(v, "search", false); err != nil {
+		return q, err
+	}
+	if q.MimeTypes, err = parseTextFilter(v, "mimeType", true); err != nil {
+		return q, err
+	}
+	for _, m := range append(append([]string{}, q.MimeTypes.Include...), q.MimeTypes.Exclude...) {
+		if !mimePattern.MatchString(m) {
+			return q, fmt.Errorf("%w: mimeType %q, use type/subtype or type/*", ErrInvalidFilter, m)
+		}
+	}
+	if q.Uploaders, err = parseTextFilter(v, "uploader", true); err != nil {
+		return q, err
+	}
+	if q.Tags, err = parseTextFilter(v, "tag", true); err != nil {
+		return q, err
+	}
+	for _, list := range [][]string{q.Tags.Include, q.Tags.Exclude} {
+		for i, t := range list {
+			if list[i], err = NormalizeTag(t); err != nil {
+				return q, fmt.Errorf("%w: tag %q, %v", ErrInvalidFilter, t, err)
+			}
+		}
+	}
+	if q.Metadata, err = parseTextFilter(v, "meta", false); err != nil {
+		return q, err
+	}
+	for _, m := range append(append([]string{}, q.Metadata.Include...), q.Metadata.Exclude...) {
+		key, _, _ := strings.Cut(m, ":")
+		if _, err := NormalizeMetadataKey(key); err != nil {
+			return q, fmt.Errorf("%w: meta %q, use key or key:value", ErrInvalidFilter, m)
+		}
+	}
+
+	if q.MinSize, err = parseSizeKB(v.Get("minSize"), "minSize"); err != nil {
+		return q, err
+	}
+	if q.MaxSize, err = parseSizeKB(v.Get("maxSize"), "maxSize"); err != nil {
+		return q, err
+	}
+	if q.MinSize != nil && q.MaxSize != nil && *q.MinSize > *q.MaxSize {
+		return q, fmt.Errorf("%w: minSize is above maxSize", ErrInvalidFilter)
+	}
+
+	if q.From, err = parseFilterDate(v.Get("startDate"), "startDate", false); err != nil {
+		return q, err
+	}
+	if q.To, err = parseFilterDate(v.Get("endDate"), "endDate", true); err != nil {
+		return q, err
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return q, fmt.Errorf("%w: startDate is after endDate", ErrInvalidFilter)
+	}
+	return q, nil
+}
+
+// parseTextFilter collects the values of one param, splitting on commas unless it's free text :
+func parseTextFilter(v url.Values, name string, split bool) (TextFilter, error) {
+	var t TextFilter
+	for _, raw := range v[name] {
+		parts := []string{raw}
+		if split {
+			parts = strings.Split(raw, ",")
+		}
+		for _, p := range parts {
+			p = strings.TrimSpace(p)
+			if split {
+				p = strings.ToLower(p)
+			}
+			switch {
+			case p == "" || p == "!":
+			case strings.HasPrefix(p, "!"):
+				t.Exclude = append(t.Exclude, p[1:])
+			default:
+				t.Include = append(t.Include, p)
+			}
+		}
+	}
+	if len(t.Include)+len(t.Exclude) > maxFilterValues {
+		return t, fmt.Errorf("%w: at most %d %s values", ErrInvalidFilter, maxFilterValues, name)
+	}
+	return t, nil
+}
+
+// parseSizeKB reads a size in KB, returning bytes :
+func parseSizeKB(s string, name string) (*int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	kb, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || kb < 0 || kb > 1<<40 {
+		return nil, fmt.Errorf("%w: %s must be a size in KB", ErrInvalidFilter, name)
+	}
+	b := kb * 1024
+	return &b, nil
+}
+
+// parseFilterDate reads YYYY-MM-DD or RFC 3339; a bare end date covers that whole day.
+func parseFilterDate(s string, name string, end bool) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be YYYY-MM-DD or RFC 3339", ErrInvalidFilter, name)
+	}
+	if end {
+		t = t.Add(time.Microsecond) // inclusive timestamp, exclusive bound
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// Empty reports whether no filter is set (the query matches every file) :
+func (q FileQuery) Empty() bool {
+	return q.Search.empty() && q.MimeTypes.empty() && q.Uploaders.empty() && q.Tags.empty() && q.Metadata.empty() &&
+		q.MinSize == nil && q.MaxSize == nil && q.From == nil && q.To == nil
+}
+
+// Where returns the conditions on files f JOIN users u (" AND ..." or "") with args appended :
+func (q FileQuery) Where(args []interface{}) (string, []interface{}) {
+	var conds []string
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	anyOf := func(values []string, match func(string) string) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = match(v)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")"
+	}
+	text := func(t TextFilter, match func(string) string) {
+		if len(t.Include) > 0 {
+			conds = append(conds, anyOf(t.Include, match))
+		}
+		if len(t.Exclude) > 0 {
+			conds = append(conds, "NOT "+anyOf(t.Exclude, match))
+		}
+	}
+
+	text(q.Search, func(v string) string {
+		return "f.filename ILIKE " + arg("%"+escapeLike(v)+"%")
+	})
+	text(q.MimeTypes, func(v string) string {
+		switch {
+		case v == "*":
+			return "TRUE"
+		case strings.HasSuffix(v, "/*"):
+			return "COALESCE(LOWER(f.mime_type), '') LIKE " + arg(escapeLike(strings.TrimSuffix(v, "*"))+"%")
+		default:
+			return "COALESCE(LOWER(f.mime_type), '') = " + arg(v)
+		}
+	})
+	text(q.Uploaders, func(v string) string {
+		return "u.username ILIKE " + arg("%"+escapeLike(v)+"%")
+	})
+	text(q.Tags, func(v string) string {
+		return "EXISTS (SELECT 1 FROM file_tags ft JOIN tags t ON t.id = ft.tag_id WHERE ft.file_id = f.id AND t.name = " + arg(v) + ")"
+	})
+	text(q.Metadata, func(v string) string {
+		key, value, hasValue := strings.Cut(v, ":")
+		cond := "EXISTS (SELECT 1 FROM file_metadata m WHERE m.file_id = f.id AND m.key = " + arg(strings.ToLower(strings.TrimSpace(key)))
+		if hasValue {
+			cond += " AND LOWER(m.value) = LOWER(" + arg(strings.TrimSpace(value)) + ")"
+		}
+		return cond + ")"
+	})
+
+	if q.MinSize != nil {
+		conds = append(conds, "f.size >= "+arg(*q.MinSize))
+	}
+	if q.MaxSize != nil {
+		conds = append(conds, "f.size <= "+arg(*q.MaxSize))
+	}
+	// uploaded_at is a TIMESTAMP (UTC wall clock) :
+	if q.From != nil {
+		conds = append(conds, "f.uploaded_at >= "+arg(q.From.Format(cursorTimeLayout))+"::timestamp")
+	}
+	if q.To != nil {
+		conds = append(conds, "f.uploaded_at < "+arg(q.To.Format(cursorTimeLayout))+"::timestamp")
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " AND " + strings.Join(conds, " AND "), args
+}
+
+// escapeLike makes user text match literally inside a LIKE pattern :
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// MarshalJSON writes the query back in listing param form (see UnmarshalJSON) :
+func (q FileQuery) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	values := func(t TextFilter) []string {
+		vals := append([]string{}, t.Include...)
+		for _, v := range t.Exclude {
+			vals = append(vals, "!"+v)
+		}
+		return vals
+	}
+	if !q.Search.empty() {
+		out["search"] = values(q.Search)
+	}
+	if !q.MimeTypes.empty() {
+		out["mimeType"] = values(q.MimeTypes)
+	}
+	if !q.Uploaders.empty() {
+		out["uploader"] = values(q.Uploaders)
+	}
+	if !q.Tags.empty() {
+		out["tag"] = values(q.Tags)
+	}
+	if !q.Metadata.empty() {
+		out["meta"] = values(q.Metadata)
+	}
+	if q.MinSize != nil {
+		out["minSize"] = *q.MinSize / 1024
+	}
+	if q.MaxSize != nil {
+		out["maxSize"] = *q.MaxSize / 1024
+	}
+	if q.From != nil {
+		out["startDate"] = q.From.Format(time.RFC3339Nano)
+	}
+	if q.To != nil {
+		out["endDate"] = q.To.Add(-time.Microsecond).Format(time.RFC3339Nano)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON accepts the listing params as an object, e.g.
+// {"mimeType": ["image/*", "!image/gif"], "uploader": "alice", "minSize": 10},
+// so bulk actions take exactly the same filters as the listings.
+func (q *FileQuery) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	parsed, err := ParseFileQuery(valuesFromJSON(raw))
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+// valuesFromJSON turns {"param": value or [values]} into listing params :
+func valuesFromJSON(raw map[string]interface{}) url.Values {
+	v := url.Values{}
+	for key, val := range raw {
+		switch val := val.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range val {
+				v.Add(key, fmt.Sprint(item))
+			}
+		case float64:
+			v.Add(key, strconv.FormatFloat(val, 'f', -1, 64))
+		default:
+			v.Add(key, fmt.Sprint(val))
+		}
+	}
+	return v
+}
+
+// UserFileScope returns the condition selecting which of the user's files a listing covers
+// (space=personal|team|all with team_id, optional folder_id), with its args appended.
+func UserFileScope(userID int, v url.Values, args []interface{}) (string, []interface{}, error) {
+	arg := func(val interface{}) string {
+		args = append(args, val)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var where string
+	switch v.Get("space") {
+	case "personal":
+		where = "f.user_id = " + arg(userID) + " AND f.team_id IS NULL"
+	case "team":
+		teamID, err := strconv.Atoi(v.Get("team_id"))
+		if err != nil {
+			return "", args, ErrInvalidTeamID
+		}
+		teamRole, err := GetTeamRole(teamID, userID)
+		if err != nil {
+			return "", args, err
+		}
+		if teamRole == "" {
+			return "", args, ErrNotTeamMember
+		}
+		where = "f.team_id = " + arg(teamID)
+	case "", "all":
+		p := arg(userID)
+		where = `((f.user_id = ` + p + ` AND f.team_id IS NULL)
+			OR f.team_id IN (SELECT team_id FROM team_members WHERE user_id = ` + p + `))`
+	default:
+		return "", args, ErrInvalidSpace
+	}
+
+	if folder := v.Get("folder_id"); folder != "" {
+		folderID, err := strconv.Atoi(folder)
+		if err != nil {
+			return "", args, ErrInvalidFolderID
+		}
+		where += " AND f.folder_id = " + arg(folderID)
+	}
+	return where, args, nil
+}
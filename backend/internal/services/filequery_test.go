@@ -0,0 +1,265 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFileQueryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string // "" when the query is valid
+	}{
+		{name: "empty", query: ""},
+		{name: "all filters", query: "search=report&mimeType=image/*,application/pdf&uploader=alice&minSize=1&maxSize=2&startDate=2025-09-01&endDate=2025-09-30&tag=invoice&meta=client:acme"},
+		{name: "same day", query: "startDate=2025-09-01&endDate=2025-09-01"},
+		{name: "RFC 3339 dates", query: "startDate=2025-09-01T10:00:00Z&endDate=2025-09-01T12:00:00%2B02:00"},
+		{name: "bad start date", query: "startDate=2025-13-01", wantErr: "startDate must be YYYY-MM-DD or RFC 3339"},
+		{name: "bad end date", query: "endDate=yesterday", wantErr: "endDate must be YYYY-MM-DD or RFC 3339"},
+		{name: "start after end", query: "startDate=2025-09-02&endDate=2025-09-01", wantErr: "startDate is after endDate"},
+		{name: "min above max", query: "minSize=20&maxSize=10", wantErr: "minSize is above maxSize"},
+		{name: "min equals max", query: "minSize=10&maxSize=10"},
+		{name: "negative size", query: "minSize=-1", wantErr: "minSize must be a size in KB"},
+		{name: "size not a number", query: "maxSize=10MB", wantErr: "maxSize must be a size in KB"},
+		{name: "mime without subtype", query: "mimeType=image", wantErr: `mimeType "image"`},
+		{name: "mime with spaces", query: "mimeType=image/p%20ng", wantErr: `mimeType "image/p ng"`},
+		{name: "mime wildcard type", query: "mimeType=*/png", wantErr: `mimeType "*/png"`},
+		{name: "negated malformed mime", query: "mimeType=!text", wantErr: `mimeType "text"`},
+		{name: "mime any", query: "mimeType=*"},
+		{name: "bad tag", query: "tag=%23%23%23", wantErr: "tag"},
+		{name: "bad metadata key", query: "meta=:value", wantErr: "meta"},
+		{name: "too many values", query: "uploader=" + strings.Repeat("a,", maxFilterValues) + "a", wantErr: "at most 20 uploader values"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("bad test query: %v", err)
+			}
+			_, err = ParseFileQuery(v)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.wantErr)
+			}
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("error %v doesn't wrap ErrInvalidFilter", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q doesn't contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFileQueryValues(t *testing.T) {
+	v, _ := url.ParseQuery("search=Q3, report&search=!draft&mimeType=IMAGE/*, !image/gif&uploader=alice,,!&minSize=10&startDate=2025-09-01&endDate=2025-09-30")
+	q, err := ParseFileQuery(v)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (TextFilter{Include: []string{"Q3, report"}, Exclude: []string{"draft"}}); !reflect.DeepEqual(q.Search, want) {
+		t.Errorf("search = %+v, want %+v (free text isn't split or lower-cased)", q.Search, want)
+	}
+	if want := (TextFilter{Include: []string{"image/*"}, Exclude: []string{"image/gif"}}); !reflect.DeepEqual(q.MimeTypes, want) {
+		t.Errorf("mimeType = %+v, want %+v", q.MimeTypes, want)
+	}
+	if want := (TextFilter{Include: []string{"alice"}}); !reflect.DeepEqual(q.Uploaders, want) {
+		t.Errorf("uploader = %+v, want %+v (empty values and a bare ! are ignored)", q.Uploaders, want)
+	}
+	if q.MinSize == nil || *q.MinSize != 10*1024 {
+		t.Errorf("minSize = %v, want 10240 bytes", q.MinSize)
+	}
+	if want := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC); q.To == nil || !q.To.Equal(want) {
+		t.Errorf("endDate = %v, want the whole day, up to %v", q.To, want)
+	}
+}
+
+func TestFileQueryWhere(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		prefix   []interface{} // args already bound by the caller
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:    "no filter",
+			query:   "",
+			wantSQL: "",
+		},
+		{
+			name:     "mime prefix",
+			query:    "mimeType=image/*",
+			wantSQL:  " AND (COALESCE(LOWER(f.mime_type), '') LIKE $1)",
+			wantArgs: []interface{}{"image/%"},
+		},
+		{
+			name:     "exact mime",
+			query:    "mimeType=application/pdf",
+			wantSQL:  " AND (COALESCE(LOWER(f.mime_type), '') = $1)",
+			wantArgs: []interface{}{"application/pdf"},
+		},
+		{
+			name:     "mime any",
+			query:    "mimeType=*",
+			wantSQL:  " AND (TRUE)",
+			wantArgs: nil,
+		},
+		{
+			name:     "mime values OR-ed, negation separate",
+			query:    "mimeType=image/*,application/pdf,!image/gif",
+			wantSQL:  " AND (COALESCE(LOWER(f.mime_type), '') LIKE $1 OR COALESCE(LOWER(f.mime_type), '') = $2) AND NOT (COALESCE(LOWER(f.mime_type), '') = $3)",
+			wantArgs: []interface{}{"image/%", "application/pdf", "image/gif"},
+		},
+		{
+			name:     "numbering continues after the caller's args",
+			query:    "uploader=alice,!bob",
+			prefix:   []interface{}{7, "x"},
+			wantSQL:  " AND (u.username ILIKE $3) AND NOT (u.username ILIKE $4)",
+			wantArgs: []interface{}{7, "x", "%alice%", "%bob%"},
+		},
+		{
+			name:     "search is escaped for LIKE",
+			query:    "search=100%25_done",
+			wantSQL:  " AND (f.filename ILIKE $1)",
+			wantArgs: []interface{}{`%100\%\_done%`},
+		},
+		{
+			name:     "repeated params",
+			query:    "search=a&search=b&search=!c",
+			wantSQL:  " AND (f.filename ILIKE $1 OR f.filename ILIKE $2) AND NOT (f.filename ILIKE $3)",
+			wantArgs: []interface{}{"%a%", "%b%", "%c%"},
+		},
+		{
+			name:     "tags and metadata",
+			query:    "tag=!Archived&meta=Client:ACME&meta=reviewed",
+			wantSQL:  " AND NOT (EXISTS (SELECT 1 FROM file_tags ft JOIN tags t ON t.id = ft.tag_id WHERE ft.file_id = f.id AND t.name = $1)) AND (EXISTS (SELECT 1 FROM file_metadata m WHERE m.file_id = f.id AND m.key = $2 AND LOWER(m.value) = LOWER($3)) OR EXISTS (SELECT 1 FROM file_metadata m WHERE m.file_id = f.id AND m.key = $4))",
+			wantArgs: []interface{}{"archived", "client", "ACME", "reviewed"},
+		},
+		{
+			name:     "sizes and dates",
+			query:    "minSize=1&maxSize=2&startDate=2025-09-01&endDate=2025-09-01",
+			wantSQL:  " AND f.size >= $1 AND f.size <= $2 AND f.uploaded_at >= $3::timestamp AND f.uploaded_at < $4::timestamp",
+			wantArgs: []interface{}{int64(1024), int64(2048), "2025-09-01 00:00:00", "2025-09-02 00:00:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			q, err := ParseFileQuery(v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			sql, args := q.Where(append([]interface{}{}, tt.prefix...))
+			if sql != tt.wantSQL {
+				t.Errorf("sql =\n  %s\nwant\n  %s", sql, tt.wantSQL)
+			}
+			wantArgs := tt.wantArgs
+			if wantArgs == nil {
+				wantArgs = append([]interface{}{}, tt.prefix...)
+			}
+			if len(args) != len(wantArgs) || (len(args) > 0 && !reflect.DeepEqual(args, wantArgs)) {
+				t.Errorf("args = %#v, want %#v", args, wantArgs)
+			}
+		})
+	}
+}
+
+func TestFileQueryJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "empty", query: ""},
+		{name: "text filters with negation", query: "search=Q3 report&search=!draft&uploader=alice,!bob&tag=invoice,!archived&meta=client:acme&meta=!reviewed"},
+		{name: "mime prefixes", query: "mimeType=image/*,!image/gif,application/pdf"},
+		{name: "sizes", query: "minSize=0&maxSize=2048"},
+		{name: "whole days", query: "startDate=2025-09-01&endDate=2025-09-30"},
+		{name: "timestamps", query: "startDate=2025-09-01T10:00:00Z&endDate=2025-09-01T12:30:00.5Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			q, err := ParseFileQuery(v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, err := json.Marshal(q)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var back FileQuery
+			if err := json.Unmarshal(data, &back); err != nil {
+				t.Fatalf("unmarshal %s: %v", data, err)
+			}
+			// the stored form must select exactly the same files :
+			wantSQL, wantArgs := q.Where(nil)
+			gotSQL, gotArgs := back.Where(nil)
+			if gotSQL != wantSQL || !reflect.DeepEqual(gotArgs, wantArgs) {
+				t.Errorf("round trip through %s changed the query:\n  %s %v\nwant\n  %s %v", data, gotSQL, gotArgs, wantSQL, wantArgs)
+			}
+			if back.Empty() != q.Empty() {
+				t.Errorf("Empty() = %v after round trip, want %v", back.Empty(), q.Empty())
+			}
+		})
+	}
+}
+
+func TestFileQueryUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+		check   func(q FileQuery) bool
+	}{
+		{
+			name: "single string and list",
+			json: `{"mimeType": ["image/*", "!image/gif"], "uploader": "alice"}`,
+			check: func(q FileQuery) bool {
+				return len(q.MimeTypes.Include) == 1 && len(q.MimeTypes.Exclude) == 1 && q.Uploaders.Include[0] == "alice"
+			},
+		},
+		{
+			name:  "numeric size",
+			json:  `{"minSize": 10}`,
+			check: func(q FileQuery) bool { return q.MinSize != nil && *q.MinSize == 10240 },
+		},
+		{
+			name:  "null ignored",
+			json:  `{"search": null}`,
+			check: func(q FileQuery) bool { return q.Empty() },
+		},
+		{name: "invalid filter", json: `{"minSize": 20, "maxSize": 10}`, wantErr: true},
+		{name: "invalid mime", json: `{"mimeType": "image"}`, wantErr: true},
+		{name: "not an object", json: `["image/*"]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q FileQuery
+			err := json.Unmarshal([]byte(tt.json), &q)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.check(q) {
+				t.Errorf("unexpected query %+v", q)
+			}
+		})
+	}
+}
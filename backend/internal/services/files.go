@@ -11,13 +11,27 @@ type FileMeta struct {
     //file metadata :
     ID            int       `json:"id"`
     Filename      string    `json:"filename"`
+    Description   string    `json:"description"`
     Filepath      string    `json:"filepath"`
+    Hash          string    `json:"hash"`
+    MimeType      string    `json:"mime_type"`
     Uploader      string    `json:"uploader"`
     Size          int64     `json:"size"`
     UploadedAt    time.Time `json:"uploaded_at"`
     IsMaster      bool      `json:"is_master"`
     IsPublic      bool      `json:"is_public"`
     DownloadCount int       `json:"download_count"`
+    TeamID        *int      `json:"team_id"`
+    FolderID      *int      `json:"folder_id"`
+    QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+
+    // annotations (see LoadFileAnnotations) :
+    Tags     []string          `json:"tags,omitempty"`
+    Metadata map[string]string `json:"metadata,omitempty"`
+
+    // file detail only :
+    Starred       bool `json:"starred"`
+    CommentsCount int  `json:"comments_count"`
 
     // uploader info :
     UploaderID       int       `json:"uploader_id"`
@@ -32,11 +46,12 @@ type FileMeta struct {
 // GetFileByID returns file metadata (and uploader username) or (nil, nil) if not found.
 func GetFileByID(fileID int) (*FileMeta, error) {
     var f FileMeta
+    var teamID, folderID sql.NullInt64
 
     // query files joined with users to get uploader info :
     err := db.DB.QueryRow(`
-        SELECT f.id, f.filename, f.filepath, f.size, f.uploaded_at, 
-               f.is_master, f.is_public, f.download_count,
+        SELECT f.id, f.filename, COALESCE(f.description, ''), f.filepath, f.hash, COALESCE(f.mime_type, ''), f.size, f.uploaded_at, 
+               f.is_master, f.is_public, f.download_count, f.team_id, f.folder_id, f.quarantined_at,
                u.id, u.username, u.email, u.role, u.created_at
         FROM files f
         JOIN users u ON f.user_id = u.id
@@ -44,12 +59,18 @@ func GetFileByID(fileID int) (*FileMeta, error) {
     `, fileID).Scan(
         &f.ID,
         &f.Filename,
+        &f.Description,
         &f.Filepath,
+        &f.Hash,
+        &f.MimeType,
         &f.Size,
         &f.UploadedAt,
         &f.IsMaster,
         &f.IsPublic,
         &f.DownloadCount,
+        &teamID,
+        &folderID,
+        &f.QuarantinedAt,
         &f.UploaderID,
         &f.UploaderUsername,
         &f.UploaderEmail,
@@ -68,5 +89,7 @@ func GetFileByID(fileID int) (*FileMeta, error) {
     }
 
     // return populated structure :
+    f.TeamID = nullIntPtr(teamID)
+    f.FolderID = nullIntPtr(folderID)
     return &f, nil
 }
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInlineUnsupported is returned for types only offered as a download :
+var ErrInlineUnsupported = errors.New("this file type can't be viewed inline, download it instead")
+
+// how an inline view is rendered :
+const (
+	InlinePassive = "passive" // images, audio, video, text : no script can run
+	InlinePDF     = "pdf"     // the browser's PDF viewer (CSP sandbox would block it)
+	InlineActive  = "active"  // HTML, SVG, XML : rendered in a CSP sandbox, never with script
+)
+
+// active content, by media type and by extension (when the upload was detected as text / XML) :
+var (
+	activeTypes = map[string]bool{
+		"text/html": true, "application/xhtml+xml": true, "image/svg+xml": true, "text/xml": true, "application/xml": true,
+	}
+	activeExts = map[string]string{
+		".html": "text/html", ".htm": "text/html", ".xhtml": "application/xhtml+xml",
+		".svg": "image/svg+xml", ".xml": "text/xml", ".xsl": "text/xml",
+	}
+	inlineImages = map[string]bool{
+		"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true, "image/avif": true,
+		"image/bmp": true, "image/x-icon": true, "image/vnd.microsoft.icon": true,
+	}
+)
+
+// InlineType decides how the file may be shown inline : the Content-Type to serve it with and how
+// it's rendered. The stored (sniffed at upload) type wins, the extension only fills in for unknown
+// content or marks text as HTML / SVG / XML; anything else gets ErrInlineUnsupported.
+func InlineType(filename string, mimeType string) (string, string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	base, _, _ := mime.ParseMediaType(mimeType)
+	if base == "" || base == "application/octet-stream" {
+		base, _, _ = mime.ParseMediaType(mime.TypeByExtension(ext))
+	}
+
+	switch {
+	case (activeTypes[base] || strings.HasPrefix(base, "text/")) && activeExts[ext] != "":
+		// SVG is sniffed as text/xml, HTML fragments as text/plain :
+		return withCharset(activeExts[ext]), InlineActive, nil
+	case activeTypes[base]:
+		return withCharset(base), InlineActive, nil
+	case base == "application/pdf":
+		return base, InlinePDF, nil
+	case inlineImages[base], strings.HasPrefix(base, "audio/"), strings.HasPrefix(base, "video/"):
+		return base, InlinePassive, nil
+	case strings.HasPrefix(base, "text/"), base == "application/json":
+		// CSV, Markdown, source code … are shown as they are :
+		return "text/plain; charset=utf-8", InlinePassive, nil
+	}
+	return "", "", ErrInlineUnsupported
+}
+
+func withCharset(mediaType string) string {
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+xml") || mediaType == "application/xml" {
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
+
+// InlineCSP returns the Content-Security-Policy of an inline view. Nothing is ever allowed to load
+// or run; passive & active content also get a CSP sandbox (opaque origin, no script, no forms).
+func InlineCSP(rendering string) string {
+	ancestors := "frame-ancestors 'self'"
+	if origin := config.AppConfig.AppBaseURL; origin != "" {
+		ancestors += " " + strings.TrimRight(origin, "/")
+	}
+	switch rendering {
+	case InlinePDF:
+		return "default-src 'none'; style-src 'unsafe-inline'; " + ancestors
+	case InlineActive:
+		return "default-src 'none'; img-src data:; style-src 'unsafe-inline'; font-src data:; sandbox; " + ancestors
+	default:
+		return "default-src 'none'; img-src 'self'; media-src 'self'; style-src 'unsafe-inline'; sandbox; " + ancestors
+	}
+}
+
+// ---- signed links for the sandbox origin ----
+
+const viewTokenAudience = "file-view"
+
+// ViewGrant is who a sandbox link views a file as : the viewer's session (and the admin behind it
+// when impersonating), so revoking either ends the link too.
+type ViewGrant struct {
+	FileID               int
+	UserID               int // 0 for guests
+	Username             string
+	Role                 string // current role, filled by ParseViewToken
+	Session              int
+	ImpersonatorID       int
+	ImpersonatorUsername string
+	ImpersonatorSession  int
+	Expires              time.Time // the session token's expiry, a link never outlives it
+}
+
+// ViewGrantFromClaims is the grant of the session token the link is created with :
+func ViewGrantFromClaims(fileID int, c *models.Claims) ViewGrant {
+	g := ViewGrant{
+		FileID:               fileID,
+		UserID:               c.UserID,
+		Username:             c.Username,
+		Session:              c.Session,
+		ImpersonatorID:       c.ImpersonatorID,
+		ImpersonatorUsername: c.ImpersonatorUsername,
+		ImpersonatorSession:  c.ImpersonatorSession,
+	}
+	if c.ExpiresAt != nil {
+		g.Expires = c.ExpiresAt.Time
+	}
+	return g
+}
+
+// viewClaims is a short-lived grant to view one file on the sandbox origin :
+type viewClaims struct {
+	FileID               int    `json:"fid"`
+	Session              int    `json:"sv,omitempty"`
+	ImpersonatorID       int    `json:"imp,omitempty"`
+	ImpersonatorUsername string `json:"imp_name,omitempty"`
+	ImpersonatorSession  int    `json:"imp_sv,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// viewTokenKey derives the link signing key from JWT_KEY, so a link is never a valid session token :
+func viewTokenKey() []byte {
+	mac := hmac.New(sha256.New, []byte(config.AppConfig.JWTKey))
+	mac.Write([]byte(viewTokenAudience))
+	return mac.Sum(nil)
+}
+
+// SandboxViewURL returns the signed sandbox-origin URL viewing the file as g.UserID (0 for guests) :
+func SandboxViewURL(g ViewGrant) (string, error) {
+	now := time.Now()
+	expires := now.Add(time.Duration(config.AppConfig.ViewTokenTTLMinutes) * time.Minute)
+	if !g.Expires.IsZero() && g.Expires.Before(expires) {
+		expires = g.Expires
+	}
+	claims := viewClaims{
+		FileID:               g.FileID,
+		Session:              g.Session,
+		ImpersonatorID:       g.ImpersonatorID,
+		ImpersonatorUsername: g.ImpersonatorUsername,
+		ImpersonatorSession:  g.ImpersonatorSession,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(g.UserID),
+			Audience:  jwt.ClaimStrings{viewTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(viewTokenKey())
+	if err != nil {
+		return "", err
+	}
+	return config.AppConfig.ViewSandboxOrigin + "/api/view/" + token, nil
+}
+
+// ParseViewToken checks a sandbox link like AuthMiddleware checks a session token : the viewer's
+// session (and the impersonating admin's) must still be valid. The role is read again, so access is
+// checked as of now.
+func ParseViewToken(raw string) (*ViewGrant, error) {
+	claims := &viewClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(*jwt.Token) (interface{}, error) {
+		return viewTokenKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(viewTokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	g := &ViewGrant{FileID: claims.FileID, UserID: userID}
+	if userID == 0 {
+		return g, nil
+	}
+
+	if err := viewSessionValid(userID, claims.Session); err != nil {
+		return nil, err
+	}
+	if claims.ImpersonatorID != 0 {
+		if err := viewSessionValid(claims.ImpersonatorID, claims.ImpersonatorSession); err != nil {
+			return nil, err
+		}
+		g.ImpersonatorID, g.ImpersonatorUsername = claims.ImpersonatorID, claims.ImpersonatorUsername
+		g.ImpersonatorSession = claims.ImpersonatorSession
+	}
+	g.Session = claims.Session
+
+	err = db.DB.QueryRow(`SELECT username, role FROM users WHERE id = $1`, userID).Scan(&g.Username, &g.Role)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// viewSessionValid maps an ended session (logout, revocation, deactivation) to ErrInvalidToken :
+func viewSessionValid(userID int, session int) error {
+	err := CheckSession(userID, session)
+	if err == ErrAccountInactive || err == ErrSessionRevoked {
+		return ErrInvalidToken
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"backend/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestInlineType(t *testing.T) {
+	tests := []struct {
+		name          string
+		filename      string
+		mimeType      string
+		wantType      string
+		wantRendering string
+		wantErr       error
+	}{
+		{name: "image", filename: "cat.png", mimeType: "image/png", wantType: "image/png", wantRendering: InlinePassive},
+		{name: "video", filename: "clip.mp4", mimeType: "video/mp4", wantType: "video/mp4", wantRendering: InlinePassive},
+		{name: "pdf", filename: "doc.pdf", mimeType: "application/pdf", wantType: "application/pdf", wantRendering: InlinePDF},
+		{name: "pdf from extension", filename: "doc.pdf", mimeType: "", wantType: "application/pdf", wantRendering: InlinePDF},
+		{name: "csv shown as text", filename: "data.csv", mimeType: "text/csv", wantType: "text/plain; charset=utf-8", wantRendering: InlinePassive},
+		{name: "json shown as text", filename: "data.json", mimeType: "application/json", wantType: "text/plain; charset=utf-8", wantRendering: InlinePassive},
+		{name: "script shown as text", filename: "app.js", mimeType: "application/octet-stream", wantType: "text/plain; charset=utf-8", wantRendering: InlinePassive},
+		{name: "html", filename: "page.html", mimeType: "text/html; charset=utf-8", wantType: "text/html; charset=utf-8", wantRendering: InlineActive},
+		{name: "html fragment sniffed as text", filename: "PAGE.HTM", mimeType: "text/plain; charset=utf-8", wantType: "text/html; charset=utf-8", wantRendering: InlineActive},
+		{name: "svg sniffed as xml", filename: "logo.svg", mimeType: "text/xml; charset=utf-8", wantType: "image/svg+xml; charset=utf-8", wantRendering: InlineActive},
+		{name: "svg from extension", filename: "logo.svg", mimeType: "application/octet-stream", wantType: "image/svg+xml; charset=utf-8", wantRendering: InlineActive},
+		{name: "stored html wins over the extension", filename: "cat.png", mimeType: "text/html", wantType: "text/html; charset=utf-8", wantRendering: InlineActive},
+		{name: "xhtml", filename: "page.txt", mimeType: "application/xhtml+xml", wantType: "application/xhtml+xml; charset=utf-8", wantRendering: InlineActive},
+		{name: "archive", filename: "all.zip", mimeType: "application/zip", wantErr: ErrInlineUnsupported},
+		{name: "unlisted image", filename: "scan.tiff", mimeType: "image/tiff", wantErr: ErrInlineUnsupported},
+		{name: "unknown", filename: "blob", mimeType: "", wantErr: ErrInlineUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, rendering, err := InlineType(tt.filename, tt.mimeType)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if typ != tt.wantType || rendering != tt.wantRendering {
+				t.Errorf("InlineType(%q, %q) = %q %q, want %q %q", tt.filename, tt.mimeType, typ, rendering, tt.wantType, tt.wantRendering)
+			}
+		})
+	}
+}
+
+func TestInlineCSP(t *testing.T) {
+	prev := config.AppConfig.AppBaseURL
+	t.Cleanup(func() { config.AppConfig.AppBaseURL = prev })
+
+	tests := []struct {
+		rendering     string
+		baseURL       string
+		wantSandbox   bool
+		wantAncestors string
+	}{
+		{rendering: InlinePassive, wantSandbox: true, wantAncestors: "frame-ancestors 'self'"},
+		{rendering: InlineActive, wantSandbox: true, wantAncestors: "frame-ancestors 'self'"},
+		{rendering: InlinePDF, wantSandbox: false, wantAncestors: "frame-ancestors 'self'"},
+		{rendering: InlineActive, baseURL: "https://vault.example.com/", wantSandbox: true, wantAncestors: "frame-ancestors 'self' https://vault.example.com"},
+		{rendering: "unknown", wantSandbox: true, wantAncestors: "frame-ancestors 'self'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rendering+" "+tt.baseURL, func(t *testing.T) {
+			config.AppConfig.AppBaseURL = tt.baseURL
+			csp := InlineCSP(tt.rendering)
+			if !strings.HasPrefix(csp, "default-src 'none';") {
+				t.Errorf("CSP %q doesn't start from default-src 'none'", csp)
+			}
+			if strings.Contains(csp, "script-src") || strings.Contains(csp, "allow-scripts") {
+				t.Errorf("CSP %q lets script run", csp)
+			}
+			if got := strings.Contains(csp, "; sandbox;"); got != tt.wantSandbox {
+				t.Errorf("CSP %q sandboxed = %v, want %v", csp, got, tt.wantSandbox)
+			}
+			if !strings.HasSuffix(csp, tt.wantAncestors) {
+				t.Errorf("CSP %q doesn't end with %q", csp, tt.wantAncestors)
+			}
+		})
+	}
+}
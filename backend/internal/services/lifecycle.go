@@ -0,0 +1,130 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"errors"
+	"os"
+)
+
+// ErrFileNotFound is returned when the file row doesn't exist :
+var ErrFileNotFound = errors.New("file not found")
+
+// DeleteFile removes one file row inside a transaction, keeping dedup references and
+// the owner's quota counter consistent. The physical object is only removed once no
+// other row points at it.
+func DeleteFile(fileID int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// locking the row :
+	var userID, refCount int
+	var teamID sql.NullInt64
+	var hash, path string
+	var size int64
+	var isMaster bool
+	err = tx.QueryRow(`
+		SELECT user_id, team_id, hash, filepath, size, is_master, reference_count
+		FROM files WHERE id=$1 FOR UPDATE`, fileID,
+	).Scan(&userID, &teamID, &hash, &path, &size, &isMaster, &refCount)
+	if err == sql.ErrNoRows {
+		return ErrFileNotFound
+	} else if err != nil {
+		return err
+	}
+
+	removePhysical := false
+	switch {
+	case !isMaster:
+		// Case 1: duplicate → decrement master's ref_count :
+		_, err = tx.Exec(`
+			UPDATE files SET reference_count = reference_count - 1
+			WHERE hash=$1 AND is_master = TRUE`, hash)
+	case refCount > 1:
+		// Case 2: master with duplicates → promote one of them :
+		var newMasterID int
+		err = tx.QueryRow(`
+			SELECT id FROM files
+			WHERE hash=$1 AND is_master = FALSE
+			ORDER BY id LIMIT 1 FOR UPDATE`, hash,
+		).Scan(&newMasterID)
+		if err == nil {
+			_, err = tx.Exec(`
+				UPDATE files SET is_master = TRUE, filepath = $1, reference_count = $2
+				WHERE id=$3`, path, refCount-1, newMasterID)
+		}
+	default:
+		// Case 3: only reference → physical file goes too :
+		removePhysical = true
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM files WHERE id=$1`, fileID); err != nil {
+		return err
+	}
+	if err := ReleaseQuota(tx, userID, nullIntPtr(teamID), size); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if removePhysical {
+		_ = os.Remove(path)
+	}
+	return nil
+}
+
+// TransferFile gives one file to another user. Personal files move their size to the new
+// owner's counter (quota enforced) and leave the old owner's folders; team files keep
+// counting against the team and may only go to a member of it.
+func TransferFile(fileID int, toUserID int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var fromID int
+	var teamID sql.NullInt64
+	var size int64
+	err = tx.QueryRow(`SELECT user_id, team_id, size FROM files WHERE id=$1 FOR UPDATE`, fileID).Scan(&fromID, &teamID, &size)
+	if err == sql.ErrNoRows {
+		return ErrFileNotFound
+	} else if err != nil {
+		return err
+	}
+	if fromID == toUserID {
+		return nil
+	}
+
+	if teamID.Valid {
+		teamRole, err := GetTeamRole(int(teamID.Int64), toUserID)
+		if err != nil {
+			return err
+		}
+		if teamRole == "" {
+			return ErrNotTeamMember
+		}
+		if _, err := tx.Exec(`UPDATE files SET user_id=$2 WHERE id=$1`, fileID, toUserID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	if _, _, err := ReserveQuota(tx, toUserID, nil, size); err != nil {
+		return err
+	}
+	if err := ReleaseQuota(tx, fromID, nil, size); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE files SET user_id=$2, folder_id=NULL WHERE id=$1`, fileID, toUserID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
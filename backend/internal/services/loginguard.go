@@ -0,0 +1,201 @@
+package services
+
+import (
+	"backend/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginAttempt tracks failed logins for one key ("ip:<addr>" or "user:<name>") :
+type loginAttempt struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// Lockout is the admin-facing view of a tracked key :
+type Lockout struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+	Locked      bool      `json:"locked"`
+}
+
+var (
+	loginAttempts = make(map[string]*loginAttempt)
+	loginMu       sync.Mutex
+
+	// dummy hash compared against for unknown users, so timing doesn't leak existence :
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("filevault-dummy-password"), bcrypt.DefaultCost)
+)
+
+// cleaning stale attempts :
+func init() {
+	go func() {
+		for {
+			time.Sleep(5 * time.Minute)
+			window := failureWindow()
+			loginMu.Lock()
+			for key, a := range loginAttempts {
+				if time.Now().After(a.LockedUntil) && time.Since(a.LastFailure) > window {
+					delete(loginAttempts, key)
+				}
+			}
+			loginMu.Unlock()
+		}
+	}()
+}
+
+func failureWindow() time.Duration {
+	mins := config.AppConfig.LoginFailureWindowMins
+	if mins <= 0 {
+		mins = 15
+	}
+	return time.Duration(mins) * time.Minute
+}
+
+func ipKey(ip string) string { return "ip:" + ip }
+func usernameKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// ReserveLoginAttempt checks the lockout and counts the attempt in one step : it returns how long
+// the IP or username is still locked (nothing counted), or 0 with the attempt already recorded as a
+// failure for both keys. Counting before bcrypt runs means parallel guesses can't all slip through
+// the check before the first one fails. RecordLoginSuccess / CancelLoginAttempt take it back.
+func ReserveLoginAttempt(ip string, username string) time.Duration {
+	cfg := config.AppConfig
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	var wait time.Duration
+	for _, key := range []string{ipKey(ip), usernameKey(username)} {
+		if a, ok := loginAttempts[key]; ok {
+			if d := time.Until(a.LockedUntil); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	registerFailure(ipKey(ip), cfg.LoginIPMaxFailures)
+	registerFailure(usernameKey(username), cfg.LoginMaxFailures)
+	return 0
+}
+
+func registerFailure(key string, threshold int) {
+	if threshold <= 0 {
+		return
+	}
+	a, ok := loginAttempts[key]
+	if !ok || time.Since(a.LastFailure) > failureWindow() {
+		a = &loginAttempt{}
+		loginAttempts[key] = a
+	}
+	a.Failures++
+	a.LastFailure = time.Now()
+	a.LockedUntil = lockUntil(a, threshold)
+}
+
+// refundFailure takes back one reserved attempt that turned out not to be a failed login :
+func refundFailure(key string, threshold int) {
+	a, ok := loginAttempts[key]
+	if !ok || threshold <= 0 {
+		return
+	}
+	if a.Failures--; a.Failures <= 0 {
+		delete(loginAttempts, key)
+		return
+	}
+	a.LockedUntil = lockUntil(a, threshold)
+}
+
+// lockUntil is the end of the lock for a's failures, base * 2^(failures-threshold) capped (zero below threshold) :
+func lockUntil(a *loginAttempt, threshold int) time.Time {
+	if a.Failures < threshold {
+		return time.Time{}
+	}
+	base := time.Duration(config.AppConfig.LoginLockoutBaseSecs) * time.Second
+	max := time.Duration(config.AppConfig.LoginLockoutMaxMinutes) * time.Minute
+	lock := base
+	for i := threshold; i < a.Failures && lock < max; i++ {
+		lock *= 2
+	}
+	if lock > max {
+		lock = max
+	}
+	return a.LastFailure.Add(lock)
+}
+
+// RecordLoginSuccess forgets failures for the username and takes back the reserved IP attempt.
+// Older IP failures are kept so one valid account can't reset an attacker's counter.
+func RecordLoginSuccess(ip string, username string) {
+	loginMu.Lock()
+	delete(loginAttempts, usernameKey(username))
+	refundFailure(ipKey(ip), config.AppConfig.LoginIPMaxFailures)
+	loginMu.Unlock()
+}
+
+// CancelLoginAttempt takes back a reserved attempt that couldn't be checked (server error) :
+func CancelLoginAttempt(ip string, username string) {
+	cfg := config.AppConfig
+	loginMu.Lock()
+	refundFailure(ipKey(ip), cfg.LoginIPMaxFailures)
+	refundFailure(usernameKey(username), cfg.LoginMaxFailures)
+	loginMu.Unlock()
+}
+
+// ListLockouts returns every tracked key, locked ones first :
+func ListLockouts() []Lockout {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+
+	list := make([]Lockout, 0, len(loginAttempts))
+	for key, a := range loginAttempts {
+		l := Lockout{Key: key, Failures: a.Failures, LastFailure: a.LastFailure}
+		if time.Now().Before(a.LockedUntil) {
+			l.Locked = true
+			l.LockedUntil = a.LockedUntil
+		}
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Locked != list[j].Locked {
+			return list[i].Locked
+		}
+		return list[i].LastFailure.After(list[j].LastFailure)
+	})
+	return list
+}
+
+// ClearLockout removes a tracked key, returns false if it wasn't tracked :
+func ClearLockout(key string) bool {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+	if _, ok := loginAttempts[key]; !ok {
+		return false
+	}
+	delete(loginAttempts, key)
+	return true
+}
+
+// ClearAllLockouts forgets every tracked key and returns how many were removed :
+func ClearAllLockouts() int {
+	loginMu.Lock()
+	defer loginMu.Unlock()
+	n := len(loginAttempts)
+	loginAttempts = make(map[string]*loginAttempt)
+	return n
+}
+
+// CompareDummyPassword burns the same bcrypt time as a real check for unknown users :
+func CompareDummyPassword(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
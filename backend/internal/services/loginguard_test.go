@@ -0,0 +1,123 @@
+package services
+
+import (
+	"backend/internal/config"
+	"sync"
+	"testing"
+)
+
+// withLoginLimits sets the lockout thresholds for one test, starting from no tracked attempts :
+func withLoginLimits(t *testing.T, userMax int, ipMax int) {
+	t.Helper()
+	prev := config.AppConfig
+	config.AppConfig.LoginMaxFailures = userMax
+	config.AppConfig.LoginIPMaxFailures = ipMax
+	config.AppConfig.LoginLockoutBaseSecs = 60
+	config.AppConfig.LoginLockoutMaxMinutes = 15
+	config.AppConfig.LoginFailureWindowMins = 15
+	ClearAllLockouts()
+	t.Cleanup(func() {
+		config.AppConfig = prev
+		ClearAllLockouts()
+	})
+}
+
+func TestReserveLoginAttemptConcurrent(t *testing.T) {
+	withLoginLimits(t, 5, 100)
+
+	// 50 parallel guesses : only as many as the threshold get to check a pswd :
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ReserveLoginAttempt("10.0.0.1", "alice") == 0 {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Errorf("%d attempts got through, want 5", allowed)
+	}
+}
+
+func TestReserveLoginAttempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    func(ip string, user string)
+		wantLock bool
+	}{
+		{
+			name: "below the threshold",
+			steps: func(ip string, user string) {
+				ReserveLoginAttempt(ip, user)
+				ReserveLoginAttempt(ip, user)
+			},
+		},
+		{
+			name: "failures reach the threshold",
+			steps: func(ip string, user string) {
+				for i := 0; i < 3; i++ {
+					ReserveLoginAttempt(ip, user)
+				}
+			},
+			wantLock: true,
+		},
+		{
+			name: "success on the last attempt takes it back",
+			steps: func(ip string, user string) {
+				for i := 0; i < 3; i++ {
+					ReserveLoginAttempt(ip, user)
+				}
+				RecordLoginSuccess(ip, user)
+			},
+		},
+		{
+			name: "cancelled attempts don't count",
+			steps: func(ip string, user string) {
+				for i := 0; i < 5; i++ {
+					ReserveLoginAttempt(ip, user)
+					CancelLoginAttempt(ip, user)
+				}
+			},
+		},
+		{
+			name: "usernames are case-insensitive",
+			steps: func(ip string, user string) {
+				ReserveLoginAttempt(ip, "Bob")
+				ReserveLoginAttempt("10.0.0.9", " bob")
+				ReserveLoginAttempt("10.0.0.8", "BOB")
+			},
+			wantLock: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLoginLimits(t, 3, 100)
+			tt.steps("10.0.0.1", "bob")
+			locked := ReserveLoginAttempt("10.0.0.1", "bob") > 0
+			if locked != tt.wantLock {
+				t.Errorf("locked = %v, want %v", locked, tt.wantLock)
+			}
+		})
+	}
+}
+
+func TestLoginSuccessKeepsOtherIPFailures(t *testing.T) {
+	withLoginLimits(t, 100, 3)
+
+	// an attacker's failures from one IP aren't reset by a valid login from it :
+	ReserveLoginAttempt("10.0.0.2", "victim")
+	ReserveLoginAttempt("10.0.0.2", "victim")
+	ReserveLoginAttempt("10.0.0.2", "attacker")
+	RecordLoginSuccess("10.0.0.2", "attacker")
+	ReserveLoginAttempt("10.0.0.2", "victim")
+	if ReserveLoginAttempt("10.0.0.2", "victim") == 0 {
+		t.Errorf("IP not locked after 3 failed attempts and one successful login")
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"backend/internal/db"
+	"backend/internal/mailer"
+	"log"
+	"time"
+)
+
+// notification kinds :
+const (
+	NotifyQuotaWarning = "quota.warning"
+)
+
+// Notification is one in-app message for a user :
+type Notification struct {
+	ID        int        `json:"id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Notify stores an in-app notification and, if email is set, also mails it (in the background) :
+func Notify(userID int, kind string, title string, body string, email bool) error {
+	_, err := db.DB.Exec(
+		`INSERT INTO notifications (user_id, kind, title, body) VALUES ($1, $2, $3, $4)`,
+		userID, kind, title, body,
+	)
+	if err != nil {
+		return err
+	}
+
+	if email {
+		go func() {
+			var address string
+			if err := db.DB.QueryRow(`SELECT email FROM users WHERE id=$1`, userID).Scan(&address); err != nil {
+				log.Printf("❌ notification mail lookup for user %d failed: %v", userID, err)
+				return
+			}
+			if err := mailer.Send(address, title, body); err != nil {
+				log.Printf("❌ notification mail to user %d failed: %v", userID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// ListNotifications returns a page of the user's notifications and the unread count :
+func ListNotifications(userID int, unreadOnly bool, limit int, offset int) ([]Notification, int, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, kind, title, body, read_at, created_at
+		FROM notifications
+		WHERE user_id = $1 AND ($2 = FALSE OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`,
+		userID, unreadOnly, limit, offset,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	list := make([]Notification, 0)
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.Title, &n.Body, &n.ReadAt, &n.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var unread int
+	err = db.DB.QueryRow(`SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND read_at IS NULL`, userID).Scan(&unread)
+	return list, unread, err
+}
+
+// MarkNotificationRead marks one of the user's notifications as read :
+func MarkNotificationRead(userID int, id int) (bool, error) {
+	res, err := db.DB.Exec(
+		`UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id=$1 AND user_id=$2`, id, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification of the user as read :
+func MarkAllNotificationsRead(userID int) (int, error) {
+	res, err := db.DB.Exec(`UPDATE notifications SET read_at = NOW() WHERE user_id=$1 AND read_at IS NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
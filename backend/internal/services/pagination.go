@@ -0,0 +1,198 @@
+package services
+
+import (
+	"backend/internal/db"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// page sizes of the file listings :
+const (
+	DefaultFilePageSize = 50
+	MaxFilePageSize     = 200
+)
+
+var (
+	ErrInvalidPageSize = fmt.Errorf("limit must be 1-%d", MaxFilePageSize)
+	ErrInvalidSort     = errors.New("sort must be name, size, date or downloads; order asc or desc")
+	ErrInvalidCursor   = errors.New("invalid or stale cursor (sort and order must not change between pages)")
+)
+
+// sortable columns of files f; every key is paired with f.id so the order is total :
+var fileSortColumns = map[string]string{
+	"name":      "LOWER(f.filename)",
+	"size":      "f.size",
+	"date":      "f.uploaded_at",
+	"downloads": "f.download_count",
+}
+
+// cursor dates are wall-clock (files.uploaded_at is a TIMESTAMP), compared as ::timestamp :
+const cursorTimeLayout = "2006-01-02 15:04:05.999999"
+
+// FilePage is the paging & sorting contract shared by the file listings :
+// ?limit=&sort=name|size|date|downloads&order=asc|desc&cursor=<next_cursor of the previous page>
+type FilePage struct {
+	Limit int
+	Sort  string
+	Order string
+	after *fileCursor
+}
+
+// fileCursor is the position after the last row of a page (base64 JSON on the wire) :
+type fileCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// FileKey holds the sortable values of a listed row, to build the next cursor :
+type FileKey struct {
+	ID         int
+	Filename   string
+	Size       int64
+	UploadedAt time.Time
+	Downloads  int
+}
+
+// ParseFilePage reads limit / sort / order / cursor; names sort ascending, the rest descending by default.
+func ParseFilePage(q url.Values) (FilePage, error) {
+	p := FilePage{Limit: DefaultFilePageSize, Sort: q.Get("sort"), Order: q.Get("order")}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxFilePageSize {
+			return p, ErrInvalidPageSize
+		}
+		p.Limit = n
+	}
+	if p.Sort == "" {
+		p.Sort = "date"
+	}
+	if _, ok := fileSortColumns[p.Sort]; !ok {
+		return p, ErrInvalidSort
+	}
+	switch p.Order {
+	case "":
+		p.Order = "desc"
+		if p.Sort == "name" {
+			p.Order = "asc"
+		}
+	case "asc", "desc":
+	default:
+		return p, ErrInvalidSort
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, ErrInvalidCursor
+		}
+		var c fileCursor
+		if err := json.Unmarshal(raw, &c); err != nil || c.Sort != p.Sort || c.Order != p.Order || c.ID <= 0 {
+			return p, ErrInvalidCursor
+		}
+		if _, err := c.value(); err != nil {
+			return p, ErrInvalidCursor
+		}
+		p.after = &c
+	}
+	return p, nil
+}
+
+// First reports whether this is the first page (totals are only computed there) :
+func (p FilePage) First() bool {
+	return p.after == nil
+}
+
+// value converts the cursor value to the sort column's type :
+func (c fileCursor) value() (interface{}, error) {
+	switch c.Sort {
+	case "name":
+		return c.Value, nil
+	case "date":
+		if _, err := time.Parse(cursorTimeLayout, c.Value); err != nil {
+			return nil, err
+		}
+		return c.Value, nil
+	default:
+		return strconv.ParseInt(c.Value, 10, 64)
+	}
+}
+
+// Where returns the keyset condition (" AND ..." or "") with its args appended :
+func (p FilePage) Where(args []interface{}) (string, []interface{}) {
+	if p.after == nil {
+		return "", args
+	}
+	v, _ := p.after.value()
+	args = append(args, v, p.after.ID)
+	placeholder := fmt.Sprintf("$%d", len(args)-1)
+	switch p.Sort {
+	case "name":
+		placeholder = "LOWER(" + placeholder + ")"
+	case "date":
+		placeholder += "::timestamp"
+	}
+	op := "<"
+	if p.Order == "asc" {
+		op = ">"
+	}
+	return fmt.Sprintf(" AND (%s, f.id) %s (%s, $%d)", fileSortColumns[p.Sort], op, placeholder, len(args)), args
+}
+
+// OrderLimit returns the ORDER BY / LIMIT tail; one extra row tells whether a next page exists.
+func (p FilePage) OrderLimit() string {
+	dir := "DESC"
+	if p.Order == "asc" {
+		dir = "ASC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, f.id %s LIMIT %d", fileSortColumns[p.Sort], dir, dir, p.Limit+1)
+}
+
+// Next trims the extra row off a page of n rows and returns the cursor after the last kept
+// one (keyOf gives its sortable values), "" when this was the last page.
+func (p FilePage) Next(n int, keyOf func(i int) FileKey) (int, string) {
+	if n <= p.Limit {
+		return n, ""
+	}
+	k := keyOf(p.Limit - 1)
+	c := fileCursor{Sort: p.Sort, Order: p.Order, ID: k.ID}
+	switch p.Sort {
+	case "name":
+		c.Value = k.Filename
+	case "size":
+		c.Value = strconv.FormatInt(k.Size, 10)
+	case "date":
+		c.Value = k.UploadedAt.Format(cursorTimeLayout)
+	case "downloads":
+		c.Value = strconv.Itoa(k.Downloads)
+	}
+	raw, _ := json.Marshal(c)
+	return p.Limit, base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// FileTotals aggregates every row matching a listing's filters :
+type FileTotals struct {
+	Total        int   `json:"total"`
+	OriginalSize int64 `json:"originalSize"`
+	DedupSize    int64 `json:"dedupSize"`
+}
+
+// CountFiles computes FileTotals for a WHERE clause over files f JOIN users u :
+func CountFiles(where string, args []interface{}) (*FileTotals, error) {
+	var t FileTotals
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(f.size), 0), COALESCE(SUM(f.size) FILTER (WHERE f.is_master), 0)
+		FROM files f JOIN users u ON f.user_id = u.id
+		WHERE `+where, args...,
+	).Scan(&t.Total, &t.OriginalSize, &t.DedupSize)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/base64"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// encodeCursor builds a cursor by hand, as a client tampering with one would :
+func encodeCursor(json string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(json))
+}
+
+func TestParseFilePage(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int
+		wantSort  string
+		wantOrder string
+		wantErr   error
+	}{
+		{name: "defaults", query: "", wantLimit: DefaultFilePageSize, wantSort: "date", wantOrder: "desc"},
+		{name: "names ascend by default", query: "sort=name", wantLimit: DefaultFilePageSize, wantSort: "name", wantOrder: "asc"},
+		{name: "sizes descend by default", query: "sort=size", wantLimit: DefaultFilePageSize, wantSort: "size", wantOrder: "desc"},
+		{name: "explicit order", query: "sort=downloads&order=asc&limit=10", wantLimit: 10, wantSort: "downloads", wantOrder: "asc"},
+		{name: "max limit", query: "limit=200", wantLimit: MaxFilePageSize, wantSort: "date", wantOrder: "desc"},
+		{name: "limit zero", query: "limit=0", wantErr: ErrInvalidPageSize},
+		{name: "limit too big", query: "limit=201", wantErr: ErrInvalidPageSize},
+		{name: "limit not a number", query: "limit=ten", wantErr: ErrInvalidPageSize},
+		{name: "unknown sort", query: "sort=owner", wantErr: ErrInvalidSort},
+		{name: "unknown order", query: "order=up", wantErr: ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			p, err := ParseFilePage(v)
+			if err != tt.wantErr {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.Limit != tt.wantLimit || p.Sort != tt.wantSort || p.Order != tt.wantOrder {
+				t.Errorf("page = %d %s %s, want %d %s %s", p.Limit, p.Sort, p.Order, tt.wantLimit, tt.wantSort, tt.wantOrder)
+			}
+			if !p.First() {
+				t.Errorf("page without a cursor isn't the first one")
+			}
+			if sql, args := p.Where(nil); sql != "" || args != nil {
+				t.Errorf("first page has a keyset condition %q %v", sql, args)
+			}
+		})
+	}
+}
+
+func TestFilePageCursorRoundTrip(t *testing.T) {
+	uploaded := time.Date(2025, 9, 1, 10, 30, 15, 123456789, time.UTC)
+	rows := []FileKey{
+		{ID: 9, Filename: "Report.pdf", Size: 2048, UploadedAt: uploaded.Add(time.Hour), Downloads: 7},
+		{ID: 4, Filename: "notes.txt", Size: 1024, UploadedAt: uploaded, Downloads: 3},
+		{ID: 2, Filename: "a.png", Size: 10, UploadedAt: uploaded.Add(-time.Hour), Downloads: 0},
+	}
+	keyOf := func(i int) FileKey { return rows[i] }
+
+	tests := []struct {
+		query    string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{query: "sort=name", wantSQL: " AND (LOWER(f.filename), f.id) > (LOWER($2), $3)", wantArgs: []interface{}{"x", "notes.txt", 4}},
+		{query: "sort=name&order=desc", wantSQL: " AND (LOWER(f.filename), f.id) < (LOWER($2), $3)", wantArgs: []interface{}{"x", "notes.txt", 4}},
+		{query: "sort=size", wantSQL: " AND (f.size, f.id) < ($2, $3)", wantArgs: []interface{}{"x", int64(1024), 4}},
+		{query: "sort=downloads&order=asc", wantSQL: " AND (f.download_count, f.id) > ($2, $3)", wantArgs: []interface{}{"x", int64(3), 4}},
+		// dates keep microseconds (what a TIMESTAMP stores), nanoseconds are dropped :
+		{query: "sort=date", wantSQL: " AND (f.uploaded_at, f.id) < ($2::timestamp, $3)", wantArgs: []interface{}{"x", "2025-09-01 10:30:15.123456", 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query + "&limit=2")
+			p, err := ParseFilePage(v)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			n, cursor := p.Next(len(rows), keyOf)
+			if n != 2 || cursor == "" {
+				t.Fatalf("Next = %d %q, want 2 rows and a cursor", n, cursor)
+			}
+
+			v.Set("cursor", cursor)
+			next, err := ParseFilePage(v)
+			if err != nil {
+				t.Fatalf("cursor %q rejected: %v", cursor, err)
+			}
+			if next.First() {
+				t.Errorf("page with a cursor reported as the first one")
+			}
+			sql, args := next.Where([]interface{}{"x"})
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestFilePageLastPage(t *testing.T) {
+	p, _ := ParseFilePage(url.Values{"limit": {"2"}})
+	keyOf := func(i int) FileKey { return FileKey{ID: i + 1} }
+	for _, n := range []int{0, 1, 2} {
+		if got, cursor := p.Next(n, keyOf); got != n || cursor != "" {
+			t.Errorf("Next(%d) = %d %q, want every row and no cursor", n, got, cursor)
+		}
+	}
+}
+
+func TestFilePageTieBreak(t *testing.T) {
+	// two files with the same size : the cursor still tells them apart by id :
+	rows := []FileKey{{ID: 12, Size: 500}, {ID: 11, Size: 500}, {ID: 10, Size: 500}}
+	p, _ := ParseFilePage(url.Values{"sort": {"size"}, "limit": {"1"}})
+	_, first := p.Next(len(rows), func(i int) FileKey { return rows[i] })
+	_, second := p.Next(len(rows)-1, func(i int) FileKey { return rows[i+1] })
+	if first == second {
+		t.Fatalf("rows with equal sizes got the same cursor %q", first)
+	}
+
+	p2, err := ParseFilePage(url.Values{"sort": {"size"}, "limit": {"1"}, "cursor": {first}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sql, args := p2.Where(nil)
+	if want := " AND (f.size, f.id) < ($1, $2)"; sql != want {
+		t.Errorf("sql = %q, want the row comparison %q", sql, want)
+	}
+	if want := []interface{}{int64(500), 12}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %#v, want %#v", args, want)
+	}
+	if want := " ORDER BY f.size DESC, f.id DESC LIMIT 2"; p2.OrderLimit() != want {
+		t.Errorf("OrderLimit = %q, want %q", p2.OrderLimit(), want)
+	}
+}
+
+func TestParseFilePageBadCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		cursor string
+	}{
+		{name: "not base64", query: "sort=size", cursor: "%%%"},
+		{name: "not JSON", query: "sort=size", cursor: encodeCursor("size:500")},
+		{name: "sort changed", query: "sort=name", cursor: encodeCursor(`{"s":"size","o":"desc","v":"500","id":3}`)},
+		{name: "order changed", query: "sort=size&order=asc", cursor: encodeCursor(`{"s":"size","o":"desc","v":"500","id":3}`)},
+		{name: "missing id", query: "sort=size", cursor: encodeCursor(`{"s":"size","o":"desc","v":"500"}`)},
+		{name: "negative id", query: "sort=size", cursor: encodeCursor(`{"s":"size","o":"desc","v":"500","id":-1}`)},
+		{name: "size not a number", query: "sort=size", cursor: encodeCursor(`{"s":"size","o":"desc","v":"1 OR 1=1","id":3}`)},
+		{name: "downloads not a number", query: "sort=downloads", cursor: encodeCursor(`{"s":"downloads","o":"desc","v":"","id":3}`)},
+		{name: "bad date", query: "sort=date", cursor: encodeCursor(`{"s":"date","o":"desc","v":"2025-13-01 00:00:00","id":3}`)},
+		{name: "RFC 3339 date", query: "sort=date", cursor: encodeCursor(`{"s":"date","o":"desc","v":"2025-09-01T10:00:00Z","id":3}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			v.Set("cursor", tt.cursor)
+			if _, err := ParseFilePage(v); err != ErrInvalidCursor {
+				t.Errorf("error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/utils"
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords holds the most frequent leaked passwords & dictionary words,
+// any password built mostly from these is scored as very weak :
+var commonPasswords = map[string]bool{}
+
+// commonWords are the dictionary words stripped from longer pswds, longest first (then
+// alphabetical) so the same pswd always loses the same words and gets the same score :
+var commonWords []string
+
+func init() {
+	for _, w := range strings.Fields(`
+		password passw0rd qwerty qwertyuiop asdfgh asdfghjkl zxcvbn zxcvbnm letmein welcome
+		admin administrator root login master secret monkey dragon football baseball
+		soccer hockey batman superman iloveyou princess sunshine shadow ashley michael
+		jennifer jordan hunter ranger buster thomas tigger charlie robert daniel
+		starwars whatever freedom trustno1 access hello flower summer winter spring
+		autumn changeme default guest abc123 abcdef 123456 1234567 12345678 123456789
+		1234567890 111111 000000 123123 654321 666666 121212 987654321 filevault vault
+		file files upload secure security computer internet google apple samsung
+		cookie chocolate pepper ginger orange banana purple silver golden diamond
+	`) {
+		commonPasswords[w] = true
+		if len(w) >= 4 {
+			commonWords = append(commonWords, w)
+		}
+	}
+	sort.Slice(commonWords, func(i, j int) bool {
+		if len(commonWords[i]) != len(commonWords[j]) {
+			return len(commonWords[i]) > len(commonWords[j])
+		}
+		return commonWords[i] < commonWords[j]
+	})
+}
+
+// leetReplacer undoes the usual character substitutions before dictionary checks :
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i",
+)
+
+// PasswordStrength gives a zxcvbn-style score from 0 (too guessable) to 4 (very strong).
+// userInputs (username, email, ...) are treated as known words for an attacker.
+func PasswordStrength(password string, userInputs ...string) int {
+	g := estimateGuessesLog10(password, userInputs)
+	switch {
+	case g < 3:
+		return 0
+	case g < 6:
+		return 1
+	case g < 8:
+		return 2
+	case g < 10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// estimateGuessesLog10 approximates log10(number of guesses) needed to crack the pswd :
+func estimateGuessesLog10(password string, userInputs []string) float64 {
+	lower := strings.ToLower(password)
+	unleeted := leetReplacer.Replace(lower)
+	if commonPasswords[lower] || commonPasswords[unleeted] {
+		return 0
+	}
+
+	// stripping known words, each costing only a dictionary lookup :
+	var extra float64
+	rest := unleeted
+	for _, in := range userInputs {
+		in = strings.ToLower(in)
+		if at := strings.Index(in, "@"); at > 0 {
+			in = in[:at]
+		}
+		if len(in) >= 3 && strings.Contains(rest, in) {
+			rest = strings.Replace(rest, in, "", 1)
+			extra += 1
+		}
+	}
+	for _, w := range commonWords {
+		if strings.Contains(rest, w) {
+			rest = strings.Replace(rest, w, "", 1)
+			extra += math.Log10(float64(len(commonPasswords)))
+		}
+	}
+
+	// charset size from the classes used in the original pswd :
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, c := range password {
+		switch {
+		case c > unicode.MaxASCII:
+			hasOther = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	charset := 0
+	if hasLower {
+		charset += 26
+	}
+	if hasUpper {
+		charset += 26
+	}
+	if hasDigit {
+		charset += 10
+	}
+	if hasSymbol {
+		charset += 33
+	}
+	if hasOther {
+		charset += 100
+	}
+	if charset == 0 {
+		return extra
+	}
+
+	// repeats & sequences ("aaaa", "abcd", "4321") add almost nothing :
+	var bits float64
+	var prev rune
+	for i, c := range []rune(rest) {
+		if i > 0 && (c == prev || c == prev+1 || c == prev-1) {
+			bits += 1
+		} else {
+			bits += math.Log2(float64(charset))
+		}
+		prev = c
+	}
+
+	return bits*math.Log10(2) + extra
+}
+
+// IsBreachedPassword checks the pswd against a local breached-password corpus.
+// The corpus uses the k-anonymity range layout: BREACHED_PASSWORDS_DIR/<first 5
+// SHA-1 hex chars>.txt holding "SUFFIX:COUNT" lines, so only one small bucket is
+// ever read and the full hash never leaves this function.
+func IsBreachedPassword(password string) (bool, error) {
+	dir := config.AppConfig.BreachedPasswordsDir
+	if dir == "" {
+		return false, nil
+	}
+
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	f, err := os.Open(filepath.Join(dir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if colon := strings.IndexByte(line, ':'); colon >= 0 {
+			line = line[:colon]
+		}
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// ValidatePassword applies the configured policy and returns field errors for "password" :
+func ValidatePassword(password string, username string, email string) []utils.FieldError {
+	var errs []utils.FieldError
+	cfg := config.AppConfig
+
+	if len([]rune(password)) < cfg.PasswordMinLength {
+		errs = append(errs, utils.FieldError{
+			Field:   "password",
+			Message: fmt.Sprintf("password must be at least %d characters", cfg.PasswordMinLength),
+		})
+	}
+	if len(password) > 72 {
+		// bcrypt ignores everything after 72 bytes
+		errs = append(errs, utils.FieldError{Field: "password", Message: "password must be at most 72 bytes"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if PasswordStrength(password, username, email) < cfg.PasswordMinScore {
+		errs = append(errs, utils.FieldError{
+			Field:   "password",
+			Message: "password is too easy to guess, try a longer phrase or mix in more unusual words",
+		})
+	}
+
+	breached, err := IsBreachedPassword(password)
+	if err != nil {
+		// corpus problems shouldn't block signups, the other checks still apply
+		log.Printf("⚠️ breached password check failed: %v", err)
+	} else if breached {
+		errs = append(errs, utils.FieldError{
+			Field:   "password",
+			Message: "password has appeared in a data breach, please choose another",
+		})
+	}
+	return errs
+}
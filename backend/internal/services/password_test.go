@@ -0,0 +1,47 @@
+package services
+
+import "testing"
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{name: "empty", password: "", want: 0},
+		{name: "common password", password: "password", want: 0},
+		{name: "leet common password", password: "P@ssw0rd", want: 0},
+		{name: "username", password: "bobsmith", userInputs: []string{"bobsmith", "bob@example.com"}, want: 0},
+		{name: "repeats", password: "aaaaaaaaaaaa", want: 1},
+		{name: "sequence", password: "abcdefghijkl", want: 1},
+		{name: "two dictionary words", password: "summerwinter", want: 1},
+		{name: "keyboard rows", password: "qwertyuiopasdfghjkl", want: 1},
+		{name: "overlapping words", password: "passwordqwertyuiop", want: 1},
+		{name: "words sharing a prefix", password: "vaultfilevaultfiles", want: 1},
+		{name: "word inside a longer word", password: "securityfiles", want: 1},
+		{name: "repeated username", password: "alicealice", userInputs: []string{"alice"}, want: 3},
+		{name: "random characters", password: "x7#Kp9!qLm2$", want: 4},
+		{name: "passphrase", password: "correct horse battery staple", want: 4},
+		{name: "mixed passphrase", password: "Zebra-Quartz-Lantern-81", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PasswordStrength(tt.password, tt.userInputs...); got != tt.want {
+				t.Errorf("PasswordStrength(%q) = %d, want %d", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordStrengthDeterministic(t *testing.T) {
+	// words overlapping each other used to be stripped in map order, changing the score run to run :
+	for _, pswd := range []string{"vaultfilevaultfiles", "passwordqwertyuiop", "securityfiles", "filesecurevault", "qwertyuiopasdfghjkl"} {
+		first := estimateGuessesLog10(pswd, nil)
+		for i := 0; i < 200; i++ {
+			if got := estimateGuessesLog10(pswd, nil); got != first {
+				t.Fatalf("estimateGuessesLog10(%q) = %v then %v", pswd, first, got)
+			}
+		}
+	}
+}
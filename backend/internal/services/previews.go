@@ -0,0 +1,231 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// preview statuses (file_previews.status), same lifecycle as file_contents :
+const (
+	PreviewPending = "pending"
+	PreviewDone    = "done"
+	PreviewSkipped = "skipped" // type without a preview
+	PreviewFailed  = "failed"  // gave up after maxPreviewAttempts
+)
+
+const maxPreviewAttempts = 3
+
+// previews are stored under PREVIEW_DIR by content hash, which must look like one :
+var previewHash = regexp.MustCompile(`^[0-9a-fA-F]{16,128}$`)
+
+// wakes the generator when a new preview is queued :
+var previewWake = make(chan struct{}, 1)
+
+// FilePreview is the stored preview of a content hash :
+type FilePreview struct {
+	Hash      string
+	Status    string
+	Kind      string
+	MimeType  string
+	Path      string
+	Width     int
+	Height    int
+	UpdatedAt time.Time
+}
+
+// QueuePreview schedules the preview of a new upload (once per content hash, copies reuse it) :
+func QueuePreview(hash string) {
+	if _, err := db.DB.Exec(`INSERT INTO file_previews (hash) VALUES ($1) ON CONFLICT (hash) DO NOTHING`, hash); err != nil {
+		log.Printf("❌ queueing preview failed: %v", err)
+	}
+	select {
+	case previewWake <- struct{}{}:
+	default:
+	}
+}
+
+// GetFilePreview loads the preview row of a content hash, nil if none was queued :
+func GetFilePreview(hash string) (*FilePreview, error) {
+	var p FilePreview
+	var kind, mimeType, path sql.NullString
+	var width, height sql.NullInt64
+	err := db.DB.QueryRow(`
+		SELECT hash, status, kind, mime_type, path, width, height, updated_at
+		FROM file_previews WHERE hash = $1`, hash,
+	).Scan(&p.Hash, &p.Status, &kind, &mimeType, &path, &width, &height, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Kind, p.MimeType, p.Path = kind.String, mimeType.String, path.String
+	p.Width, p.Height = int(width.Int64), int(height.Int64)
+	return &p, nil
+}
+
+// StartPreviewGenerator runs the background thumbnail generator : pending previews are rendered as
+// soon as they are queued, and at least every interval (which also drops previews no file uses anymore).
+func StartPreviewGenerator(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	if err := os.MkdirAll(config.AppConfig.PreviewDir, 0o755); err != nil {
+		log.Printf("❌ preview dir unavailable, thumbnails disabled: %v", err)
+		return
+	}
+	go func() {
+		for {
+			for {
+				more, err := generateNextPreview()
+				if err != nil {
+					log.Printf("❌ preview generation failed: %v", err)
+					break
+				}
+				if !more {
+					break
+				}
+			}
+			select {
+			case <-previewWake:
+			case <-time.After(interval):
+				removeOrphanedPreviews()
+			}
+		}
+	}()
+}
+
+// generateNextPreview renders the oldest pending preview, reporting whether one was found :
+func generateNextPreview() (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var hash string
+	var attempts int
+	err = tx.QueryRow(`
+		SELECT hash, attempts FROM file_previews
+		WHERE status = $1
+		ORDER BY updated_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, PreviewPending,
+	).Scan(&hash, &attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// any copy will do, they share the blob :
+	var filename, path, mimeType string
+	err = tx.QueryRow(`
+		SELECT filename, filepath, COALESCE(mime_type, '') FROM files
+		WHERE hash = $1
+		ORDER BY is_master DESC, id
+		LIMIT 1`, hash,
+	).Scan(&filename, &path, &mimeType)
+	if err == sql.ErrNoRows {
+		if _, err := tx.Exec(`DELETE FROM file_previews WHERE hash = $1`, hash); err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	} else if err != nil {
+		return false, err
+	}
+
+	var preview *Preview
+	var stored string
+	err = ErrPreviewUnsupported
+	if previewHash.MatchString(hash) {
+		if preview, err = RenderPreview(path, filename, mimeType, config.AppConfig.ThumbnailSize); err == nil {
+			stored, err = storePreview(hash, preview)
+		}
+	}
+	status, errMsg := PreviewDone, sql.NullString{}
+	switch {
+	case err == ErrPreviewUnsupported:
+		status = PreviewSkipped
+	case err != nil:
+		attempts++
+		status = PreviewPending
+		if attempts >= maxPreviewAttempts {
+			status = PreviewFailed
+		}
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	var kind, previewMime sql.NullString
+	var width, height sql.NullInt64
+	if status == PreviewDone {
+		kind = sql.NullString{String: preview.Kind, Valid: true}
+		previewMime = sql.NullString{String: preview.MimeType, Valid: true}
+		width = sql.NullInt64{Int64: int64(preview.Width), Valid: true}
+		height = sql.NullInt64{Int64: int64(preview.Height), Valid: true}
+	}
+	if _, err := tx.Exec(`
+		UPDATE file_previews
+		SET status = $2, kind = $3, mime_type = $4, path = NULLIF($5, ''), width = $6, height = $7,
+		    error = $8, attempts = $9, updated_at = NOW()
+		WHERE hash = $1`,
+		hash, status, kind, previewMime, stored, width, height, errMsg, attempts,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// storePreview writes the rendered preview under PREVIEW_DIR/<2 first hash chars>/, returning its path :
+func storePreview(hash string, p *Preview) (string, error) {
+	ext := ".png"
+	if p.MimeType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	dir := filepath.Join(config.AppConfig.PreviewDir, hash[:2])
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, hash+ext)
+	tmp := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
+	if err := os.WriteFile(tmp, p.Data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// removeOrphanedPreviews drops the previews (rows & images) of contents no file uses anymore :
+func removeOrphanedPreviews() {
+	rows, err := db.DB.Query(`
+		DELETE FROM file_previews p
+		WHERE NOT EXISTS (SELECT 1 FROM files f WHERE f.hash = p.hash)
+		RETURNING COALESCE(path, '')`)
+	if err != nil {
+		log.Printf("❌ orphaned previews cleanup failed: %v", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			log.Printf("❌ orphaned previews cleanup failed: %v", err)
+			return
+		}
+		if path != "" {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("❌ removing preview %s failed: %v", path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrQuotaExceeded is returned when a reservation would go over the limit :
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// where a user's effective quota comes from :
+const (
+	QuotaSourceUser    = "user"
+	QuotaSourceRole    = "role"
+	QuotaSourceDefault = "default"
+)
+
+// MimeUsage is the storage used by one MIME type :
+type MimeUsage struct {
+	MimeType string `json:"mime_type"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Usage summarises a user's personal-space storage.
+// Policy: every file counts its full logical size against the owner's quota, whether
+// its bytes are stored (master) or shared with an existing copy (duplicate link).
+// Dedup only saves physical disk space; that saving is reported, not deducted.
+type Usage struct {
+	UsedBytes         int64       `json:"used_bytes"`
+	LimitBytes        int64       `json:"limit_bytes"`
+	RemainingBytes    int64       `json:"remaining_bytes"`
+	QuotaSource       string      `json:"quota_source"`
+	FileCount         int         `json:"file_count"`
+	DedupSavingsBytes int64       `json:"dedup_savings_bytes"`
+	ByMimeType        []MimeUsage `json:"by_mime_type"`
+}
+
+// GetUserQuotaLimit returns the effective quota: user override → role default → USER_QUOTA_MB :
+func GetUserQuotaLimit(userID int) (int64, string, error) {
+	var userQuota, roleQuota sql.NullInt64
+	err := db.DB.QueryRow(`
+		SELECT u.quota_bytes, r.default_quota_bytes
+		FROM users u
+		LEFT JOIN roles r ON r.name = u.role
+		WHERE u.id = $1`, userID,
+	).Scan(&userQuota, &roleQuota)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if userQuota.Valid {
+		return userQuota.Int64, QuotaSourceUser, nil
+	}
+	if roleQuota.Valid {
+		return roleQuota.Int64, QuotaSourceRole, nil
+	}
+	return utils.GetUserQuotaBytes(), QuotaSourceDefault, nil
+}
+
+// GetUserUsage returns used/limit/dedup savings and a per-MIME breakdown of the personal space :
+func GetUserUsage(userID int) (*Usage, error) {
+	limit, source, err := GetUserQuotaLimit(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT COALESCE(mime_type, 'unknown'), COUNT(*), COALESCE(SUM(size), 0),
+		       COALESCE(SUM(size) FILTER (WHERE NOT is_master), 0)
+		FROM files
+		WHERE user_id = $1 AND team_id IS NULL
+		GROUP BY 1
+		ORDER BY 3 DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	u := &Usage{LimitBytes: limit, QuotaSource: source, ByMimeType: make([]MimeUsage, 0)}
+	for rows.Next() {
+		var m MimeUsage
+		var linked int64
+		if err := rows.Scan(&m.MimeType, &m.Files, &m.Bytes, &linked); err != nil {
+			return nil, err
+		}
+		u.ByMimeType = append(u.ByMimeType, m)
+		u.UsedBytes += m.Bytes
+		u.FileCount += m.Files
+		u.DedupSavingsBytes += linked
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	u.RemainingBytes = u.LimitBytes - u.UsedBytes
+	if u.RemainingBytes < 0 {
+		u.RemainingBytes = 0
+	}
+	return u, nil
+}
+
+// SetUserQuota overrides a user's quota; nil falls back to the role default :
+func SetUserQuota(userID int, quotaBytes *int64) (bool, error) {
+	res, err := db.DB.Exec(`UPDATE users SET quota_bytes=$1 WHERE id=$2`, quotaBytes, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// SetRoleDefaultQuota sets the default quota for users of a role; nil falls back to USER_QUOTA_MB :
+func SetRoleDefaultQuota(role string, quotaBytes *int64) (bool, error) {
+	res, err := db.DB.Exec(`UPDATE roles SET default_quota_bytes=$1 WHERE name=$2`, quotaBytes, role)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ReserveQuota adds size to the owner's used_bytes counter inside tx, but only if the
+// result stays within the limit. The conditional UPDATE takes a row lock, so parallel
+// uploads for the same user/team are checked one after another and can't overshoot.
+// Team files (teamID != nil) are charged to the team instead of the user.
+func ReserveQuota(tx *sql.Tx, userID int, teamID *int, size int64) (used int64, limit int64, err error) {
+	var query, fallback string
+	var ownerID int
+	if teamID != nil {
+		ownerID = *teamID
+		limit, _, err = GetTeamQuota(ownerID)
+		query = `UPDATE teams SET used_bytes = used_bytes + $2 WHERE id=$1 AND used_bytes + $2 <= $3 RETURNING used_bytes`
+		fallback = `SELECT used_bytes FROM teams WHERE id=$1`
+	} else {
+		ownerID = userID
+		limit, _, err = GetUserQuotaLimit(ownerID)
+		query = `UPDATE users SET used_bytes = used_bytes + $2 WHERE id=$1 AND used_bytes + $2 <= $3 RETURNING used_bytes`
+		fallback = `SELECT used_bytes FROM users WHERE id=$1`
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = tx.QueryRow(query, ownerID, size, limit).Scan(&used)
+	if err == sql.ErrNoRows {
+		// over the limit, reporting what the total would have been :
+		if err := tx.QueryRow(fallback, ownerID).Scan(&used); err != nil {
+			return 0, limit, err
+		}
+		return used + size, limit, ErrQuotaExceeded
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return used, limit, nil
+}
+
+// ReleaseQuota subtracts size from the owner's used_bytes counter inside tx :
+func ReleaseQuota(tx *sql.Tx, userID int, teamID *int, size int64) error {
+	var err error
+	if teamID != nil {
+		_, err = tx.Exec(`UPDATE teams SET used_bytes = GREATEST(used_bytes - $2, 0) WHERE id=$1`, *teamID, size)
+	} else {
+		_, err = tx.Exec(`UPDATE users SET used_bytes = GREATEST(used_bytes - $2, 0) WHERE id=$1`, userID, size)
+	}
+	return err
+}
+
+// GetRemainingQuota returns limit - used from the counters, for the personal or team space :
+func GetRemainingQuota(userID int, teamID *int) (remaining int64, limit int64, err error) {
+	var used int64
+	if teamID != nil {
+		limit, _, err = GetTeamQuota(*teamID)
+		if err == nil {
+			err = db.DB.QueryRow(`SELECT used_bytes FROM teams WHERE id=$1`, *teamID).Scan(&used)
+		}
+	} else {
+		limit, _, err = GetUserQuotaLimit(userID)
+		if err == nil {
+			err = db.DB.QueryRow(`SELECT used_bytes FROM users WHERE id=$1`, userID).Scan(&used)
+		}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	remaining = limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, limit, nil
+}
+
+// what a counter must equal, per owner :
+const (
+	userUsageQuery = `SELECT COALESCE(SUM(size), 0) FROM files WHERE user_id = $1 AND team_id IS NULL`
+	teamUsageQuery = `SELECT COALESCE(SUM(size), 0) FROM files WHERE team_id = $1`
+)
+
+// RecalculateUserUsage resets one user's counter from the files table :
+func RecalculateUserUsage(userID int) error {
+	_, err := reconcileCounter("users", userUsageQuery, userID)
+	return err
+}
+
+// ReconcileUsage fixes every counter that drifted from the files table,
+// returning how many users and teams were corrected.
+func ReconcileUsage() (int, int, error) {
+	users, err := reconcileCounters("users", userUsageQuery)
+	if err != nil {
+		return users, 0, err
+	}
+	teams, err := reconcileCounters("teams", teamUsageQuery)
+	return users, teams, err
+}
+
+// reconcileCounters reconciles the counters of a table ("users" / "teams") one owner at a time :
+func reconcileCounters(table string, usageQuery string) (int, error) {
+	rows, err := db.DB.Query(`SELECT id FROM ` + table + ` ORDER BY id`)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	fixed := 0
+	for _, id := range ids {
+		changed, err := reconcileCounter(table, usageQuery, id)
+		if err != nil {
+			return fixed, err
+		}
+		if changed {
+			fixed++
+		}
+	}
+	return fixed, nil
+}
+
+// reconcileCounter resets one owner's counter to the sum of their files. The owner row is locked
+// first : that waits for uploads & deletions that reserved on it to commit (ReserveQuota holds the same
+// lock), so the sum sees their files and no reservation lands between the sum and the update.
+func reconcileCounter(table string, usageQuery string, id int) (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var used int64
+	err = tx.QueryRow(`SELECT used_bytes FROM `+table+` WHERE id = $1 FOR UPDATE`, id).Scan(&used)
+	if err == sql.ErrNoRows {
+		return false, nil // deleted meanwhile
+	}
+	if err != nil {
+		return false, err
+	}
+	var total int64
+	if err := tx.QueryRow(usageQuery, id).Scan(&total); err != nil {
+		return false, err
+	}
+	if total == used {
+		return false, nil
+	}
+	if _, err := tx.Exec(`UPDATE `+table+` SET used_bytes = $2 WHERE id = $1`, id, total); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// StartQuotaReconciler runs ReconcileUsage every interval in the background :
+func StartQuotaReconciler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(interval)
+			users, teams, err := ReconcileUsage()
+			if err != nil {
+				log.Printf("❌ quota reconcile failed: %v", err)
+				continue
+			}
+			if users > 0 || teams > 0 {
+				log.Printf("⚠️ quota reconcile corrected %d user and %d team counters", users, teams)
+			}
+		}
+	}()
+}
+
+// CheckQuotaWarnings notifies the owner of a space once per crossed threshold
+// (QUOTA_WARN_THRESHOLDS). The UPDATE only succeeds when the stored level is
+// lower, so concurrent uploads can't send the same warning twice. When usage
+// falls back under a threshold the level is lowered so it can fire again later.
+// Team warnings go to the team's owners and admins.
+func CheckQuotaWarnings(userID int, teamID *int) {
+	thresholds := config.AppConfig.QuotaWarnThresholds
+	if len(thresholds) == 0 {
+		return
+	}
+
+	remaining, limit, err := GetRemainingQuota(userID, teamID)
+	if err != nil || limit <= 0 {
+		return
+	}
+	pct := int((limit - remaining) * 100 / limit)
+
+	level := 0
+	for _, t := range thresholds {
+		if pct >= t {
+			level = t
+		}
+	}
+
+	table, ownerID := "users", userID
+	if teamID != nil {
+		table, ownerID = "teams", *teamID
+	}
+
+	// usage dropped : lowering the stored level without notifying :
+	if _, err := db.DB.Exec(`UPDATE `+table+` SET quota_warned_pct=$2 WHERE id=$1 AND quota_warned_pct > $2`, ownerID, level); err != nil {
+		log.Printf("❌ quota warning reset failed: %v", err)
+		return
+	}
+	if level == 0 {
+		return
+	}
+
+	res, err := db.DB.Exec(`UPDATE `+table+` SET quota_warned_pct=$2 WHERE id=$1 AND quota_warned_pct < $2`, ownerID, level)
+	if err != nil {
+		log.Printf("❌ quota warning update failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+
+	recipients := []int{userID}
+	title := fmt.Sprintf("You have used %d%% of your storage", pct)
+	if teamID != nil {
+		var name string
+		if err := db.DB.QueryRow(`SELECT name FROM teams WHERE id=$1`, *teamID).Scan(&name); err != nil {
+			log.Printf("❌ quota warning team lookup failed: %v", err)
+			return
+		}
+		title = fmt.Sprintf("Team %s has used %d%% of its storage", name, pct)
+		recipients, err = teamManagerIDs(*teamID)
+		if err != nil {
+			log.Printf("❌ quota warning recipients lookup failed: %v", err)
+			return
+		}
+	}
+	body := fmt.Sprintf("%s of %s used, %s remaining.", formatBytes(limit-remaining), formatBytes(limit), formatBytes(remaining))
+
+	for _, id := range recipients {
+		if err := Notify(id, NotifyQuotaWarning, title, body, config.AppConfig.QuotaWarnEmail); err != nil {
+			log.Printf("❌ quota warning notification failed: %v", err)
+		}
+	}
+}
+
+// teamManagerIDs returns the user ids of a team's owners and admins :
+func teamManagerIDs(teamID int) ([]int, error) {
+	rows, err := db.DB.Query(
+		`SELECT user_id FROM team_members WHERE team_id=$1 AND role IN ($2, $3)`,
+		teamID, TeamRoleOwner, TeamRoleAdmin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// formatBytes renders a byte count as a short human readable string :
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
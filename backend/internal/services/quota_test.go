@@ -0,0 +1,187 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDB connects to the migrated database in TEST_DB_URL, skipping the test without one :
+func testDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set, skipping database test")
+	}
+	conn, err := sql.Open("postgres", url)
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		t.Skipf("test database unavailable: %v", err)
+	}
+	conn.SetMaxOpenConns(20)
+	prev := db.DB
+	db.DB = conn
+	t.Cleanup(func() {
+		conn.Close()
+		db.DB = prev
+	})
+}
+
+// testQuotaUser creates a user with a quota override, removed (with their files) after the test :
+func testQuotaUser(t *testing.T, quota int64) int {
+	t.Helper()
+	name := fmt.Sprintf("quota_test_%d", time.Now().UnixNano())
+	var id int
+	err := db.DB.QueryRow(`
+		INSERT INTO users (username, email, password, quota_bytes)
+		VALUES ($1, $1 || '@example.com', 'x', $2) RETURNING id`, name, quota,
+	).Scan(&id)
+	if err != nil {
+		t.Fatalf("creating test user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.DB.Exec(`DELETE FROM files WHERE user_id = $1`, id)
+		db.DB.Exec(`DELETE FROM users WHERE id = $1`, id)
+	})
+	return id
+}
+
+// uploadInTx does what UploadHandler does : reserve, insert the row, commit together :
+func uploadInTx(userID int, size int64, n int) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, err := ReserveQuota(tx, userID, nil, size); err != nil {
+		return err
+	}
+	// a slow upload keeps its reservation open while the reconciler runs :
+	time.Sleep(time.Millisecond)
+	_, err = tx.Exec(`
+		INSERT INTO files (user_id, filename, filepath, hash, size)
+		VALUES ($1, $2, $2, $2, $3)`, userID, fmt.Sprintf("quota-test-%d-%d", userID, n), size)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func usedAndStored(t *testing.T, userID int) (int64, int64) {
+	t.Helper()
+	var used, stored int64
+	err := db.DB.QueryRow(`
+		SELECT used_bytes, (SELECT COALESCE(SUM(size), 0) FROM files WHERE user_id = $1 AND team_id IS NULL)
+		FROM users WHERE id = $1`, userID,
+	).Scan(&used, &stored)
+	if err != nil {
+		t.Fatalf("reading usage: %v", err)
+	}
+	return used, stored
+}
+
+func TestConcurrentUploadsNearQuotaLimit(t *testing.T) {
+	testDB(t)
+
+	tests := []struct {
+		name      string
+		quota     int64
+		size      int64
+		uploads   int
+		reconcile bool
+	}{
+		{name: "exact fit", quota: 6000, size: 1000, uploads: 20},
+		{name: "remainder left", quota: 6500, size: 1000, uploads: 20},
+		{name: "single slot", quota: 1000, size: 1000, uploads: 10},
+		{name: "with reconciler running", quota: 6000, size: 1000, uploads: 20, reconcile: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := testQuotaUser(t, tt.quota)
+
+			var accepted, refused atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < tt.uploads; i++ {
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					switch err := uploadInTx(userID, tt.size, n); err {
+					case nil:
+						accepted.Add(1)
+					case ErrQuotaExceeded:
+						refused.Add(1)
+					default:
+						t.Errorf("upload %d: %v", n, err)
+					}
+				}(i)
+				if tt.reconcile && i%4 == 0 {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						if _, err := reconcileCounter("users", userUsageQuery, userID); err != nil {
+							t.Errorf("reconcile: %v", err)
+						}
+					}()
+				}
+			}
+			wg.Wait()
+
+			want := tt.quota / tt.size
+			if accepted.Load() != want || refused.Load() != int64(tt.uploads)-want {
+				t.Errorf("accepted %d, refused %d; want %d accepted", accepted.Load(), refused.Load(), want)
+			}
+			used, stored := usedAndStored(t, userID)
+			if used != stored {
+				t.Errorf("used_bytes = %d, files sum to %d", used, stored)
+			}
+			if used > tt.quota {
+				t.Errorf("used_bytes = %d, over the %d quota", used, tt.quota)
+			}
+		})
+	}
+}
+
+func TestReconcileCounterFixesDrift(t *testing.T) {
+	testDB(t)
+	userID := testQuotaUser(t, 10000)
+	for i := 0; i < 3; i++ {
+		if err := uploadInTx(userID, 1000, i); err != nil {
+			t.Fatalf("upload: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		drift   int64
+		changed bool
+	}{
+		{name: "in sync", drift: 3000, changed: false},
+		{name: "too high", drift: 9000, changed: true},
+		{name: "too low", drift: 0, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := db.DB.Exec(`UPDATE users SET used_bytes = $2 WHERE id = $1`, userID, tt.drift); err != nil {
+				t.Fatalf("setting drift: %v", err)
+			}
+			changed, err := reconcileCounter("users", userUsageQuery, userID)
+			if err != nil {
+				t.Fatalf("reconcile: %v", err)
+			}
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+			if used, stored := usedAndStored(t, userID); used != 3000 || stored != 3000 {
+				t.Errorf("used_bytes = %d, files = %d, want 3000", used, stored)
+			}
+		})
+	}
+}
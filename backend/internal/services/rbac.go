@@ -0,0 +1,313 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"errors"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// built-in permission names (rows in the permissions table) :
+const (
+	PermFilesReadAny   = "files.read.any"
+	PermFilesManageAny = "files.manage.any"
+	PermUsersRead      = "users.read"
+	PermUsersManage    = "users.manage"
+	PermRolesManage    = "roles.manage"
+	PermQuotaManage    = "quota.manage"
+	PermAuditRead      = "audit.read"
+	PermSecurityManage = "security.manage"
+	PermImpersonate    = "users.impersonate"
+)
+
+// Role is a named set of permissions :
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	IsSystem    bool     `json:"is_system"`
+	Permissions []string `json:"permissions"`
+	UserCount   int      `json:"user_count"`
+
+	DefaultQuotaBytes *int64 `json:"default_quota_bytes"`
+}
+
+// Permission describes one grantable permission :
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrSystemRole        = errors.New("built-in roles cannot be deleted")
+	ErrInvalidRoleName   = errors.New("role name must be 2-20 chars: lowercase letters, digits, '_' or '-'")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrRoleEscalation    = errors.New("cannot grant or edit permissions you don't have")
+	ErrBuiltinAdminRole  = errors.New("the built-in admin role cannot be edited")
+	ErrNoRoleManager     = errors.New("at least one role must keep " + PermRolesManage)
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,19}$`)
+
+// role → permission cache, refreshed every permCacheTTL or on role changes :
+var (
+	permCache    map[string]map[string]bool
+	permLoadedAt time.Time
+	permMu       sync.Mutex
+	permCacheTTL = 30 * time.Second
+)
+
+// loadPermissions (re)reads role_permissions into the cache, caller holds permMu :
+func loadPermissions() error {
+	rows, err := db.DB.Query(`SELECT role, permission FROM role_permissions`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fresh := make(map[string]map[string]bool)
+	for rows.Next() {
+		var role, perm string
+		if err := rows.Scan(&role, &perm); err != nil {
+			return err
+		}
+		if fresh[role] == nil {
+			fresh[role] = make(map[string]bool)
+		}
+		fresh[role][perm] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	permCache = fresh
+	permLoadedAt = time.Now()
+	return nil
+}
+
+// InvalidatePermissionCache forces a reload on the next permission check :
+func InvalidatePermissionCache() {
+	permMu.Lock()
+	permLoadedAt = time.Time{}
+	permMu.Unlock()
+}
+
+// PermissionsForRole returns the permission set of a role :
+func PermissionsForRole(role string) (map[string]bool, error) {
+	permMu.Lock()
+	defer permMu.Unlock()
+	if permCache == nil || time.Since(permLoadedAt) > permCacheTTL {
+		if err := loadPermissions(); err != nil {
+			return nil, err
+		}
+	}
+	return permCache[role], nil
+}
+
+// HasPermission reports whether the role grants perm (false on lookup errors) :
+func HasPermission(role string, perm string) bool {
+	perms, err := PermissionsForRole(role)
+	if err != nil {
+		log.Printf("❌ permission lookup failed: %v", err)
+		return false
+	}
+	return perms[perm]
+}
+
+// RoleCovers reports whether `role` holds every permission granted by `target`,
+// used so nobody can hand out more access than they have themselves.
+func RoleCovers(role string, target string) (bool, error) {
+	mine, err := PermissionsForRole(role)
+	if err != nil {
+		return false, err
+	}
+	theirs, err := PermissionsForRole(target)
+	if err != nil {
+		return false, err
+	}
+	for p := range theirs {
+		if !mine[p] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// ListPermissions returns all grantable permissions :
+func ListPermissions() ([]Permission, error) {
+	rows, err := db.DB.Query(`SELECT name, COALESCE(description, '') FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := make([]Permission, 0)
+	for rows.Next() {
+		var p Permission
+		if err := rows.Scan(&p.Name, &p.Description); err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, rows.Err()
+}
+
+// ListRoles returns every role with its permissions and number of users :
+func ListRoles() ([]Role, error) {
+	rows, err := db.DB.Query(`
+		SELECT r.name, COALESCE(r.description, ''), r.is_system,
+		       COALESCE(ARRAY(SELECT permission FROM role_permissions rp WHERE rp.role = r.name ORDER BY permission), '{}'),
+		       (SELECT COUNT(*) FROM users u WHERE u.role = r.name),
+		       r.default_quota_bytes
+		FROM roles r
+		ORDER BY r.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := make([]Role, 0)
+	for rows.Next() {
+		var ro Role
+		var perms pq.StringArray
+		if err := rows.Scan(&ro.Name, &ro.Description, &ro.IsSystem, &perms, &ro.UserCount, &ro.DefaultQuotaBytes); err != nil {
+			return nil, err
+		}
+		ro.Permissions = []string(perms)
+		roles = append(roles, ro)
+	}
+	return roles, rows.Err()
+}
+
+// SaveRole creates the role or replaces its description & permission set, on behalf of a caller
+// holding callerRole. Nobody can hand out more access than they have : the caller must hold every
+// permission of the new set and of the role as it was. The built-in admin role is never edited, and
+// some role always keeps roles.manage.
+func SaveRole(callerRole string, name string, description string, perms []string) error {
+	if !roleNamePattern.MatchString(name) {
+		return ErrInvalidRoleName
+	}
+	if name == AdminRoleName {
+		return ErrBuiltinAdminRole
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// role changes go one after another, checked against the stored permissions (not the cache) :
+	if _, err := tx.Exec(`SELECT 1 FROM roles ORDER BY name FOR UPDATE`); err != nil {
+		return err
+	}
+	mine, err := rolePermissionsTx(tx, callerRole)
+	if err != nil {
+		return err
+	}
+	current, err := rolePermissionsTx(tx, name)
+	if err != nil {
+		return err
+	}
+	for _, p := range perms {
+		if !mine[p] {
+			return ErrRoleEscalation
+		}
+	}
+	// nor take away (or rewrite) a role that has more than the caller :
+	for p := range current {
+		if !mine[p] {
+			return ErrRoleEscalation
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO roles (name, description) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description`,
+		name, description,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role=$1`, name); err != nil {
+		return err
+	}
+	for _, p := range perms {
+		_, err := tx.Exec(`INSERT INTO role_permissions (role, permission) VALUES ($1, $2) ON CONFLICT DO NOTHING`, name, p)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrUnknownPermission
+		} else if err != nil {
+			return err
+		}
+	}
+
+	var managed bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM role_permissions WHERE permission=$1)`, PermRolesManage).Scan(&managed); err != nil {
+		return err
+	}
+	if !managed {
+		return ErrNoRoleManager
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	InvalidatePermissionCache()
+	return nil
+}
+
+// rolePermissionsTx reads a role's stored permission set inside tx :
+func rolePermissionsTx(tx *sql.Tx, role string) (map[string]bool, error) {
+	rows, err := tx.Query(`SELECT permission FROM role_permissions WHERE role=$1`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perms := make(map[string]bool)
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		perms[p] = true
+	}
+	return perms, rows.Err()
+}
+
+// DeleteRole removes a custom role that no user holds anymore :
+func DeleteRole(name string) error {
+	var isSystem bool
+	err := db.DB.QueryRow(`SELECT is_system FROM roles WHERE name=$1`, name).Scan(&isSystem)
+	if err == sql.ErrNoRows {
+		return ErrRoleNotFound
+	} else if err != nil {
+		return err
+	}
+	if isSystem {
+		return ErrSystemRole
+	}
+
+	_, err = db.DB.Exec(`DELETE FROM roles WHERE name=$1`, name)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+		return ErrRoleInUse
+	} else if err != nil {
+		return err
+	}
+
+	InvalidatePermissionCache()
+	return nil
+}
+
+// RoleExists reports whether a role with that name exists :
+func RoleExists(name string) (bool, error) {
+	var exists bool
+	err := db.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM roles WHERE name=$1)`, name).Scan(&exists)
+	return exists, err
+}
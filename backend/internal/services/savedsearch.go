@@ -0,0 +1,359 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NotifySavedSearch is sent to subscribers when new files match a saved search :
+const NotifySavedSearch = "saved_search.match"
+
+var (
+	ErrSavedSearchName     = errors.New("name must be 1-100 characters")
+	ErrSavedSearchTaken    = errors.New("you already have a saved search with this name")
+	ErrSavedSearchNotFound = errors.New("saved search not found")
+)
+
+// the listing params a saved search keeps : filters & space (paging & sorting come with each request) :
+var savedSearchParams = map[string]bool{
+	"search": true, "mimeType": true, "uploader": true, "tag": true, "meta": true,
+	"minSize": true, "maxSize": true, "startDate": true, "endDate": true,
+	"space": true, "team_id": true, "folder_id": true,
+}
+
+// SavedSearch is a named filter set; shared with a team (TeamID) its members can list it as a smart
+// folder and subscribe to it. It always runs with the viewer's own access.
+type SavedSearch struct {
+	ID         int        `json:"id"`
+	OwnerID    int        `json:"owner_id"`
+	Owner      string     `json:"owner"`
+	TeamID     *int       `json:"team_id"`
+	Name       string     `json:"name"`
+	Params     url.Values `json:"params"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Subscribed bool       `json:"subscribed"`
+}
+
+// SavedSearchInput is what users send to create / update a saved search :
+type SavedSearchInput struct {
+	Name   string                 `json:"name"`
+	TeamID *int                   `json:"team_id"` // share with this team (must be a member), null = private
+	Params map[string]interface{} `json:"params"`  // listing params, values as string or array
+}
+
+// validate checks the input for ownerID and returns the normalized params :
+func (in *SavedSearchInput) validate(ownerID int) (url.Values, error) {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len([]rune(in.Name)) > 100 {
+		return nil, ErrSavedSearchName
+	}
+	params := valuesFromJSON(in.Params)
+	for key := range params {
+		if !savedSearchParams[key] {
+			return nil, fmt.Errorf("%w: %s can't be saved", ErrInvalidFilter, key)
+		}
+	}
+	if _, err := ParseFileQuery(params); err != nil {
+		return nil, err
+	}
+	if _, _, err := UserFileScope(ownerID, params, nil); err != nil {
+		return nil, err
+	}
+	if in.TeamID != nil {
+		role, err := GetTeamRole(*in.TeamID, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, ErrNotTeamMember
+		}
+	}
+	return params, nil
+}
+
+// Apply returns the request params with the saved filters & space in place of the request's own :
+func (s *SavedSearch) Apply(q url.Values) url.Values {
+	out := url.Values{}
+	for key, vals := range q {
+		if !savedSearchParams[key] && key != "saved_search" {
+			out[key] = vals
+		}
+	}
+	for key, vals := range s.Params {
+		out[key] = vals
+	}
+	return out
+}
+
+// CanUseSavedSearch : the owner, and members of the team it's shared with.
+func CanUseSavedSearch(userID int, s *SavedSearch) bool {
+	if s.OwnerID == userID {
+		return true
+	}
+	if s.TeamID == nil {
+		return false
+	}
+	role, err := GetTeamRole(*s.TeamID, userID)
+	if err != nil {
+		log.Printf("❌ team role lookup failed: %v", err)
+		return false
+	}
+	return role != ""
+}
+
+const savedSearchColumns = `s.id, s.user_id, u.username, s.team_id, s.name, s.params, s.created_at, s.updated_at`
+
+func scanSavedSearch(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*SavedSearch, error) {
+	var s SavedSearch
+	var params []byte
+	if err := row.Scan(append([]interface{}{&s.ID, &s.OwnerID, &s.Owner, &s.TeamID, &s.Name, &params,
+		&s.CreatedAt, &s.UpdatedAt}, extra...)...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(params, &s.Params); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetSavedSearch returns one saved search, (nil, nil) if not found :
+func GetSavedSearch(id int) (*SavedSearch, error) {
+	s, err := scanSavedSearch(db.DB.QueryRow(`
+		SELECT `+savedSearchColumns+`
+		FROM saved_searches s JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListSavedSearches returns the user's own saved searches and those shared with their teams :
+func ListSavedSearches(userID int) ([]SavedSearch, error) {
+	rows, err := db.DB.Query(`
+		SELECT `+savedSearchColumns+`,
+		       EXISTS (SELECT 1 FROM saved_search_subscriptions ss WHERE ss.search_id = s.id AND ss.user_id = $1)
+		FROM saved_searches s JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1
+		   OR s.team_id IN (SELECT team_id FROM team_members WHERE user_id = $1)
+		ORDER BY s.user_id <> $1, LOWER(s.name), s.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []SavedSearch{}
+	for rows.Next() {
+		var subscribed bool
+		s, err := scanSavedSearch(rows, &subscribed)
+		if err != nil {
+			return nil, err
+		}
+		s.Subscribed = subscribed
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+// CreateSavedSearch validates & stores a new saved search of ownerID :
+func CreateSavedSearch(ownerID int, in SavedSearchInput) (*SavedSearch, error) {
+	params, err := in.validate(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := json.Marshal(params)
+	var id int
+	err = db.DB.QueryRow(`
+		INSERT INTO saved_searches (user_id, team_id, name, params) VALUES ($1, $2, $3, $4)
+		RETURNING id`, ownerID, in.TeamID, in.Name, raw,
+	).Scan(&id)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrSavedSearchTaken
+	} else if err != nil {
+		return nil, err
+	}
+	return GetSavedSearch(id)
+}
+
+// UpdateSavedSearch replaces name, sharing & params of a saved search owned by ownerID.
+// Teammates who lose access through an unshare keep no subscription.
+func UpdateSavedSearch(id int, ownerID int, in SavedSearchInput) (*SavedSearch, error) {
+	params, err := in.validate(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	raw, _ := json.Marshal(params)
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE saved_searches SET name = $3, team_id = $4, params = $5, updated_at = NOW()
+		WHERE id = $1 AND user_id = $2`, id, ownerID, in.Name, in.TeamID, raw)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return nil, ErrSavedSearchTaken
+	} else if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrSavedSearchNotFound
+	}
+	if _, err := tx.Exec(`
+		DELETE FROM saved_search_subscriptions ss
+		WHERE ss.search_id = $1 AND ss.user_id <> $2
+		  AND NOT EXISTS (SELECT 1 FROM team_members tm WHERE tm.team_id = $3 AND tm.user_id = ss.user_id)`,
+		id, ownerID, in.TeamID,
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetSavedSearch(id)
+}
+
+// DeleteSavedSearch removes a saved search owned by ownerID (subscriptions go with it) :
+func DeleteSavedSearch(id int, ownerID int) (bool, error) {
+	res, err := db.DB.Exec(`DELETE FROM saved_searches WHERE id = $1 AND user_id = $2`, id, ownerID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// SubscribeSavedSearch notifies userID about files matching from now on (idempotent) :
+func SubscribeSavedSearch(id int, userID int) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO saved_search_subscriptions (search_id, user_id, seen_until)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (search_id, user_id) DO NOTHING`, id, userID)
+	return err
+}
+
+// UnsubscribeSavedSearch stops the notifications, reporting whether the user was subscribed :
+func UnsubscribeSavedSearch(id int, userID int) (bool, error) {
+	res, err := db.DB.Exec(`DELETE FROM saved_search_subscriptions WHERE search_id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// StartSavedSearchNotifier periodically checks subscribed saved searches for new matching files :
+func StartSavedSearchNotifier(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			time.Sleep(interval)
+			if err := NotifySavedSearchMatches(); err != nil {
+				log.Printf("❌ saved search notifications failed: %v", err)
+			}
+		}
+	}()
+}
+
+// savedSearchCommitLag keeps the notifier behind the newest uploads : uploaded_at is stamped when the
+// upload's transaction starts, so a file only counts once any transaction older than it is long done.
+const savedSearchCommitLag = 2 * time.Minute
+
+// NotifySavedSearchMatches sends one notification per subscription with files uploaded since the last
+// check that match for the subscriber (their own uploads & quarantined files don't count).
+// Subscriptions that lost access (left the team, unshared) are dropped.
+func NotifySavedSearchMatches() error {
+	var cutoff time.Time
+	err := db.DB.QueryRow(`SELECT NOW()::timestamp - make_interval(secs => $1)`, savedSearchCommitLag.Seconds()).Scan(&cutoff)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT `+savedSearchColumns+`, ss.user_id, ss.seen_until
+		FROM saved_search_subscriptions ss
+		JOIN saved_searches s ON s.id = ss.search_id
+		JOIN users u ON u.id = s.user_id
+		WHERE ss.seen_until < $1`, cutoff)
+	if err != nil {
+		return err
+	}
+	type subscription struct {
+		userID    int
+		seenUntil time.Time
+		search    *SavedSearch
+	}
+	var subs []subscription
+	for rows.Next() {
+		var sub subscription
+		if sub.search, err = scanSavedSearch(rows, &sub.userID, &sub.seenUntil); err != nil {
+			rows.Close()
+			return err
+		}
+		subs = append(subs, sub)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if err := notifySubscription(sub.userID, sub.seenUntil, cutoff, sub.search); err != nil {
+			log.Printf("❌ saved search %d for user %d: %v", sub.search.ID, sub.userID, err)
+		}
+	}
+	return nil
+}
+
+// notifySubscription counts the new matches uploaded in (seenUntil, cutoff] and moves the watermark :
+func notifySubscription(userID int, seenUntil time.Time, cutoff time.Time, s *SavedSearch) error {
+	drop := func() error {
+		_, err := db.DB.Exec(`DELETE FROM saved_search_subscriptions WHERE search_id = $1 AND user_id = $2`, s.ID, userID)
+		return err
+	}
+	if !CanUseSavedSearch(userID, s) {
+		return drop()
+	}
+	filter, err := ParseFileQuery(s.Params)
+	if err != nil {
+		return err
+	}
+	where, args, err := UserFileScope(userID, s.Params, nil)
+	if err == ErrNotTeamMember {
+		return drop()
+	} else if err != nil {
+		return err
+	}
+	filterWhere, args := filter.Where(args)
+	args = append(args, seenUntil, cutoff, userID)
+	where += filterWhere + fmt.Sprintf(" AND f.uploaded_at > $%d AND f.uploaded_at <= $%d AND f.user_id <> $%d AND f.quarantined_at IS NULL",
+		len(args)-2, len(args)-1, len(args))
+
+	totals, err := CountFiles(where, args)
+	if err != nil {
+		return err
+	}
+	if totals.Total > 0 {
+		body := fmt.Sprintf("%d new file(s) match your saved search %q.", totals.Total, s.Name)
+		if err := Notify(userID, NotifySavedSearch, "New files in "+s.Name, body, false); err != nil {
+			return err
+		}
+	}
+	_, err = db.DB.Exec(`
+		UPDATE saved_search_subscriptions SET seen_until = $3
+		WHERE search_id = $1 AND user_id = $2`, s.ID, userID, cutoff)
+	return err
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// content extraction statuses (file_contents.status) :
+const (
+	ContentPending = "pending"
+	ContentDone    = "done"
+	ContentSkipped = "skipped" // type the extractor can't read
+	ContentFailed  = "failed"  // gave up after maxExtractAttempts
+)
+
+const maxExtractAttempts = 3
+
+// searchVectorSQL builds files.search_vector of the file row f : filename (A), description, tags &
+// metadata values (B), extracted content (C). Punctuation in names is split so "q3_report.pdf" matches "report".
+const searchVectorSQL = `
+	setweight(to_tsvector('english', regexp_replace(f.filename, '[._-]+', ' ', 'g')), 'A') ||
+	setweight(to_tsvector('english', COALESCE(f.description, '')), 'B') ||
+	setweight(to_tsvector('english', COALESCE((
+		SELECT string_agg(t.name, ' ') FROM file_tags ft JOIN tags t ON t.id = ft.tag_id WHERE ft.file_id = f.id
+	), '')), 'B') ||
+	setweight(to_tsvector('english', COALESCE((
+		SELECT string_agg(m.value, ' ') FROM file_metadata m WHERE m.file_id = f.id
+	), '')), 'B') ||
+	setweight(to_tsvector('english', COALESCE((SELECT c.content FROM file_contents c WHERE c.hash = f.hash), '')), 'C')`
+
+// highlighted terms come back wrapped in these, swapped for <mark> once the snippet is escaped :
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+const headlineOptions = "StartSel=" + markStart + ", StopSel=" + markStop +
+	", MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=\" … \""
+
+// wakes the extractor when new content is queued :
+var extractWake = make(chan struct{}, 1)
+
+// RefreshSearchVectors rebuilds search_vector of the files matching where (over files f) :
+func RefreshSearchVectors(where string, args ...interface{}) error {
+	_, err := db.DB.Exec(`UPDATE files f SET search_vector = `+searchVectorSQL+` WHERE `+where, args...)
+	return err
+}
+
+// IndexFile makes a new upload searchable : its name & description right away, its content once
+// the extractor got to it (done once per content hash, deduplicated copies reuse it).
+func IndexFile(fileID int, hash string) {
+	if _, err := db.DB.Exec(`INSERT INTO file_contents (hash) VALUES ($1) ON CONFLICT (hash) DO NOTHING`, hash); err != nil {
+		log.Printf("❌ queueing content extraction failed: %v", err)
+	}
+	if err := RefreshSearchVectors("f.id = $1", fileID); err != nil {
+		log.Printf("❌ search index update failed: %v", err)
+	}
+	select {
+	case extractWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartContentExtractor runs the background extractor : pending contents are processed as soon as
+// they are queued, and at least every interval (which also drops contents no file uses anymore).
+func StartContentExtractor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			for {
+				more, err := extractNextContent()
+				if err != nil {
+					log.Printf("❌ content extraction failed: %v", err)
+					break
+				}
+				if !more {
+					break
+				}
+			}
+			select {
+			case <-extractWake:
+			case <-time.After(interval):
+				if _, err := db.DB.Exec(`
+					DELETE FROM file_contents c
+					WHERE NOT EXISTS (SELECT 1 FROM files f WHERE f.hash = c.hash)`,
+				); err != nil {
+					log.Printf("❌ orphaned contents cleanup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// extractNextContent extracts the oldest pending content, reporting whether one was found :
+func extractNextContent() (bool, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var hash string
+	var attempts int
+	err = tx.QueryRow(`
+		SELECT hash, attempts FROM file_contents
+		WHERE status = $1
+		ORDER BY updated_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, ContentPending,
+	).Scan(&hash, &attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// any copy will do, they share the blob :
+	var filename, path, mimeType string
+	err = tx.QueryRow(`
+		SELECT filename, filepath, COALESCE(mime_type, '') FROM files
+		WHERE hash = $1
+		ORDER BY is_master DESC, id
+		LIMIT 1`, hash,
+	).Scan(&filename, &path, &mimeType)
+	if err == sql.ErrNoRows {
+		if _, err := tx.Exec(`DELETE FROM file_contents WHERE hash = $1`, hash); err != nil {
+			return false, err
+		}
+		return true, tx.Commit()
+	} else if err != nil {
+		return false, err
+	}
+
+	text, extractErr := ExtractText(path, filename, mimeType)
+	status, errMsg := ContentDone, sql.NullString{}
+	switch {
+	case extractErr == ErrContentUnsupported:
+		status = ContentSkipped
+	case extractErr != nil:
+		attempts++
+		status = ContentPending
+		if attempts >= maxExtractAttempts {
+			status = ContentFailed
+		}
+		errMsg = sql.NullString{String: extractErr.Error(), Valid: true}
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE file_contents
+		SET status = $2, content = $3, error = $4, attempts = $5, updated_at = NOW()
+		WHERE hash = $1`,
+		hash, status, text, errMsg, attempts,
+	); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	if status == ContentDone && text != "" {
+		if err := RefreshSearchVectors("f.hash = $1", hash); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// SearchResult is one ranked full-text match :
+type SearchResult struct {
+	ID         int       `json:"id"`
+	Filename   string    `json:"filename"`
+	Size       int64     `json:"size"`
+	MimeType   string    `json:"mime_type"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Uploader   string    `json:"uploader"`
+	IsPublic   bool      `json:"is_public"`
+	TeamID     *int      `json:"team_id"`
+	Rank       float64   `json:"rank"`
+	Snippet    string    `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+}
+
+// SearchFiles ranks the files matching the web-search style text (quoted phrases, OR, -word)
+// among those where selects (over files f JOIN users u, args numbered accordingly).
+func SearchFiles(text string, where string, args []interface{}, limit int, offset int) ([]SearchResult, error) {
+	args = append(args, text, headlineOptions)
+	queryArg, optsArg := len(args)-1, len(args)
+
+	rows, err := db.DB.Query(fmt.Sprintf(`
+		SELECT m.id, m.filename, m.size, m.mime_type, m.uploaded_at, m.username, m.is_public, m.team_id, m.rank,
+		       ts_headline('english', m.body, m.query, $%[2]d)
+		FROM (
+			SELECT f.id, f.filename, f.size, COALESCE(f.mime_type, '') AS mime_type, f.uploaded_at,
+			       u.username, f.is_public, f.team_id, q.query,
+			       ts_rank_cd(f.search_vector, q.query) AS rank,
+			       COALESCE(f.description, '') || E'\n' || COALESCE(c.content, '') AS body
+			FROM files f
+			JOIN users u ON f.user_id = u.id
+			LEFT JOIN file_contents c ON c.hash = f.hash
+			CROSS JOIN websearch_to_tsquery('english', $%[1]d) AS q(query)
+			WHERE f.search_vector @@ q.query AND %[3]s
+			ORDER BY rank DESC, f.id DESC
+			LIMIT %[4]d OFFSET %[5]d
+		) m
+		ORDER BY m.rank DESC, m.id DESC`, queryArg, optsArg, where, limit, offset), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var s SearchResult
+		if err := rows.Scan(&s.ID, &s.Filename, &s.Size, &s.MimeType, &s.UploadedAt, &s.Uploader,
+			&s.IsPublic, &s.TeamID, &s.Rank, &s.Snippet); err != nil {
+			return nil, err
+		}
+		s.Snippet = highlight(s.Snippet)
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
+
+// CountSearchMatches counts every match of SearchFiles' text & where :
+func CountSearchMatches(text string, where string, args []interface{}) (int, error) {
+	args = append(args, text)
+	var n int
+	err := db.DB.QueryRow(`
+		SELECT COUNT(*)
+		FROM files f JOIN users u ON f.user_id = u.id
+		WHERE f.search_vector @@ websearch_to_tsquery('english', $`+strconv.Itoa(len(args))+`) AND `+where,
+		args...,
+	).Scan(&n)
+	return n, err
+}
+
+// highlight escapes a headline and turns the match markers into <mark> tags :
+func highlight(s string) string {
+	s = html.EscapeString(strings.TrimSpace(s))
+	return strings.NewReplacer(markStart, "<mark>", markStop, "</mark>").Replace(s)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// session errors, surfaced as 401 by AuthMiddleware :
+var (
+	ErrSessionRevoked  = errors.New("session has been revoked")
+	ErrAccountInactive = errors.New("account is deactivated")
+)
+
+// per-user session state cache; local changes invalidate it right away,
+// other instances pick them up within sessionCacheTTL :
+type sessionState struct {
+	exists   bool
+	active   bool
+	version  int
+	loadedAt time.Time
+}
+
+var (
+	sessionCache    = make(map[int]sessionState)
+	sessionMu       sync.Mutex
+	sessionCacheTTL = 15 * time.Second
+)
+
+// CheckSession verifies that a token issued at sessionVersion is still good :
+// the user exists, is active and hasn't been force-logged-out since.
+func CheckSession(userID int, sessionVersion int) error {
+	sessionMu.Lock()
+	st, ok := sessionCache[userID]
+	sessionMu.Unlock()
+
+	if !ok || time.Since(st.loadedAt) > sessionCacheTTL {
+		st = sessionState{loadedAt: time.Now()}
+		err := db.DB.QueryRow(`SELECT is_active, session_version FROM users WHERE id=$1`, userID).Scan(&st.active, &st.version)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		st.exists = err == nil
+
+		sessionMu.Lock()
+		sessionCache[userID] = st
+		sessionMu.Unlock()
+	}
+
+	switch {
+	case !st.exists:
+		return ErrSessionRevoked
+	case !st.active:
+		return ErrAccountInactive
+	case st.version != sessionVersion:
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// InvalidateSessionCache drops the cached state of a user :
+func InvalidateSessionCache(userID int) {
+	sessionMu.Lock()
+	delete(sessionCache, userID)
+	sessionMu.Unlock()
+}
+
+// RevokeSessions invalidates every token issued to the user so far :
+func RevokeSessions(userID int) (bool, error) {
+	res, err := db.DB.Exec(`UPDATE users SET session_version = session_version + 1 WHERE id=$1`, userID)
+	if err != nil {
+		return false, err
+	}
+	InvalidateSessionCache(userID)
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
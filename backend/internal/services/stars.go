@@ -0,0 +1,144 @@
+package services
+
+import (
+	"backend/internal/db"
+	"log"
+	"time"
+)
+
+// maxRecentFiles is how many recently accessed files are kept per user :
+const maxRecentFiles = 100
+
+// readableBy is the SQL condition for files f the user ($n) can still read : own files, their
+// teams' files and public files, quarantined ones excluded (see ReadAccessVia).
+func readableBy(p string) string {
+	return `f.quarantined_at IS NULL AND (f.user_id = ` + p + ` OR f.is_public = TRUE
+		OR f.team_id IN (SELECT team_id FROM team_members WHERE user_id = ` + p + `))`
+}
+
+// FileSummary is one entry of the starred / recent lists :
+type FileSummary struct {
+	ID         int        `json:"id"`
+	Filename   string     `json:"filename"`
+	Size       int64      `json:"size"`
+	MimeType   string     `json:"mime_type"`
+	UploadedAt time.Time  `json:"uploaded_at"`
+	Uploader   string     `json:"uploader"`
+	IsPublic   bool       `json:"is_public"`
+	TeamID     *int       `json:"team_id"`
+	StarredAt  *time.Time `json:"starred_at,omitempty"`
+	ViewedAt   *time.Time `json:"viewed_at,omitempty"`
+	Views      int        `json:"views,omitempty"`
+}
+
+// StarFile stars a file for the user (idempotent) :
+func StarFile(userID int, fileID int) error {
+	_, err := db.DB.Exec(`
+		INSERT INTO file_stars (user_id, file_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, file_id) DO NOTHING`, userID, fileID)
+	return err
+}
+
+// UnstarFile removes the star, reporting whether there was one :
+func UnstarFile(userID int, fileID int) (bool, error) {
+	res, err := db.DB.Exec(`DELETE FROM file_stars WHERE user_id = $1 AND file_id = $2`, userID, fileID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ListStarredFiles returns the user's starred files they can still read, latest stars first :
+func ListStarredFiles(userID int, limit int, offset int) ([]FileSummary, int, error) {
+	var total int
+	if err := db.DB.QueryRow(`
+		SELECT COUNT(*) FROM file_stars s JOIN files f ON f.id = s.file_id
+		WHERE s.user_id = $1 AND `+readableBy("$1"), userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT f.id, f.filename, f.size, COALESCE(f.mime_type, ''), f.uploaded_at, u.username, f.is_public, f.team_id,
+		       s.created_at
+		FROM file_stars s
+		JOIN files f ON f.id = s.file_id
+		JOIN users u ON u.id = f.user_id
+		WHERE s.user_id = $1 AND `+readableBy("$1")+`
+		ORDER BY s.created_at DESC, f.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	files := []FileSummary{}
+	for rows.Next() {
+		var f FileSummary
+		if err := rows.Scan(&f.ID, &f.Filename, &f.Size, &f.MimeType, &f.UploadedAt, &f.Uploader, &f.IsPublic, &f.TeamID,
+			&f.StarredAt); err != nil {
+			return nil, 0, err
+		}
+		files = append(files, f)
+	}
+	return files, total, rows.Err()
+}
+
+// RecordFileView remembers a download / detail view for the user's recent files, keeping the
+// latest maxRecentFiles. Failures are only logged, they never block the view itself.
+func RecordFileView(userID int, fileID int) {
+	if userID == 0 {
+		return
+	}
+	if _, err := db.DB.Exec(`
+		INSERT INTO file_views (user_id, file_id) VALUES ($1, $2)
+		ON CONFLICT (user_id, file_id) DO UPDATE SET viewed_at = NOW(), views = file_views.views + 1`,
+		userID, fileID,
+	); err != nil {
+		log.Printf("❌ recording file view failed: %v", err)
+		return
+	}
+	if _, err := db.DB.Exec(`
+		DELETE FROM file_views
+		WHERE user_id = $1 AND file_id NOT IN (
+			SELECT file_id FROM file_views WHERE user_id = $1 ORDER BY viewed_at DESC LIMIT $2
+		)`, userID, maxRecentFiles,
+	); err != nil {
+		log.Printf("❌ trimming recent files failed: %v", err)
+	}
+}
+
+// ListRecentFiles returns the files the user recently downloaded or opened, still readable :
+func ListRecentFiles(userID int, limit int) ([]FileSummary, error) {
+	rows, err := db.DB.Query(`
+		SELECT f.id, f.filename, f.size, COALESCE(f.mime_type, ''), f.uploaded_at, u.username, f.is_public, f.team_id,
+		       v.viewed_at, v.views
+		FROM file_views v
+		JOIN files f ON f.id = v.file_id
+		JOIN users u ON u.id = f.user_id
+		WHERE v.user_id = $1 AND `+readableBy("$1")+`
+		ORDER BY v.viewed_at DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	files := []FileSummary{}
+	for rows.Next() {
+		var f FileSummary
+		if err := rows.Scan(&f.ID, &f.Filename, &f.Size, &f.MimeType, &f.UploadedAt, &f.Uploader, &f.IsPublic, &f.TeamID,
+			&f.ViewedAt, &f.Views); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// IsStarred reports whether the user starred the file :
+func IsStarred(userID int, fileID int) (bool, error) {
+	var starred bool
+	err := db.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM file_stars WHERE user_id = $1 AND file_id = $2)`,
+		userID, fileID).Scan(&starred)
+	return starred, err
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"backend/internal/db"
+	"log"
+	"time"
+)
+
+// StorageByMime is storage of one MIME type across all users :
+type StorageByMime struct {
+	MimeType      string `json:"mime_type"`
+	Files         int    `json:"files"`
+	LogicalBytes  int64  `json:"logical_bytes"`
+	PhysicalBytes int64  `json:"physical_bytes"`
+}
+
+// StorageByUser is the storage of one of the biggest users :
+type StorageByUser struct {
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+	Files        int    `json:"files"`
+	LogicalBytes int64  `json:"logical_bytes"`
+}
+
+// DailyUploads is the upload volume of one day :
+type DailyUploads struct {
+	Day     time.Time `json:"day"`
+	Uploads int       `json:"uploads"`
+	Bytes   int64     `json:"bytes"`
+}
+
+// SystemStats is the admin overview. Logical bytes count every file row,
+// physical bytes only the stored masters, so the difference is the dedup saving.
+type SystemStats struct {
+	TotalUsers        int             `json:"total_users"`
+	ActiveUsers       int             `json:"active_users"`
+	TotalFiles        int             `json:"total_files"`
+	LogicalBytes      int64           `json:"logical_bytes"`
+	PhysicalBytes     int64           `json:"physical_bytes"`
+	DedupSavingsBytes int64           `json:"dedup_savings_bytes"`
+	DedupRatio        float64         `json:"dedup_ratio"`
+	ByMimeType        []StorageByMime `json:"by_mime_type"`
+	TopUsers          []StorageByUser `json:"top_users"`
+	UploadsPerDay     []DailyUploads  `json:"uploads_per_day"`
+}
+
+// totals fills the headline numbers :
+func (s *SystemStats) totals() error {
+	err := db.DB.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE is_active) FROM users`).Scan(&s.TotalUsers, &s.ActiveUsers)
+	if err != nil {
+		return err
+	}
+	err = db.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(size) FILTER (WHERE is_master), 0)
+		FROM files`).Scan(&s.TotalFiles, &s.LogicalBytes, &s.PhysicalBytes)
+	if err != nil {
+		return err
+	}
+	s.DedupSavingsBytes = s.LogicalBytes - s.PhysicalBytes
+	if s.PhysicalBytes > 0 {
+		s.DedupRatio = float64(s.LogicalBytes) / float64(s.PhysicalBytes)
+	}
+	return nil
+}
+
+// GetSystemStats computes the overview in SQL, with uploads for the last `days` days
+// and the `topUsers` biggest users :
+func GetSystemStats(days int, topUsers int) (*SystemStats, error) {
+	s := &SystemStats{
+		ByMimeType:    make([]StorageByMime, 0),
+		TopUsers:      make([]StorageByUser, 0),
+		UploadsPerDay: make([]DailyUploads, 0),
+	}
+	if err := s.totals(); err != nil {
+		return nil, err
+	}
+
+	// by MIME type :
+	rows, err := db.DB.Query(`
+		SELECT COALESCE(mime_type, 'unknown'), COUNT(*), COALESCE(SUM(size), 0),
+		       COALESCE(SUM(size) FILTER (WHERE is_master), 0)
+		FROM files GROUP BY 1 ORDER BY 3 DESC`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var m StorageByMime
+		if err := rows.Scan(&m.MimeType, &m.Files, &m.LogicalBytes, &m.PhysicalBytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.ByMimeType = append(s.ByMimeType, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// biggest users (everything they uploaded, personal and team) :
+	rows, err = db.DB.Query(`
+		SELECT u.id, u.username, COUNT(f.id), COALESCE(SUM(f.size), 0)
+		FROM files f JOIN users u ON u.id = f.user_id
+		GROUP BY u.id, u.username
+		ORDER BY 4 DESC, u.id
+		LIMIT $1`, topUsers)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var u StorageByUser
+		if err := rows.Scan(&u.UserID, &u.Username, &u.Files, &u.LogicalBytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		s.TopUsers = append(s.TopUsers, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// upload volume per day (files that still exist) :
+	rows, err = db.DB.Query(`
+		SELECT date_trunc('day', uploaded_at) AS d, COUNT(*), COALESCE(SUM(size), 0)
+		FROM files
+		WHERE uploaded_at >= date_trunc('day', NOW()) - make_interval(days => $1 - 1)
+		GROUP BY d ORDER BY d`, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DailyUploads
+		if err := rows.Scan(&d.Day, &d.Uploads, &d.Bytes); err != nil {
+			return nil, err
+		}
+		s.UploadsPerDay = append(s.UploadsPerDay, d)
+	}
+	return s, rows.Err()
+}
+
+// StatsSnapshot is one stored day of history :
+type StatsSnapshot struct {
+	Day           time.Time `json:"day"`
+	TotalUsers    int       `json:"total_users"`
+	ActiveUsers   int       `json:"active_users"`
+	TotalFiles    int       `json:"total_files"`
+	LogicalBytes  int64     `json:"logical_bytes"`
+	PhysicalBytes int64     `json:"physical_bytes"`
+	DedupRatio    float64   `json:"dedup_ratio"`
+	Uploads       int       `json:"uploads"`
+	UploadBytes   int64     `json:"upload_bytes"`
+	TakenAt       time.Time `json:"taken_at"`
+}
+
+// TakeStatsSnapshot upserts today's row with the current totals :
+func TakeStatsSnapshot() error {
+	s := &SystemStats{}
+	if err := s.totals(); err != nil {
+		return err
+	}
+	_, err := db.DB.Exec(`
+		INSERT INTO stats_snapshots (day, total_users, active_users, total_files, logical_bytes, physical_bytes, uploads, upload_bytes, taken_at)
+		SELECT CURRENT_DATE, $1, $2, $3, $4, $5, COUNT(*), COALESCE(SUM(size), 0), NOW()
+		FROM files WHERE uploaded_at >= CURRENT_DATE
+		ON CONFLICT (day) DO UPDATE SET
+			total_users = EXCLUDED.total_users,
+			active_users = EXCLUDED.active_users,
+			total_files = EXCLUDED.total_files,
+			logical_bytes = EXCLUDED.logical_bytes,
+			physical_bytes = EXCLUDED.physical_bytes,
+			uploads = EXCLUDED.uploads,
+			upload_bytes = EXCLUDED.upload_bytes,
+			taken_at = EXCLUDED.taken_at`,
+		s.TotalUsers, s.ActiveUsers, s.TotalFiles, s.LogicalBytes, s.PhysicalBytes,
+	)
+	return err
+}
+
+// ListStatsSnapshots returns the stored days in [from, to], oldest first :
+func ListStatsSnapshots(from time.Time, to time.Time) ([]StatsSnapshot, error) {
+	rows, err := db.DB.Query(`
+		SELECT day, total_users, active_users, total_files, logical_bytes, physical_bytes, uploads, upload_bytes, taken_at
+		FROM stats_snapshots
+		WHERE day >= $1::date AND day <= $2::date
+		ORDER BY day`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]StatsSnapshot, 0)
+	for rows.Next() {
+		var s StatsSnapshot
+		if err := rows.Scan(&s.Day, &s.TotalUsers, &s.ActiveUsers, &s.TotalFiles, &s.LogicalBytes, &s.PhysicalBytes,
+			&s.Uploads, &s.UploadBytes, &s.TakenAt); err != nil {
+			return nil, err
+		}
+		if s.PhysicalBytes > 0 {
+			s.DedupRatio = float64(s.LogicalBytes) / float64(s.PhysicalBytes)
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// StartStatsSnapshotter refreshes today's snapshot now and then every interval,
+// so each day keeps the state of its last run :
+func StartStatsSnapshotter(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			if err := TakeStatsSnapshot(); err != nil {
+				log.Printf("❌ stats snapshot failed: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
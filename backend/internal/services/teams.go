@@ -0,0 +1,357 @@
+package services
+
+import (
+	"backend/internal/db"
+	"backend/internal/utils"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// team member roles :
+const (
+	TeamRoleOwner  = "owner"
+	TeamRoleAdmin  = "admin"
+	TeamRoleMember = "member"
+)
+
+var (
+	ErrTeamNameTaken    = errors.New("team name already taken")
+	ErrInvalidTeamName  = errors.New("team name must be 2-64 characters")
+	ErrInvalidTeamRole  = errors.New("team role must be owner, admin or member")
+	ErrAlreadyMember    = errors.New("user is already a team member")
+	ErrNotTeamMember    = errors.New("user is not a team member")
+	ErrLastTeamOwner    = errors.New("a team needs at least one owner")
+	ErrFolderNotFound   = errors.New("folder not found")
+	ErrInvalidFolderArg = errors.New("folder name must be 1-255 characters")
+)
+
+// Team is a shared workspace as seen by one of its members :
+type Team struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	MyRole      string    `json:"my_role"`
+	QuotaBytes  int64     `json:"quota_bytes"`
+	UsedBytes   int64     `json:"used_bytes"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TeamMember is one row of team_members joined with the user :
+type TeamMember struct {
+	UserID   int       `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+// Folder groups files inside a personal or team space :
+type Folder struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	UserID    int       `json:"user_id"`
+	TeamID    *int      `json:"team_id"`
+	ParentID  *int      `json:"parent_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ValidTeamRole reports whether role is a known team role :
+func ValidTeamRole(role string) bool {
+	return role == TeamRoleOwner || role == TeamRoleAdmin || role == TeamRoleMember
+}
+
+// IsTeamManager reports whether the team role may manage membership :
+func IsTeamManager(role string) bool {
+	return role == TeamRoleOwner || role == TeamRoleAdmin
+}
+
+// CreateTeam creates a team with ownerID as its first owner :
+func CreateTeam(name string, ownerID int) (int, error) {
+	name = strings.TrimSpace(name)
+	if len(name) < 2 || len(name) > 64 {
+		return 0, ErrInvalidTeamName
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var teamID int
+	err = tx.QueryRow(`INSERT INTO teams (name, created_by) VALUES ($1, $2) RETURNING id`, name, ownerID).Scan(&teamID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return 0, ErrTeamNameTaken
+	} else if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO team_members (team_id, user_id, role) VALUES ($1, $2, $3)`, teamID, ownerID, TeamRoleOwner)
+	if err != nil {
+		return 0, err
+	}
+	return teamID, tx.Commit()
+}
+
+// ListUserTeams returns the teams a user belongs to, with quota usage :
+func ListUserTeams(userID int) ([]Team, error) {
+	rows, err := db.DB.Query(`
+		SELECT t.id, t.name, m.role, t.quota_bytes, t.created_at,
+		       (SELECT COALESCE(SUM(size), 0) FROM files f WHERE f.team_id = t.id),
+		       (SELECT COUNT(*) FROM team_members m2 WHERE m2.team_id = t.id)
+		FROM teams t
+		JOIN team_members m ON m.team_id = t.id
+		WHERE m.user_id = $1
+		ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]Team, 0)
+	for rows.Next() {
+		var t Team
+		var quota sql.NullInt64
+		if err := rows.Scan(&t.ID, &t.Name, &t.MyRole, &quota, &t.CreatedAt, &t.UsedBytes, &t.MemberCount); err != nil {
+			return nil, err
+		}
+		t.QuotaBytes = utils.GetTeamQuotaBytes()
+		if quota.Valid {
+			t.QuotaBytes = quota.Int64
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// GetTeamRole returns the user's role in the team, or "" if not a member :
+func GetTeamRole(teamID int, userID int) (string, error) {
+	var role string
+	err := db.DB.QueryRow(`SELECT role FROM team_members WHERE team_id=$1 AND user_id=$2`, teamID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// ListTeamMembers returns all members of a team :
+func ListTeamMembers(teamID int) ([]TeamMember, error) {
+	rows, err := db.DB.Query(`
+		SELECT u.id, u.username, u.email, m.role, m.added_at
+		FROM team_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1
+		ORDER BY m.role, u.username
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]TeamMember, 0)
+	for rows.Next() {
+		var m TeamMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Email, &m.Role, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddTeamMember adds a user to a team :
+func AddTeamMember(teamID int, userID int, role string) error {
+	if !ValidTeamRole(role) {
+		return ErrInvalidTeamRole
+	}
+	_, err := db.DB.Exec(`INSERT INTO team_members (team_id, user_id, role) VALUES ($1, $2, $3)`, teamID, userID, role)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrAlreadyMember
+	}
+	return err
+}
+
+// UpdateTeamMemberRole changes a member's role, keeping at least one owner :
+func UpdateTeamMemberRole(teamID int, userID int, role string) error {
+	if !ValidTeamRole(role) {
+		return ErrInvalidTeamRole
+	}
+	return changeMembership(teamID, userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE team_members SET role=$1 WHERE team_id=$2 AND user_id=$3`, role, teamID, userID)
+		return err
+	})
+}
+
+// RemoveTeamMember removes a user from a team, keeping at least one owner.
+// Files they uploaded stay in the team space.
+func RemoveTeamMember(teamID int, userID int) error {
+	return changeMembership(teamID, userID, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`DELETE FROM team_members WHERE team_id=$1 AND user_id=$2`, teamID, userID)
+		return err
+	})
+}
+
+// changeMembership runs fn with the team's member rows locked and checks an owner remains :
+func changeMembership(teamID int, userID int, fn func(tx *sql.Tx) error) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// locking the team's member rows so concurrent demotions can't race :
+	if _, err := tx.Exec(`SELECT 1 FROM team_members WHERE team_id=$1 FOR UPDATE`, teamID); err != nil {
+		return err
+	}
+	var exists bool
+	err = tx.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM team_members WHERE team_id=$1 AND user_id=$2)`, teamID, userID,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotTeamMember
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	var owners int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM team_members WHERE team_id=$1 AND role=$2`, teamID, TeamRoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastTeamOwner
+	}
+	return tx.Commit()
+}
+
+// GetTeamQuota returns the team's quota limit and current usage in bytes :
+func GetTeamQuota(teamID int) (limit int64, used int64, err error) {
+	var quota sql.NullInt64
+	err = db.DB.QueryRow(`
+		SELECT t.quota_bytes, (SELECT COALESCE(SUM(size), 0) FROM files f WHERE f.team_id = t.id)
+		FROM teams t WHERE t.id = $1`, teamID,
+	).Scan(&quota, &used)
+	if err != nil {
+		return 0, 0, err
+	}
+	limit = utils.GetTeamQuotaBytes()
+	if quota.Valid {
+		limit = quota.Int64
+	}
+	return limit, used, nil
+}
+
+// SetTeamQuota overrides a team's quota; nil resets it to the default :
+func SetTeamQuota(teamID int, quotaBytes *int64) (bool, error) {
+	res, err := db.DB.Exec(`UPDATE teams SET quota_bytes=$1 WHERE id=$2`, quotaBytes, teamID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// CreateFolder creates a folder in the user's personal space or in a team space :
+func CreateFolder(name string, userID int, teamID *int, parentID *int) (*Folder, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return nil, ErrInvalidFolderArg
+	}
+
+	// parent must live in the same space :
+	if parentID != nil {
+		parent, err := GetFolder(*parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || !sameSpace(parent, userID, teamID) {
+			return nil, ErrFolderNotFound
+		}
+	}
+
+	f := &Folder{Name: name, UserID: userID, TeamID: teamID, ParentID: parentID}
+	err := db.DB.QueryRow(
+		`INSERT INTO folders (name, user_id, team_id, parent_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		name, userID, teamID, parentID,
+	).Scan(&f.ID, &f.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// sameSpace reports whether the folder belongs to the given personal/team space :
+func sameSpace(f *Folder, userID int, teamID *int) bool {
+	if teamID == nil {
+		return f.TeamID == nil && f.UserID == userID
+	}
+	return f.TeamID != nil && *f.TeamID == *teamID
+}
+
+// GetFolder returns a folder or (nil, nil) if not found :
+func GetFolder(id int) (*Folder, error) {
+	f := &Folder{}
+	var teamID, parentID sql.NullInt64
+	err := db.DB.QueryRow(
+		`SELECT id, name, user_id, team_id, parent_id, created_at FROM folders WHERE id=$1`, id,
+	).Scan(&f.ID, &f.Name, &f.UserID, &teamID, &parentID, &f.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f.TeamID = nullIntPtr(teamID)
+	f.ParentID = nullIntPtr(parentID)
+	return f, nil
+}
+
+// ListFolders returns the folders of a personal space (teamID nil) or a team space :
+func ListFolders(userID int, teamID *int) ([]Folder, error) {
+	var rows *sql.Rows
+	var err error
+	if teamID == nil {
+		rows, err = db.DB.Query(`
+			SELECT id, name, user_id, team_id, parent_id, created_at
+			FROM folders WHERE user_id=$1 AND team_id IS NULL ORDER BY name`, userID)
+	} else {
+		rows, err = db.DB.Query(`
+			SELECT id, name, user_id, team_id, parent_id, created_at
+			FROM folders WHERE team_id=$1 ORDER BY name`, *teamID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := make([]Folder, 0)
+	for rows.Next() {
+		var f Folder
+		var tID, pID sql.NullInt64
+		if err := rows.Scan(&f.ID, &f.Name, &f.UserID, &tID, &pID, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.TeamID = nullIntPtr(tID)
+		f.ParentID = nullIntPtr(pID)
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// nullIntPtr converts a nullable DB int into *int :
+func nullIntPtr(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
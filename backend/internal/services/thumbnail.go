@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrPreviewUnsupported is returned for file types without a preview :
+var ErrPreviewUnsupported = errors.New("no preview for this file type")
+
+// preview kinds (file_previews.kind) :
+const (
+	PreviewKindImage = "image"
+	PreviewKindPDF   = "pdf"
+	PreviewKindText  = "text"
+)
+
+const (
+	maxPreviewPixels = 40_000_000           // decoded image size limit, guards against decompression bombs
+	minPDFPageSide   = 200                  // smaller embedded PDF images are logos & icons, not pages
+	maxPDFPixels     = 2 * maxPreviewPixels // pixels decoded from all the images of one PDF together
+	textPreviewInput = 16 << 10             // bytes of a text file read for its preview
+	textPreviewPad   = 8
+)
+
+// Preview is a rendered thumbnail, ready to be stored :
+type Preview struct {
+	Kind     string
+	MimeType string
+	Data     []byte
+	Width    int
+	Height   int
+}
+
+// RenderPreview builds the thumbnail of an uploaded file, fit in size×size :
+//   - images (JPEG, PNG, GIF first frame, WebP) are scaled down
+//   - PDFs show their first embedded page image (scanned documents), else the start of their text
+//   - plaintext files show their first lines
+func RenderPreview(path string, filename string, mimeType string, size int) (*Preview, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case isPreviewImage(ext, mimeType):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, err := decodeBounded(f)
+		if err != nil {
+			return nil, ErrPreviewUnsupported
+		}
+		return encodePreview(PreviewKindImage, fitImage(img, size), true)
+
+	case ext == ".pdf" || strings.HasPrefix(mimeType, "application/pdf"):
+		data, err := readLimited(path)
+		if err != nil {
+			return nil, err
+		}
+		if img := pdfPageImage(data); img != nil {
+			return encodePreview(PreviewKindPDF, fitImage(img, size), true)
+		}
+		text := cleanText(extractPDF(data))
+		if text == "" {
+			return nil, ErrPreviewUnsupported
+		}
+		return encodePreview(PreviewKindPDF, renderText(text, size), false)
+
+	case isPlainText(ext, mimeType):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, textPreviewInput))
+		if err != nil {
+			return nil, err
+		}
+		return encodePreview(PreviewKindText, renderText(strings.ToValidUTF8(string(data), "?"), size), false)
+	}
+	return nil, ErrPreviewUnsupported
+}
+
+// isPreviewImage tells the image formats the decoder handles (SVG is not rendered) :
+func isPreviewImage(ext string, mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// decodeBounded decodes an image after checking its dimensions from the header :
+func decodeBounded(r io.ReadSeeker) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPreviewPixels {
+		return nil, errors.New("image dimensions out of range")
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bufio.NewReader(r))
+	return img, err
+}
+
+// fitImage scales img down (never up) so its longest side is at most size :
+func fitImage(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/w)
+		} else {
+			w, h = max(1, w*size/h), size
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// encodePreview stores photos as JPEG (PNG when they have transparency), rendered text as PNG :
+func encodePreview(kind string, img image.Image, photo bool) (*Preview, error) {
+	var buf bytes.Buffer
+	p := &Preview{Kind: kind, Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	opaque, _ := img.(interface{ Opaque() bool })
+	if photo && opaque != nil && opaque.Opaque() {
+		p.MimeType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, err
+		}
+	} else {
+		p.MimeType = "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	}
+	p.Data = buf.Bytes()
+	return p, nil
+}
+
+// renderText draws the first lines of text (wrapped, monospaced) on a size×size page :
+func renderText(text string, size int) image.Image {
+	face := basicfont.Face7x13
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	cols := max(1, (size-2*textPreviewPad)/face.Advance)
+	rows := max(1, (size-2*textPreviewPad)/face.Height)
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{0x33, 0x33, 0x33, 0xff}), Face: face}
+
+	row := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\t' {
+				return -1
+			}
+			return r
+		}, strings.ReplaceAll(line, "\t", "    "))
+		runes := []rune(line)
+		for {
+			if row >= rows {
+				return img
+			}
+			n := min(len(runes), cols)
+			d.Dot = fixed.P(textPreviewPad, textPreviewPad+row*face.Height+face.Ascent)
+			d.DrawString(string(runes[:n]))
+			row++
+			runes = runes[n:]
+			if len(runes) == 0 {
+				break
+			}
+		}
+	}
+	return img
+}
+
+// pdfPageImage returns the first large JPEG image embedded in the PDF, which for scanned documents
+// is the first page. Rendering vector pages would need a full PDF engine, so other PDFs get nil.
+func pdfPageImage(data []byte) image.Image {
+	budget := maxPDFPixels
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		if !bytes.Contains(dict, []byte("/Image")) || !bytes.Contains(dict, []byte("/DCTDecode")) ||
+			bytes.Contains(dict, []byte("/FlateDecode")) {
+			continue
+		}
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		// sizing from the header first : small images are skipped undecoded, the rest count against the budget
+		stream := data[start : start+end]
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(stream))
+		if err != nil || cfg.Width < minPDFPageSide || cfg.Height < minPDFPageSide {
+			continue
+		}
+		if budget -= cfg.Width * cfg.Height; budget < 0 {
+			break
+		}
+		img, err := decodeBounded(bytes.NewReader(stream))
+		if err != nil {
+			continue
+		}
+		if b := img.Bounds(); b.Dx() >= minPDFPageSide && b.Dy() >= minPDFPageSide {
+			return img
+		}
+	}
+	return nil
+}
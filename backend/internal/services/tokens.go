@@ -0,0 +1,93 @@
+package services
+
+import (
+	"backend/internal/db"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// token purposes stored in user_tokens.purpose :
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeEmailVerify   = "email_verify"
+)
+
+// ErrInvalidToken is returned when a token is unknown, expired or already used :
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// hashToken returns the hex SHA-256 of a raw token; only hashes are stored :
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateUserToken issues a new single-use token for the given purpose.
+// Any older unused token of the same purpose is invalidated first.
+func CreateUserToken(userID int, purpose string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	raw := hex.EncodeToString(buf)
+
+	// invalidating previous tokens :
+	_, err := db.DB.Exec(
+		`UPDATE user_tokens SET used_at = NOW()
+		 WHERE user_id=$1 AND purpose=$2 AND used_at IS NULL`,
+		userID, purpose,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// expiry computed by the DB, on the same clock (& time zone) as the NOW() it's checked against :
+	_, err = db.DB.Exec(
+		`INSERT INTO user_tokens (user_id, token_hash, purpose, expires_at)
+		 VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))`,
+		userID, hashToken(raw), purpose, ttl.Seconds(),
+	)
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ConsumeUserToken marks a token as used and returns its user ID.
+// The UPDATE is atomic so a token can never be redeemed twice.
+func ConsumeUserToken(raw string, purpose string) (int, error) {
+	var userID int
+	err := db.DB.QueryRow(
+		`UPDATE user_tokens SET used_at = NOW()
+		 WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > NOW()
+		 RETURNING user_id`,
+		hashToken(raw), purpose,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// LookupUserToken returns the user ID of a valid token without redeeming it :
+func LookupUserToken(raw string, purpose string) (int, error) {
+	var userID int
+	err := db.DB.QueryRow(
+		`SELECT user_id FROM user_tokens
+		 WHERE token_hash=$1 AND purpose=$2 AND used_at IS NULL AND expires_at > NOW()`,
+		hashToken(raw), purpose,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
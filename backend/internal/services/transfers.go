@@ -0,0 +1,325 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// where a transfer was started from :
+const (
+	TransferViaAPI        = "api"
+	TransferViaCLI        = "cli"
+	TransferViaUserDelete = "user_delete"
+)
+
+var (
+	ErrTransferSameUser  = errors.New("source and target user must differ")
+	ErrTransferSelection = errors.New("set all, or file_ids / folder_ids owned by the source user")
+)
+
+// TransferRequest picks what goes from one user to another: everything (All), or
+// the listed files plus the listed folders with everything below them.
+type TransferRequest struct {
+	FromUserID int    `json:"from_user_id"`
+	ToUserID   int    `json:"to_user_id"`
+	All        bool   `json:"all"`
+	FileIDs    []int  `json:"file_ids,omitempty"`
+	FolderIDs  []int  `json:"folder_ids,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// TransferActor says who ran a transfer, for the history :
+type TransferActor struct {
+	UserID   *int
+	Username string
+	Source   string
+}
+
+// OwnershipTransfer is one row of the transfer history :
+type OwnershipTransfer struct {
+	ID                  int64           `json:"id"`
+	FromUserID          int             `json:"from_user_id"`
+	FromUsername        string          `json:"from_username"`
+	ToUserID            int             `json:"to_user_id"`
+	ToUsername          string          `json:"to_username"`
+	PerformedBy         *int            `json:"performed_by"`
+	PerformedByUsername string          `json:"performed_by_username"`
+	Source              string          `json:"source"`
+	Selection           json.RawMessage `json:"selection"`
+	Files               int             `json:"files"`
+	Folders             int             `json:"folders"`
+	Bytes               int64           `json:"bytes"`
+	HandedToTeamOwners  int             `json:"handed_to_team_owners"`
+	Reason              string          `json:"reason"`
+	CreatedAt           time.Time       `json:"created_at"`
+
+	// team items left in place (target not in the team and no other team owner), not stored :
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// teamOwnerOther picks another owner of %s's team than the source user ($1) :
+const teamOwnerOther = `(SELECT o.user_id FROM team_members o
+	WHERE o.team_id = %[1]s.team_id AND o.role = '` + TeamRoleOwner + `' AND o.user_id <> $1
+	ORDER BY o.added_at, o.user_id LIMIT 1)`
+
+// TransferOwnership moves files & folders between users in one transaction :
+//   - personal items go to the target; moved files whose folder stays behind land in the root,
+//     moved folders whose parent stays behind become top-level
+//   - team items go to the target if they're a member of that team, else to another owner of the team
+//   - public visibility travels with each file
+//
+// Both users' quota counters are recalculated afterwards (no limit is enforced on the target,
+// a leaver's files must always find a home) and the transfer is recorded in the history.
+func TransferOwnership(req TransferRequest, by TransferActor) (*OwnershipTransfer, error) {
+	if req.FromUserID == req.ToUserID {
+		return nil, ErrTransferSameUser
+	}
+	fileIDs, folderIDs := uniqueInts(req.FileIDs), uniqueInts(req.FolderIDs)
+	if !req.All && len(fileIDs) == 0 && len(folderIDs) == 0 {
+		return nil, ErrTransferSelection
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	t := &OwnershipTransfer{
+		FromUserID: req.FromUserID, ToUserID: req.ToUserID, Reason: req.Reason,
+		PerformedBy: by.UserID, PerformedByUsername: by.Username, Source: by.Source,
+	}
+	err = tx.QueryRow(`SELECT username FROM users WHERE id=$1 FOR UPDATE`, req.FromUserID).Scan(&t.FromUsername)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var targetActive bool
+	err = tx.QueryRow(`SELECT username, is_active FROM users WHERE id=$1 FOR UPDATE`, req.ToUserID).Scan(&t.ToUsername, &targetActive)
+	if err == sql.ErrNoRows || (err == nil && !targetActive) {
+		return nil, ErrInvalidTarget
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// resolving the selection to concrete rows of the source user :
+	var folders, files []int
+	if req.All {
+		if folders, err = queryInts(tx, `SELECT id FROM folders WHERE user_id=$1`, req.FromUserID); err != nil {
+			return nil, err
+		}
+		if files, err = queryInts(tx, `SELECT id FROM files WHERE user_id=$1`, req.FromUserID); err != nil {
+			return nil, err
+		}
+	} else {
+		var owned int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM folders WHERE id = ANY($2) AND user_id=$1`, req.FromUserID, pq.Array(folderIDs)).Scan(&owned); err != nil {
+			return nil, err
+		}
+		if owned != len(folderIDs) {
+			return nil, ErrTransferSelection
+		}
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM files WHERE id = ANY($2) AND user_id=$1`, req.FromUserID, pq.Array(fileIDs)).Scan(&owned); err != nil {
+			return nil, err
+		}
+		if owned != len(fileIDs) {
+			return nil, ErrTransferSelection
+		}
+
+		folders, err = queryInts(tx, `
+			WITH RECURSIVE tree AS (
+				SELECT id FROM folders WHERE id = ANY($2) AND user_id = $1
+				UNION
+				SELECT c.id FROM folders c JOIN tree t ON c.parent_id = t.id WHERE c.user_id = $1
+			)
+			SELECT id FROM tree`, req.FromUserID, pq.Array(folderIDs))
+		if err != nil {
+			return nil, err
+		}
+		files, err = queryInts(tx, `SELECT id FROM files WHERE user_id=$1 AND (id = ANY($2) OR folder_id = ANY($3))`,
+			req.FromUserID, pq.Array(fileIDs), pq.Array(folders))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var teamItems int
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(size) FILTER (WHERE team_id IS NULL), 0),
+		       (SELECT COUNT(*) FROM files WHERE id = ANY($1) AND team_id IS NOT NULL) +
+		       (SELECT COUNT(*) FROM folders WHERE id = ANY($2) AND team_id IS NOT NULL)
+		FROM files WHERE id = ANY($1)`, pq.Array(files), pq.Array(folders),
+	).Scan(&t.Bytes, &teamItems)
+	if err != nil {
+		return nil, err
+	}
+
+	// personal space :
+	res, err := tx.Exec(`
+		UPDATE files SET user_id = $2, folder_id = CASE WHEN folder_id = ANY($4) THEN folder_id END
+		WHERE user_id = $1 AND id = ANY($3) AND team_id IS NULL`,
+		req.FromUserID, req.ToUserID, pq.Array(files), pq.Array(folders))
+	if err != nil {
+		return nil, err
+	}
+	t.Files += rowsAffected(res)
+	res, err = tx.Exec(`
+		UPDATE folders SET user_id = $2, parent_id = CASE WHEN parent_id = ANY($3) THEN parent_id END
+		WHERE user_id = $1 AND id = ANY($3) AND team_id IS NULL`,
+		req.FromUserID, req.ToUserID, pq.Array(folders))
+	if err != nil {
+		return nil, err
+	}
+	t.Folders += rowsAffected(res)
+
+	// team spaces, the target if they're a member, another team owner otherwise :
+	for _, table := range []string{"files", "folders"} {
+		ids := files
+		if table == "folders" {
+			ids = folders
+		}
+		res, err := tx.Exec(`
+			UPDATE `+table+` x SET user_id = $2
+			WHERE x.user_id = $1 AND x.id = ANY($3) AND x.team_id IS NOT NULL
+			AND EXISTS (SELECT 1 FROM team_members m WHERE m.team_id = x.team_id AND m.user_id = $2)`,
+			req.FromUserID, req.ToUserID, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
+		if table == "files" {
+			t.Files += rowsAffected(res)
+		} else {
+			t.Folders += rowsAffected(res)
+		}
+		teamItems -= rowsAffected(res)
+
+		other := fmt.Sprintf(teamOwnerOther, "x")
+		res, err = tx.Exec(`
+			UPDATE `+table+` x SET user_id = `+other+`
+			WHERE x.user_id = $1 AND x.id = ANY($2) AND x.team_id IS NOT NULL AND `+other+` IS NOT NULL`,
+			req.FromUserID, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
+		t.HandedToTeamOwners += rowsAffected(res)
+		teamItems -= rowsAffected(res)
+	}
+	t.Skipped = teamItems
+
+	selection := map[string]interface{}{"all": true}
+	if !req.All {
+		selection = map[string]interface{}{"file_ids": fileIDs, "folder_ids": folderIDs}
+	}
+	t.Selection, _ = json.Marshal(selection)
+	err = tx.QueryRow(`
+		INSERT INTO ownership_transfers (from_user_id, from_username, to_user_id, to_username, performed_by,
+			performed_by_username, source, selection, files, folders, bytes, handed_to_team_owners, reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, NULLIF($13, ''))
+		RETURNING id, created_at`,
+		t.FromUserID, t.FromUsername, t.ToUserID, t.ToUsername, t.PerformedBy, t.PerformedByUsername, t.Source,
+		string(t.Selection), t.Files, t.Folders, t.Bytes, t.HandedToTeamOwners, t.Reason,
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if t.Files > 0 || t.Folders > 0 {
+		RecordActivity(Activity{Kind: ActivityReceived, ActorID: by.UserID, Details: map[string]interface{}{
+			"transfer_id": t.ID, "from_username": t.FromUsername, "files": t.Files, "folders": t.Folders,
+		}}, []int{t.ToUserID})
+	}
+
+	// usage follows the files :
+	if err := RecalculateUserUsage(t.FromUserID); err != nil {
+		return t, err
+	}
+	return t, RecalculateUserUsage(t.ToUserID)
+}
+
+// ListOwnershipTransfers returns the history, newest first; userID (either side) is optional :
+func ListOwnershipTransfers(userID *int, limit int, offset int) ([]OwnershipTransfer, int, error) {
+	where, args := "", []interface{}{}
+	if userID != nil {
+		where, args = " WHERE from_user_id = $1 OR to_user_id = $1", append(args, *userID)
+	}
+
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM ownership_transfers`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit, offset)
+	rows, err := db.DB.Query(fmt.Sprintf(`
+		SELECT id, from_user_id, from_username, to_user_id, to_username, performed_by,
+		       COALESCE(performed_by_username, ''), source, selection, files, folders, bytes,
+		       handed_to_team_owners, COALESCE(reason, ''), created_at
+		FROM ownership_transfers%s ORDER BY id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	transfers := make([]OwnershipTransfer, 0)
+	for rows.Next() {
+		var t OwnershipTransfer
+		var performedBy sql.NullInt64
+		var selection string
+		err := rows.Scan(&t.ID, &t.FromUserID, &t.FromUsername, &t.ToUserID, &t.ToUsername, &performedBy,
+			&t.PerformedByUsername, &t.Source, &selection, &t.Files, &t.Folders, &t.Bytes,
+			&t.HandedToTeamOwners, &t.Reason, &t.CreatedAt)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.PerformedBy = nullIntPtr(performedBy)
+		t.Selection = json.RawMessage(selection)
+		transfers = append(transfers, t)
+	}
+	return transfers, total, rows.Err()
+}
+
+// queryInts runs a single-column id query inside tx :
+func queryInts(tx *sql.Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func uniqueInts(in []int) []int {
+	seen := make(map[int]bool, len(in))
+	out := make([]int, 0, len(in))
+	for _, v := range in {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func rowsAffected(res sql.Result) int {
+	n, _ := res.RowsAffected()
+	return int(n)
+}
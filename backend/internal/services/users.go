@@ -0,0 +1,466 @@
+package services
+
+import (
+	"backend/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// AdminRoleName is the role the last-admin guard protects :
+const AdminRoleName = "admin"
+
+// how a deleted user's files are handled :
+const (
+	UserFilesDelete   = "delete"
+	UserFilesTransfer = "transfer"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrLastAdmin        = errors.New("cannot demote, deactivate or delete the last active admin")
+	ErrUserOwnsFiles    = errors.New("user still owns files, choose files=delete or files=transfer")
+	ErrSoleTeamOwner    = errors.New("user is the only owner of a team, transfer team ownership first")
+	ErrInvalidFilesMode = errors.New("files must be delete or transfer")
+	ErrInvalidTarget    = errors.New("transfer target must be another existing, active user")
+)
+
+// UserSummary is one row of the admin user listing :
+type UserSummary struct {
+	ID            int        `json:"id"`
+	Username      string     `json:"username"`
+	Email         string     `json:"email"`
+	Role          string     `json:"role"`
+	IsActive      bool       `json:"is_active"`
+	EmailVerified bool       `json:"email_verified"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastLogin     *time.Time `json:"last_login"`
+	UsedBytes     int64      `json:"used_bytes"`
+	FileCount     int        `json:"file_count"`
+}
+
+// UserFilter narrows the admin user listing; zero values are ignored :
+type UserFilter struct {
+	Search string // substring of username or email
+	Role   string
+	Active *bool
+}
+
+const userSummaryColumns = `u.id, u.username, u.email, u.role, u.is_active, u.email_verified, u.created_at, u.last_login, u.used_bytes,
+	(SELECT COUNT(*) FROM files f WHERE f.user_id = u.id)`
+
+func scanUserSummary(row interface{ Scan(...interface{}) error }) (UserSummary, error) {
+	var s UserSummary
+	err := row.Scan(&s.ID, &s.Username, &s.Email, &s.Role, &s.IsActive, &s.EmailVerified, &s.CreatedAt, &s.LastLogin, &s.UsedBytes, &s.FileCount)
+	return s, err
+}
+
+// ListUsers returns a page of users (newest first) and the total match count :
+func ListUsers(f UserFilter, limit int, offset int) ([]UserSummary, int, error) {
+	var conds []string
+	var args []interface{}
+	if f.Search != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(f.Search) + "%"
+		args = append(args, pattern)
+		conds = append(conds, fmt.Sprintf("(u.username ILIKE $%d OR u.email ILIKE $%d)", len(args), len(args)))
+	}
+	if f.Role != "" {
+		args = append(args, f.Role)
+		conds = append(conds, fmt.Sprintf("u.role = $%d", len(args)))
+	}
+	if f.Active != nil {
+		args = append(args, *f.Active)
+		conds = append(conds, fmt.Sprintf("u.is_active = $%d", len(args)))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int
+	if err := db.DB.QueryRow(`SELECT COUNT(*) FROM users u`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM users u%s ORDER BY u.created_at DESC, u.id DESC LIMIT $%d OFFSET $%d`,
+		userSummaryColumns, where, len(args)+1, len(args)+2)
+	rows, err := db.DB.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]UserSummary, 0)
+	for rows.Next() {
+		s, err := scanUserSummary(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, s)
+	}
+	return users, total, rows.Err()
+}
+
+// UserTeam is a team membership shown in the user detail :
+type UserTeam struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// UserDetail is the admin view of one user :
+type UserDetail struct {
+	UserSummary
+	Usage *Usage     `json:"usage"`
+	Teams []UserTeam `json:"teams"`
+}
+
+// GetUserDetail returns the admin view of a user, nil when not found :
+func GetUserDetail(userID int) (*UserDetail, error) {
+	s, err := scanUserSummary(db.DB.QueryRow(`SELECT `+userSummaryColumns+` FROM users u WHERE u.id=$1`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := &UserDetail{UserSummary: s, Teams: make([]UserTeam, 0)}
+	if d.Usage, err = GetUserUsage(userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT t.id, t.name, m.role FROM team_members m
+		JOIN teams t ON t.id = m.team_id
+		WHERE m.user_id = $1 ORDER BY t.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t UserTeam
+		if err := rows.Scan(&t.ID, &t.Name, &t.Role); err != nil {
+			return nil, err
+		}
+		d.Teams = append(d.Teams, t)
+	}
+	return d, rows.Err()
+}
+
+// guardLastAdmin locks the active admins and fails if userID is the only one.
+// Locking the rows makes concurrent demotions of two different admins serialise.
+func guardLastAdmin(tx *sql.Tx, userID int) error {
+	rows, err := tx.Query(`SELECT id FROM users WHERE role=$1 AND is_active ORDER BY id FOR UPDATE`, AdminRoleName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 1 && ids[0] == userID {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// SetUserRole changes a user's role, refusing to demote the last active admin.
+// Returns the user's id and previous role.
+func SetUserRole(username string, newRole string) (int, string, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var oldRole string
+	var active bool
+	err = tx.QueryRow(`SELECT id, role, is_active FROM users WHERE username=$1 FOR UPDATE`, username).Scan(&userID, &oldRole, &active)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrUserNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if oldRole == AdminRoleName && newRole != AdminRoleName && active {
+		if err := guardLastAdmin(tx, userID); err != nil {
+			return 0, "", err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE users SET role=$1 WHERE id=$2`, newRole, userID); err != nil {
+		return 0, "", err
+	}
+	return userID, oldRole, tx.Commit()
+}
+
+// SetUserActive activates / deactivates a user. Deactivation also revokes their sessions.
+func SetUserActive(userID int, active bool) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role string
+	err = tx.QueryRow(`SELECT role FROM users WHERE id=$1 FOR UPDATE`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !active && role == AdminRoleName {
+		if err := guardLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE users SET is_active = $2,
+			session_version = session_version + CASE WHEN $2 THEN 0 ELSE 1 END
+		WHERE id = $1`, userID, active)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	InvalidateSessionCache(userID)
+	return nil
+}
+
+// UserDeletion reports what happened to a deleted user's data :
+type UserDeletion struct {
+	FilesDeleted     int `json:"files_deleted"`
+	FilesTransferred int `json:"files_transferred"`
+}
+
+// DeleteUser removes a user. Their files are never dropped implicitly (the FK on
+// files.user_id is RESTRICT, so the DB refuses too) :
+//   - mode ""        → refused with ErrUserOwnsFiles if they own any file
+//   - mode delete    → personal files are deleted (dedup & quota aware), team files and
+//     team folders are handed to another owner of that team
+//   - mode transfer  → everything goes to transferTo (see TransferOwnership), recorded in the history
+//
+// Team folders always go to another team owner; empty personal folders are removed with the user.
+// Every refusal happens before any file is touched (see claimUserDeletion); if handling the files
+// fails afterwards the user stays deactivated and the deletion can be retried.
+func DeleteUser(userID int, mode string, transferTo int, by TransferActor) (*UserDeletion, error) {
+	if err := claimUserDeletion(userID, mode, transferTo); err != nil {
+		return nil, err
+	}
+
+	var err error
+	result := &UserDeletion{}
+	switch mode {
+	case "":
+		if _, err := handOverTeamContent(userID); err != nil {
+			return nil, err
+		}
+
+	case UserFilesTransfer:
+		by.Source = TransferViaUserDelete
+		t, err := TransferOwnership(TransferRequest{FromUserID: userID, ToUserID: transferTo, All: true, Reason: "account deletion"}, by)
+		if t == nil {
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("❌ usage recalculation after transfer %d failed: %v", t.ID, err)
+		}
+		result.FilesTransferred = t.Files + t.HandedToTeamOwners
+
+	case UserFilesDelete:
+		if result.FilesTransferred, err = handOverTeamContent(userID); err != nil {
+			return nil, err
+		}
+		ids, err := personalFileIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if err := DeleteFile(id); err != nil && err != ErrFileNotFound {
+				return result, err
+			}
+			result.FilesDeleted++
+		}
+	}
+
+	// removing the row itself (memberships, tokens & notifications cascade) :
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if err := guardSoleTeamOwner(tx, userID); err != nil {
+		return result, err
+	}
+	if _, err := tx.Exec(`DELETE FROM folders WHERE user_id=$1 AND team_id IS NULL`, userID); err != nil {
+		return result, err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id=$1`, userID); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(); err != nil {
+		return result, err
+	}
+	InvalidateSessionCache(userID)
+	return result, nil
+}
+
+// claimUserDeletion runs every check of DeleteUser under row locks (the user, the active admins,
+// the memberships of the teams they own) and then deactivates the user, so they no longer count as
+// an admin, can't log in or upload while their files are handled, and a concurrent demotion or
+// deletion sees the right number of admins left.
+func claimUserDeletion(userID int, mode string, transferTo int) error {
+	switch mode {
+	case "", UserFilesDelete, UserFilesTransfer:
+	default:
+		return ErrInvalidFilesMode
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var role string
+	var active bool
+	err = tx.QueryRow(`SELECT role, is_active FROM users WHERE id=$1 FOR UPDATE`, userID).Scan(&role, &active)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if mode == UserFilesTransfer {
+		if transferTo == userID {
+			return ErrInvalidTarget
+		}
+		var targetActive bool
+		err = tx.QueryRow(`SELECT is_active FROM users WHERE id=$1`, transferTo).Scan(&targetActive)
+		if err == sql.ErrNoRows || (err == nil && !targetActive) {
+			return ErrInvalidTarget
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if role == AdminRoleName && active {
+		if err := guardLastAdmin(tx, userID); err != nil {
+			return err
+		}
+	}
+	if err := guardSoleTeamOwner(tx, userID); err != nil {
+		return err
+	}
+	if mode == "" {
+		var files int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM files WHERE user_id=$1`, userID).Scan(&files); err != nil {
+			return err
+		}
+		if files > 0 {
+			return ErrUserOwnsFiles
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET is_active = FALSE, session_version = session_version + 1 WHERE id=$1`, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	InvalidateSessionCache(userID)
+	return nil
+}
+
+// guardSoleTeamOwner refuses when a team would lose its last owner with the user, locking the
+// memberships of the teams they own so no other owner can leave meanwhile :
+func guardSoleTeamOwner(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec(`
+		SELECT 1 FROM team_members
+		WHERE team_id IN (SELECT team_id FROM team_members WHERE user_id = $1 AND role = $2)
+		ORDER BY team_id, user_id
+		FOR UPDATE`, userID, TeamRoleOwner); err != nil {
+		return err
+	}
+	var soleOwner int
+	err := tx.QueryRow(`
+		SELECT COUNT(*) FROM team_members m
+		WHERE m.user_id = $1 AND m.role = $2
+		AND NOT EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = m.team_id AND o.role = $2 AND o.user_id <> $1)`,
+		userID, TeamRoleOwner,
+	).Scan(&soleOwner)
+	if err != nil {
+		return err
+	}
+	if soleOwner > 0 {
+		return ErrSoleTeamOwner
+	}
+	return nil
+}
+
+// handOverTeamContent gives a user's team files & folders to another owner of each team :
+func handOverTeamContent(userID int) (int, error) {
+	const otherOwner = `(SELECT o.user_id FROM team_members o
+		WHERE o.team_id = %s.team_id AND o.role = $2 AND o.user_id <> $1
+		ORDER BY o.added_at, o.user_id LIMIT 1)`
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE files SET user_id = `+fmt.Sprintf(otherOwner, "files")+`
+		WHERE user_id = $1 AND team_id IS NOT NULL`, userID, TeamRoleOwner)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE folders SET user_id = `+fmt.Sprintf(otherOwner, "folders")+`
+		WHERE user_id = $1 AND team_id IS NOT NULL`, userID, TeamRoleOwner); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// personalFileIDs lists the ids of a user's personal-space files :
+func personalFileIDs(userID int) ([]int, error) {
+	rows, err := db.DB.Query(`SELECT id FROM files WHERE user_id=$1 AND team_id IS NULL ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // fn. for converting bytes into human-readable KB/MB/GB string
 func FormatBytes(b int64) string {
@@ -17,3 +21,13 @@ func FormatBytes(b int64) string {
 	pre := "KMGTPE"[exp : exp+1]
 	return fmt.Sprintf("%.1f %sB", float64(b)/float64(div), pre)
 }
+
+// fn. for cutting text to at most n characters (what VARCHAR(n) counts), never splitting a rune;
+// invalid UTF-8 is dropped too since Postgres rejects it :
+func TruncateRunes(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "short", in: "alice", n: 50, want: "alice"},
+		{name: "exact", in: "abc", n: 3, want: "abc"},
+		{name: "ascii cut", in: "abcdef", n: 4, want: "abcd"},
+		{name: "counts characters, not bytes", in: "ééé", n: 3, want: "ééé"},
+		{name: "multi-byte cut", in: "日本語テキスト", n: 3, want: "日本語"},
+		{name: "emoji cut", in: "a😀😀", n: 2, want: "a😀"},
+		{name: "invalid bytes dropped", in: "ab\xffcd", n: 10, want: "abcd"},
+		{name: "split rune dropped", in: "ab\xe6\x97", n: 10, want: "ab"},
+		{name: "empty", in: "", n: 5, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateRunes(tt.in, tt.n)
+			if got != tt.want {
+				t.Errorf("TruncateRunes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result %q isn't valid UTF-8", got)
+			}
+		})
+	}
+
+	// a long path made of multi-byte characters stays within a VARCHAR(128) :
+	got := TruncateRunes("GET /api/fileDetails/"+strings.Repeat("é", 500), 128)
+	if n := utf8.RuneCountInString(got); n != 128 {
+		t.Errorf("got %d characters, want 128", n)
+	}
+}
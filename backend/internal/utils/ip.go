@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"backend/internal/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the caller's IP address.
+// X-Forwarded-For / X-Real-IP are only honoured when TRUST_PROXY_HEADERS is set,
+// otherwise any client could spoof them.
+func ClientIP(r *http.Request) string {
+	if config.AppConfig.TrustProxyHeaders {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			first := strings.TrimSpace(strings.Split(xff, ",")[0])
+			if net.ParseIP(first) != nil {
+				return first
+			}
+		}
+		if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(real) != nil {
+			return real
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -13,3 +13,13 @@ func GetUserQuotaBytes() int64 {
 	}
 	return int64(mb) * 1024 * 1024
 }
+
+// GetTeamQuotaBytes returns the default team quota in bytes.
+// Reads TEAM_QUOTA_MB from env, defaults to 100 MB if unset or invalid.
+func GetTeamQuotaBytes() int64 {
+	mb := config.AppConfig.TeamQuotaMB
+	if mb < 0 {
+		mb = 100 //  default: 100MB
+	}
+	return int64(mb) * 1024 * 1024
+}
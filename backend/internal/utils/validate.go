@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// FieldError describes why a single input field was rejected :
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// usernames : 3-32 chars, letters/digits/._- and must start with a letter or digit
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{2,31}$`)
+
+// ValidateUsername returns a message describing what's wrong, or "" if valid :
+func ValidateUsername(username string) string {
+	if username == "" {
+		return "username is required"
+	}
+	if !usernamePattern.MatchString(username) {
+		return "username must be 3-32 characters: letters, digits, '.', '_' or '-', starting with a letter or digit"
+	}
+	return ""
+}
+
+// ValidateEmail returns a message describing what's wrong, or "" if valid :
+func ValidateEmail(email string) string {
+	if email == "" {
+		return "email is required"
+	}
+	if len(email) > 254 {
+		return "email is too long"
+	}
+
+	// only bare addresses, no display names like "Alice <a@b.c>" :
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "email is not a valid address"
+	}
+
+	// requiring a dotted domain part :
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "email domain is not valid"
+	}
+	return ""
+}